@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/client"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultServer = "http://localhost:8080"
+	cliUsage      = "usage: linkwatch add|list|get|rm|results|pause|resume|import [flags] [args]"
+)
+
+// cliCommands are the subcommands that talk to a running server.
+var cliCommands = map[string]func(*cli, []string) error{
+	"add":     (*cli).add,
+	"list":    (*cli).list,
+	"get":     (*cli).get,
+	"rm":      (*cli).rm,
+	"results": (*cli).results,
+	"pause":   func(c *cli, args []string) error { return c.setPaused("pause", args, true) },
+	"resume":  func(c *cli, args []string) error { return c.setPaused("resume", args, false) },
+	"import":  (*cli).importTargets,
+}
+
+// errUsage makes runCLI exit 2; the message has already been printed.
+var errUsage = errors.New("usage")
+
+// errInvalidRows makes runCLI exit 1 after a best-effort import skipped rows.
+var errInvalidRows = errors.New("some rows were not imported")
+
+type cli struct {
+	stdout, stderr io.Writer
+	getenv         func(string) string
+	client         *client.Client
+	json           bool
+}
+
+// cliConfig is the config file, by default linkwatch/config.yaml in the
+// user's config directory.
+type cliConfig struct {
+	Server string `yaml:"server"`
+	APIKey string `yaml:"api_key"`
+}
+
+// runCLI runs the subcommand in args[0] and returns the exit code: 0 on
+// success, 1 on errors and 2 on usage errors.
+func runCLI(args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	if len(args) == 0 || cliCommands[args[0]] == nil {
+		fmt.Fprintln(stderr, cliUsage)
+		return 2
+	}
+	c := &cli{stdout: stdout, stderr: stderr, getenv: getenv}
+	err := cliCommands[args[0]](c, args[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	}
+	fmt.Fprintln(stderr, err)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		for _, fe := range apiErr.Errors {
+			fmt.Fprintf(stderr, "  %s: %s\n", fe.Field, fe.Message)
+		}
+	}
+	return 1
+}
+
+// flags returns a flag set with the connection and output flags every
+// subcommand takes.
+func (c *cli) flags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.String("server", "", "server URL (env LINKWATCH_SERVER, default "+defaultServer+")")
+	fs.String("api-key", "", "API key (env LINKWATCH_API_KEY)")
+	fs.String("config", "", "config file with server and api_key (env LINKWATCH_CONFIG)")
+	fs.String("o", "table", "output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: linkwatch %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses flags anywhere among the arguments, checks that there are
+// between min and max of those (max < 0 for no limit) and connects the
+// client.
+func (c *cli) parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errUsage
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) < min || (max >= 0 && len(positional) > max) {
+		fs.Usage()
+		return nil, errUsage
+	}
+
+	output := fs.Lookup("o").Value.String()
+	if output != "table" && output != "json" {
+		fmt.Fprintf(c.stderr, "invalid -o %q: must be table or json\n", output)
+		return nil, errUsage
+	}
+	c.json = output == "json"
+
+	server, apiKey, err := c.endpoint(fs)
+	if err != nil {
+		return nil, err
+	}
+	c.client = client.New(server, apiKey)
+	return positional, nil
+}
+
+// endpoint picks the server URL and API key from the flags, else the
+// environment, else the config file.
+func (c *cli) endpoint(fs *flag.FlagSet) (server, apiKey string, err error) {
+	cfg, err := c.loadConfig(fs.Lookup("config").Value.String())
+	if err != nil {
+		return "", "", err
+	}
+	server = firstNonEmpty(fs.Lookup("server").Value.String(), c.getenv("LINKWATCH_SERVER"), cfg.Server, defaultServer)
+	apiKey = firstNonEmpty(fs.Lookup("api-key").Value.String(), c.getenv("LINKWATCH_API_KEY"), cfg.APIKey)
+	return server, apiKey, nil
+}
+
+// loadConfig reads the config file at path, or else the one named by
+// LINKWATCH_CONFIG, or else the default one if it exists.
+func (c *cli) loadConfig(path string) (cliConfig, error) {
+	var cfg cliConfig
+	path = firstNonEmpty(path, c.getenv("LINKWATCH_CONFIG"))
+	optional := path == ""
+	if optional {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return cfg, nil
+		}
+		path = filepath.Join(dir, "linkwatch", "config.yaml")
+	}
+	b, err := os.ReadFile(path)
+	if optional && errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// labelFlag collects repeated -label key=value flags.
+type labelFlag map[string]string
+
+func (l labelFlag) String() string { return "" }
+
+func (l labelFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return errors.New("must be key=value")
+	}
+	l[k] = v
+	return nil
+}
+
+func (c *cli) add(args []string) error {
+	fs := c.flags("add", "URL")
+	labels := labelFlag{}
+	fs.Var(labels, "label", "label as key=value; repeatable")
+	public := fs.Bool("public", false, "show the target on badges and status pages without a key")
+	interval := fs.String("interval", "", "check interval, such as 30s; default the server's")
+	pos, err := c.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	req := apitypes.CreateTargetRequest{URL: pos[0], Public: *public, Interval: *interval}
+	if len(labels) > 0 {
+		req.Labels = labels
+	}
+	t, created, err := c.client.CreateTarget(context.Background(), req, "")
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(t)
+	}
+	if !created {
+		fmt.Fprintln(c.stderr, "target already exists")
+	}
+	return c.printTargets([]apitypes.Target{*t})
+}
+
+// list prints every target, following next_page_token until the last page.
+func (c *cli) list(args []string) error {
+	fs := c.flags("list", "")
+	host := fs.String("host", "", "only targets on this host")
+	if _, err := c.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	targets := []apitypes.Target{}
+	for t, err := range c.client.Targets(context.Background(), client.ListTargetsOptions{Host: *host}) {
+		if err != nil {
+			return err
+		}
+		targets = append(targets, t)
+	}
+	return c.printTargets(targets)
+}
+
+func (c *cli) get(args []string) error {
+	pos, err := c.parse(c.flags("get", "ID"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	t, err := c.client.GetTarget(context.Background(), pos[0])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(t)
+	}
+	return c.printTargets([]apitypes.Target{*t})
+}
+
+// rm deletes targets in order and stops at the first error.
+func (c *cli) rm(args []string) error {
+	pos, err := c.parse(c.flags("rm", "ID..."), args, 1, -1)
+	if err != nil {
+		return err
+	}
+	for _, id := range pos {
+		if err := c.client.DeleteTarget(context.Background(), id); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		if !c.json {
+			fmt.Fprintln(c.stdout, "deleted", id)
+		}
+	}
+	return nil
+}
+
+func (c *cli) setPaused(name string, args []string, paused bool) error {
+	pos, err := c.parse(c.flags(name, "ID..."), args, 1, -1)
+	if err != nil {
+		return err
+	}
+	targets := make([]apitypes.Target, 0, len(pos))
+	for _, id := range pos {
+		t, err := c.client.SetPaused(context.Background(), id, paused)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		targets = append(targets, *t)
+	}
+	return c.printTargets(targets)
+}
+
+func (c *cli) results(args []string) error {
+	fs := c.flags("results", "ID")
+	since := fs.Duration("since", 0, "only results of this last period, such as 1h")
+	limit := fs.Int("limit", 0, "at most this many results; default the server's")
+	pos, err := c.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	opts := client.ResultsOptions{Limit: *limit}
+	if *since > 0 {
+		opts.Since = time.Now().Add(-*since)
+	}
+	results, err := c.client.Results(context.Background(), pos[0], opts)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(results)
+	}
+	tw := c.table("CHECKED AT", "STATUS", "LATENCY", "ERROR")
+	for _, r := range results {
+		status := "-"
+		if r.StatusCode != 0 {
+			status = strconv.Itoa(r.StatusCode)
+		}
+		errMsg := ""
+		if r.Error != nil {
+			errMsg = *r.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%dms\t%s\n", r.CheckedAt.Local().Format(time.DateTime), status, r.LatencyMs, errMsg)
+	}
+	return tw.Flush()
+}
+
+// importTargets sends a file, or stdin for "-", to the import endpoint. The
+// format comes from -format or else the file extension.
+func (c *cli) importTargets(args []string) error {
+	fs := c.flags("import", "FILE|-")
+	bestEffort := fs.Bool("best-effort", false, "import the valid rows even if others are invalid")
+	format := fs.String("format", "", "json, csv or yaml; default from the file extension, else json")
+	pos, err := c.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(pos[0]), ".")
+	}
+	contentType := map[string]string{
+		"":     "application/json",
+		"json": "application/json",
+		"csv":  "text/csv",
+		"yaml": "application/yaml",
+		"yml":  "application/yaml",
+	}[*format]
+	if contentType == "" {
+		fmt.Fprintf(c.stderr, "unknown format %q: use -format json, csv or yaml\n", *format)
+		return errUsage
+	}
+
+	var r io.Reader = os.Stdin
+	if pos[0] != "-" {
+		f, err := os.Open(pos[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	result, err := c.client.ImportTargets(context.Background(), r, contentType, *bestEffort)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		err = c.printJSON(result)
+	} else {
+		tw := c.table("ROW", "STATUS", "ID", "URL", "ERROR")
+		for _, item := range result.Items {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", item.Index, item.Status, item.ID, item.URL, item.Error)
+		}
+		err = tw.Flush()
+		fmt.Fprintf(c.stdout, "%d created, %d existing, %d invalid\n", result.Created, result.Existing, result.Invalid)
+	}
+	if err == nil && result.Invalid > 0 {
+		return errInvalidRows
+	}
+	return err
+}
+
+func (c *cli) printTargets(targets []apitypes.Target) error {
+	if c.json {
+		return c.printJSON(targets)
+	}
+	tw := c.table("ID", "URL", "INTERVAL", "PUBLIC", "PAUSED", "LABELS")
+	for _, t := range targets {
+		interval := t.Interval
+		if interval == "" {
+			interval = "default"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%t\t%s\n", t.ID, t.URL, interval, t.Public, t.Paused, formatLabels(t.Labels))
+	}
+	return tw.Flush()
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// table returns a tab writer for stdout that has printed the header row.
+func (c *cli) table(header ...string) *tabwriter.Writer {
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	return tw
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/api"
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "test-admin-key"
+
+// newCLI returns a function that runs the CLI against a test server, with
+// the server and key in the environment.
+func newCLI(t *testing.T) (func(args ...string) (code int, stdout, stderr string), storage.Storage) {
+	s := testutil.SetupTestDB(t)
+	srv := httptest.NewServer(api.NewHandler(s).Routes(auth.New(s, testKey)))
+	t.Cleanup(srv.Close)
+	env := map[string]string{
+		"LINKWATCH_SERVER":  srv.URL,
+		"LINKWATCH_API_KEY": testKey,
+		"LINKWATCH_CONFIG":  filepath.Join(t.TempDir(), "config.yaml"),
+	}
+	require.NoError(t, os.WriteFile(env["LINKWATCH_CONFIG"], nil, 0o600))
+	return func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(args, &stdout, &stderr, func(k string) string { return env[k] })
+		return code, stdout.String(), stderr.String()
+	}, s
+}
+
+func TestCLI_Targets(t *testing.T) {
+	run, s := newCLI(t)
+
+	code, out, _ := run("add", "https://example.com", "-label", "env=prod", "-interval", "5m")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "https://example.com")
+	assert.Contains(t, out, "env=prod")
+
+	code, out, _ = run("add", "-o", "json", "https://example.com")
+	require.Equal(t, 0, code)
+	var target apitypes.Target
+	require.NoError(t, json.Unmarshal([]byte(out), &target))
+	assert.Equal(t, "5m0s", target.Interval)
+
+	for i := 0; i < 120; i++ {
+		s.CreateTarget(context.Background(), "https://"+strings.Repeat("a", i+1)+".example.com", "")
+	}
+	code, out, _ = run("list", "-o", "json")
+	require.Equal(t, 0, code)
+	var targets []apitypes.Target
+	require.NoError(t, json.Unmarshal([]byte(out), &targets))
+	assert.Len(t, targets, 121, "list follows every page")
+
+	code, out, _ = run("list", "-host", "example.com")
+	require.Equal(t, 0, code)
+	assert.Equal(t, 2, strings.Count(out, "\n"), "header and one target")
+
+	code, out, _ = run("pause", target.ID)
+	require.Equal(t, 0, code)
+	assert.Regexp(t, `true\s+env=prod`, out)
+	got, _ := s.GetTarget(context.Background(), target.ID)
+	assert.True(t, got.Paused)
+	code, _, _ = run("resume", target.ID)
+	require.Equal(t, 0, code)
+	got, _ = s.GetTarget(context.Background(), target.ID)
+	assert.False(t, got.Paused)
+
+	code, out, _ = run("results", target.ID, "-since", "1h")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "CHECKED AT")
+
+	code, out, _ = run("rm", target.ID)
+	require.Equal(t, 0, code)
+	assert.Equal(t, "deleted "+target.ID+"\n", out)
+	code, _, errOut := run("get", target.ID)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "404")
+}
+
+func TestCLI_Import(t *testing.T) {
+	run, _ := newCLI(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "targets.csv")
+	require.NoError(t, os.WriteFile(path, []byte("url,interval\nhttps://a.example.com,\nhttps://b.example.com,soon\n"), 0o600))
+
+	code, _, errOut := run("import", path)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "[1].interval")
+
+	code, out, _ := run("import", "-best-effort", path)
+	assert.Equal(t, 1, code, "invalid rows fail the command")
+	assert.Contains(t, out, "1 created, 0 existing, 1 invalid")
+
+	code, _, errOut = run("import", "-format", "xml", path)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "unknown format")
+}
+
+func TestCLI_Config(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("server: http://config:1\napi_key: from-config\n"), 0o600))
+	env := map[string]string{"LINKWATCH_CONFIG": path, "LINKWATCH_API_KEY": "from-env"}
+	c := &cli{stderr: &bytes.Buffer{}, getenv: func(k string) string { return env[k] }}
+
+	cfg, err := c.loadConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, cliConfig{Server: "http://config:1", APIKey: "from-config"}, cfg)
+	_, err = c.loadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err, "a named config file must exist")
+
+	fs := c.flags("list", "")
+	require.NoError(t, fs.Parse([]string{"-server", "http://flag:1"}))
+	server, apiKey, err := c.endpoint(fs)
+	require.NoError(t, err)
+	assert.Equal(t, "http://flag:1", server, "flags win")
+	assert.Equal(t, "from-env", apiKey, "the environment beats the config file")
+	server, _, err = c.endpoint(c.flags("list", ""))
+	require.NoError(t, err)
+	assert.Equal(t, "http://config:1", server)
+
+	assert.Equal(t, 2, runCLI([]string{"bogus"}, &bytes.Buffer{}, &bytes.Buffer{}, c.getenv))
+	assert.Equal(t, 2, runCLI([]string{"get"}, &bytes.Buffer{}, &bytes.Buffer{}, c.getenv))
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,78 +12,232 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/AlanZeng-Coder/linkwatch/internal/alerting"
 	"github.com/AlanZeng-Coder/linkwatch/internal/api"
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/events"
+	"github.com/AlanZeng-Coder/linkwatch/internal/logging"
+	"github.com/AlanZeng-Coder/linkwatch/internal/maintenance"
+	"github.com/AlanZeng-Coder/linkwatch/internal/metrics"
+	"github.com/AlanZeng-Coder/linkwatch/internal/ratelimit"
+	"github.com/AlanZeng-Coder/linkwatch/internal/statuspage"
 	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/targetsfile"
+	"github.com/AlanZeng-Coder/linkwatch/internal/tracing"
 
 	"github.com/AlanZeng-Coder/linkwatch/internal/checker"
+	"github.com/AlanZeng-Coder/linkwatch/internal/notifier"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
+	if len(os.Args) > 1 && cliCommands[os.Args[1]] != nil {
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr, os.Getenv))
+	}
+	diffOnly := len(os.Args) > 1 && os.Args[1] == "diff"
+	if (len(os.Args) > 1 && !diffOnly) || len(os.Args) > 3 {
+		fmt.Fprintln(os.Stderr, "usage: linkwatch [diff [targets-file]]")
+		fmt.Fprintln(os.Stderr, "       "+strings.TrimPrefix(cliUsage, "usage: "))
+		os.Exit(2)
+	}
+	logger, err := logging.New(os.Stderr, getEnvString("LOG_FORMAT", "text"), getEnvString("LOG_LEVEL", "info"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
 	checkInterval := getEnvDuration("CHECK_INTERVAL", 15*time.Second)
 	maxConc := getEnvInt("MAX_CONCURRENCY", 8)
 	httpTimeout := getEnvDuration("HTTP_TIMEOUT", 5*time.Second)
 	shutdownGrace := getEnvDuration("SHUTDOWN_GRACE", 10*time.Second)
+	readyMaxCycleAge := getEnvDuration("READY_MAX_CYCLE_AGE", 3*checkInterval)
+	failThreshold := getEnvInt("FAIL_THRESHOLD", 3)
+	recoverThreshold := getEnvInt("RECOVER_THRESHOLD", 2)
+	degradedLatency := getEnvDuration("DEGRADED_LATENCY", 0)
+	flapWindow := getEnvInt("FLAP_WINDOW", 21)
+	flapLow := getEnvFloat("FLAP_LOW_THRESHOLD", 5)
+	flapHigh := getEnvFloat("FLAP_HIGH_THRESHOLD", 20)
+	webhookURLs := getEnvList("WEBHOOK_URLS")
+	webhookSecret := os.Getenv("WEBHOOK_SECRET")
+	webhookMaxAttempts := getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5)
+	webhookRetryBackoff := getEnvDuration("WEBHOOK_RETRY_BACKOFF", 10*time.Second)
+	smtpCfg := notifier.SMTPConfig{
+		Addr:     os.Getenv("SMTP_ADDR"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		StartTLS: getEnvBool("SMTP_STARTTLS", true),
+	}
+	alertInterval := getEnvDuration("ALERT_INTERVAL", 30*time.Second)
+	emailSubject := getEnvString("EMAIL_SUBJECT_TEMPLATE", notifier.DefaultEmailSubject)
+	emailBody := notifier.DefaultEmailBody
+	if path := os.Getenv("EMAIL_BODY_TEMPLATE_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			fatal("reading email body template", err)
+		}
+		emailBody = string(b)
+	}
+
+	tracingCfg := tracing.Config{
+		Exporter:    getEnvString("TRACING_EXPORTER", tracing.ExporterNone),
+		Endpoint:    os.Getenv("TRACING_ENDPOINT"),
+		ServiceName: getEnvString("TRACING_SERVICE_NAME", "linkwatch"),
+	}
+	tracePropagation := getEnvBool("TRACE_PROPAGATION", false)
+	eventHistory := getEnvInt("EVENT_HISTORY", 1000)
+	adminAPIKey := os.Getenv("ADMIN_API_KEY")
+	var limits [3]ratelimit.Limit
+	for i, env := range []struct{ key, def string }{
+		{"RATE_LIMIT_READ", "600/1m"},
+		{"RATE_LIMIT_WRITE", "60/1m"},
+		{"RATE_LIMIT_PUBLIC", "300/1m"},
+	} {
+		if limits[i], err = ratelimit.ParseLimit(getEnvString(env.key, env.def)); err != nil {
+			fatal("parsing "+env.key, err)
+		}
+	}
+	trustedProxies := 0
+	if v := os.Getenv("TRUST_PROXY"); v != "" {
+		if trustedProxies, err = strconv.Atoi(v); err != nil || trustedProxies < 0 {
+			fatal("parsing TRUST_PROXY", fmt.Errorf("%q is not a number of proxies", v))
+		}
+	}
+	targetsFile := os.Getenv("TARGETS_FILE")
+	targetsFilePoll := getEnvDuration("TARGETS_FILE_POLL", 10*time.Second)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingCfg)
+	if err != nil {
+		fatal("setting up tracing", err)
+	}
 
-	db, err := sql.Open("sqlite3", "./linkwatch.db")
+	db, err := tracing.OpenDB("sqlite3", "./linkwatch.db")
 	if err != nil {
-		log.Fatal(err)
+		fatal("opening database", err)
 	}
 	s := storage.NewSQLiteStorage(db)
 	if err := s.Init(context.Background()); err != nil {
-		log.Fatal(err)
+		fatal("initializing database", err)
 	}
 	defer s.Close()
 
+	if diffOnly {
+		if len(os.Args) == 3 {
+			targetsFile = os.Args[2]
+		}
+		os.Exit(diffTargets(s, targetsFile))
+	}
+	var targets *targetsfile.Reconciler
+	if targetsFile != "" {
+		targets = targetsfile.NewReconciler(s, targetsFile, targetsFilePoll)
+		plan, err := targets.Reconcile()
+		if err != nil {
+			fatal("applying targets file", err)
+		}
+		slog.Info("applied targets file", "path", targetsFile, "created", plan.Count(targetsfile.ActionCreate),
+			"updated", plan.Count(targetsfile.ActionUpdate), "deleted", plan.Count(targetsfile.ActionDelete))
+		go targets.Start()
+	}
+
 	c := checker.NewChecker(s, checkInterval, maxConc, httpTimeout)
+	c.SetThresholds(failThreshold, recoverThreshold)
+	c.SetDegradedLatency(degradedLatency)
+	c.SetFlapDetection(flapWindow, flapLow, flapHigh)
+	c.SetTracePropagation(tracePropagation)
+	m := maintenance.NewManager(s)
+	c.SetMaintenance(m)
+
+	var webhooks []notifier.Webhook
+	for _, u := range webhookURLs {
+		webhooks = append(webhooks, notifier.Webhook{URL: u, Secret: webhookSecret})
+	}
+	n := notifier.NewNotifier(s, webhooks, httpTimeout)
+	n.SetRetryPolicy(webhookMaxAttempts, webhookRetryBackoff)
+	n.SetSMTP(smtpCfg)
+	n.SetSuppressor(m)
+	if err := n.SetEmailTemplates(emailSubject, emailBody); err != nil {
+		fatal("parsing email templates", err)
+	}
+	c.AddListener(n)
+	e := alerting.NewEngine(s, n, alertInterval)
+	c.AddListener(e)
+	prom := metrics.New()
+	prom.RegisterChecker(c)
+	c.AddListener(prom)
+	broker := events.NewBroker(eventHistory)
+	c.AddListener(broker)
+	go n.Start()
+	go e.Start()
 	go c.Start()
 
-	h := api.NewHandler(s)
-	mux := http.NewServeMux()
-	mux.HandleFunc("/v1/targets", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "POST" {
-			h.PostTarget(w, r)
-		} else if r.Method == "GET" {
-			h.ListTargets(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	authn := auth.New(s, adminAPIKey)
+	if adminAPIKey == "" {
+		if keys, err := s.ListAPIKeys(context.Background()); err == nil && len(keys) == 0 {
+			slog.Warn("no API keys exist and ADMIN_API_KEY is not set; the API cannot be used until one is configured")
 		}
-	})
+	}
 
-	mux.HandleFunc("/v1/targets/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" {
-			path := r.URL.Path
-			if strings.HasSuffix(path, "/results") {
-				id := strings.TrimSuffix(strings.TrimPrefix(path, "/v1/targets/"), "/results")
-				h.GetResults(w, r, id)
-			} else {
-				h.ListTargets(w, r)
-			}
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
+	h := api.NewHandler(s)
+	h.SetChannelTester(n)
+	h.SetReadiness(c, readyMaxCycleAge)
+	h.SetEvents(broker)
+	readLimit, writeLimit, publicLimit := ratelimit.New(limits[0]), ratelimit.New(limits[1]), ratelimit.New(limits[2])
+	for _, l := range []*ratelimit.Limiter{readLimit, writeLimit, publicLimit} {
+		l.SetTrustedProxies(trustedProxies)
+	}
+	h.SetRateLimits(readLimit, writeLimit, publicLimit)
+	h.SetInstrumenter(func(route string, next http.HandlerFunc) http.HandlerFunc {
+		return tracing.Handler(route, prom.Instrument(route, next)).ServeHTTP
 	})
+	h.SetStatusPages(statuspage.New(s))
+	h.SetMetricsHandler(prom.Handler())
 
-	srv := &http.Server{Addr: ":8080", Handler: mux}
+	srv := &http.Server{
+		Addr:     ":8080",
+		Handler:  logging.Middleware(h.Routes(authn)),
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
 	go func() {
+		slog.Info("listening", "addr", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal(err)
+			fatal("serving http", err)
 		}
 	}()
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+	if targets != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				targets.Reload()
+			}
+		}()
+	}
 	<-sig
 
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
 	defer cancel()
 	c.Stop()
+	if targets != nil {
+		targets.Stop()
+	}
+	e.Stop()
+	n.Stop()
+	broker.Close()
 	srv.Shutdown(ctx)
-	log.Println("Shutdown complete")
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("flushing traces", "err", err)
+	}
+	slog.Info("shutdown complete")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
 
 func getEnvDuration(key string, def time.Duration) time.Duration {
@@ -109,3 +263,44 @@ func getEnvInt(key string, def int) int {
 	}
 	return i
 }
+
+func getEnvFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+func getEnvList(key string) []string {
+	var items []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+func getEnvString(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func getEnvBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/targetsfile"
+)
+
+// diffTargets prints what applying the targets file at path would change
+// without changing anything. Like diff(1) it exits 1 when there are changes
+// and 2 on errors.
+func diffTargets(s storage.Storage, path string) int {
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "no targets file: pass a path or set TARGETS_FILE")
+		return 2
+	}
+	f, err := targetsfile.Load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	plan, err := targetsfile.Diff(context.Background(), s, f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Print(plan)
+	if plan.Empty() {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,261 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/checker"
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/notifier"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/google/uuid"
+)
+
+type Sender interface {
+	Notify(ev *notifier.Event, t *storage.Target, channelIDs []string)
+}
+
+// queueSize is how many saved results may wait for evaluation before new
+// ones are dropped.
+const queueSize = 1024
+
+// Engine evaluates alert rules against a target's recent results every time
+// a check is saved, and periodically re-notifies or escalates alerts that
+// are still firing and unacknowledged. Both happen on the goroutine running
+// Start, so the checker never waits for rule evaluation.
+type Engine struct {
+	storage  storage.Storage
+	sender   Sender
+	interval time.Duration
+	now      func() time.Time
+	queue    chan *storage.Target
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewEngine(s storage.Storage, sender Sender, interval time.Duration) *Engine {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Engine{
+		storage:  s,
+		sender:   sender,
+		interval: interval,
+		now:      func() time.Time { return time.Now().UTC() },
+		queue:    make(chan *storage.Target, queueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func (e *Engine) Start() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case t := <-e.queue:
+			e.evaluateAll(t)
+		case <-ticker.C:
+			e.followUp()
+		}
+	}
+}
+
+func (e *Engine) Stop() {
+	e.cancel()
+}
+
+// ResultSaved queues the target for evaluation. Rules look at the latest
+// results, so when the queue is full the result is dropped and the target is
+// evaluated again on its next check.
+func (e *Engine) ResultSaved(t *storage.Target, r *storage.CheckResult) {
+	select {
+	case e.queue <- t:
+	default:
+		slog.Warn("alert evaluation queue full, dropping result", "target_id", t.ID)
+	}
+}
+
+// evaluateAll evaluates every rule that applies to t.
+func (e *Engine) evaluateAll(t *storage.Target) {
+	rules, err := e.storage.ListRules(storage.WithTenant(e.ctx, t.TenantID))
+	if err != nil {
+		slog.Error("listing rules", "err", err)
+		return
+	}
+	for _, rule := range rules {
+		if appliesTo(rule, t) {
+			e.evaluate(rule, t)
+		}
+	}
+}
+
+func (e *Engine) StateChanged(tr checker.Transition) {}
+
+func (e *Engine) FlappingChanged(fc checker.FlapChange) {}
+
+func (e *Engine) TargetRemoved(targetID string) {}
+
+func appliesTo(rule *storage.Rule, t *storage.Target) bool {
+	if rule.TargetID != "" {
+		return rule.TargetID == t.ID
+	}
+	sel, err := labels.Parse(rule.Selector)
+	if err != nil {
+		return false
+	}
+	return sel.Matches(t.Labels)
+}
+
+func (e *Engine) evaluate(rule *storage.Rule, t *storage.Target) {
+	firing, summary, err := e.check(rule, t)
+	if err != nil {
+		slog.Error("evaluating rule", "rule_id", rule.ID, "target_id", t.ID, "err", err)
+		return
+	}
+	open, err := e.storage.GetOpenAlert(e.ctx, rule.ID, t.ID)
+	if err != nil {
+		slog.Error("loading alert", "rule_id", rule.ID, "target_id", t.ID, "err", err)
+		return
+	}
+
+	now := e.now()
+	switch {
+	case firing && open == nil:
+		alert := &storage.Alert{
+			RuleID:         rule.ID,
+			TargetID:       t.ID,
+			Severity:       rule.Severity,
+			Summary:        summary,
+			StartedAt:      now,
+			LastNotifiedAt: now,
+		}
+		if err := e.storage.CreateAlert(e.ctx, alert); err != nil {
+			slog.Error("creating alert", "rule_id", rule.ID, "target_id", t.ID, "err", err)
+			return
+		}
+		e.sender.Notify(alertEvent("alert.firing", rule, alert, t, now), t, rule.ChannelIDs)
+	case !firing && open != nil:
+		open.Status = storage.AlertResolved
+		open.ResolvedAt = now
+		if err := e.storage.UpdateAlert(e.ctx, open); err != nil {
+			slog.Error("resolving alert", "alert_id", open.ID, "err", err)
+			return
+		}
+		e.notifyAll(alertEvent("alert.resolved", rule, open, t, now), rule, open, t)
+	}
+}
+
+// notifyAll sends ev to every channel that has heard about the alert so far,
+// including the escalation channel once the alert has been escalated.
+func (e *Engine) notifyAll(ev *notifier.Event, rule *storage.Rule, alert *storage.Alert, t *storage.Target) {
+	e.sender.Notify(ev, t, rule.ChannelIDs)
+	if !alert.EscalatedAt.IsZero() && rule.EscalationChannelID != "" {
+		e.sender.Notify(ev, t, []string{rule.EscalationChannelID})
+	}
+}
+
+func (e *Engine) check(rule *storage.Rule, t *storage.Target) (bool, string, error) {
+	cond := rule.Condition
+	switch rule.Kind {
+	case storage.RuleFailures:
+		results, err := e.storage.GetCheckResults(e.ctx, t.ID, time.Time{}, cond.Of)
+		if err != nil {
+			return false, "", err
+		}
+		failed := 0
+		for _, r := range results {
+			if r.Failed() {
+				failed++
+			}
+		}
+		return failed >= cond.Failures, fmt.Sprintf("%d of the last %d checks failed", failed, len(results)), nil
+
+	case storage.RuleLatency:
+		p, checks, err := e.storage.GetLatencyPercentile(e.ctx, t.ID, e.now().Add(-cond.Window), cond.Percentile)
+		if err != nil || checks == 0 {
+			return false, "", err
+		}
+		return p > cond.LatencyMs, fmt.Sprintf("p%g latency %dms over %s exceeds %dms", cond.Percentile, p, cond.Window, cond.LatencyMs), nil
+
+	case storage.RuleCertExpiry:
+		results, err := e.storage.GetCheckResults(e.ctx, t.ID, time.Time{}, 1)
+		if err != nil || len(results) == 0 || results[0].CertExpiresAt.IsZero() {
+			return false, "", err
+		}
+		left := results[0].CertExpiresAt.Sub(e.now())
+		return left < cond.Within, fmt.Sprintf("certificate expires %s (in %s)", results[0].CertExpiresAt.Format(time.RFC3339), left.Round(time.Hour)), nil
+	}
+	return false, "", fmt.Errorf("unknown rule kind %q", rule.Kind)
+}
+
+// followUp repeats notifications for unacknowledged alerts whose repeat
+// interval has passed and escalates those left unacknowledged too long.
+func (e *Engine) followUp() {
+	alerts, err := e.storage.ListAlerts(e.ctx, storage.AlertFiring, 1000)
+	if err != nil {
+		slog.Error("listing alerts", "err", err)
+		return
+	}
+	now := e.now()
+	for _, alert := range alerts {
+		if !alert.AcknowledgedAt.IsZero() {
+			continue
+		}
+		rule, err := e.storage.GetRule(e.ctx, alert.RuleID)
+		if errors.Is(err, storage.ErrNotFound) {
+			alert.Status = storage.AlertResolved
+			alert.ResolvedAt = now
+			if err := e.storage.UpdateAlert(e.ctx, alert); err != nil {
+				slog.Error("resolving orphaned alert", "alert_id", alert.ID, "err", err)
+			}
+			continue
+		}
+		if err != nil {
+			slog.Error("loading rule", "rule_id", alert.RuleID, "err", err)
+			continue
+		}
+		t, err := e.storage.GetTarget(e.ctx, alert.TargetID)
+		if err != nil {
+			slog.Error("loading target", "target_id", alert.TargetID, "err", err)
+			continue
+		}
+
+		changed := false
+		if rule.EscalationChannelID != "" && alert.EscalatedAt.IsZero() && now.Sub(alert.StartedAt) >= rule.EscalateAfter {
+			alert.EscalatedAt = now
+			changed = true
+			e.sender.Notify(alertEvent("alert.escalated", rule, alert, t, now), t, []string{rule.EscalationChannelID})
+		}
+		if rule.RepeatInterval > 0 && now.Sub(alert.LastNotifiedAt) >= rule.RepeatInterval {
+			alert.LastNotifiedAt = now
+			changed = true
+			e.notifyAll(alertEvent("alert.repeat", rule, alert, t, now), rule, alert, t)
+		}
+		if changed {
+			if err := e.storage.UpdateAlert(e.ctx, alert); err != nil {
+				slog.Error("updating alert", "alert_id", alert.ID, "err", err)
+			}
+		}
+	}
+}
+
+func alertEvent(typ string, rule *storage.Rule, alert *storage.Alert, t *storage.Target, at time.Time) *notifier.Event {
+	return &notifier.Event{
+		ID:        "evt_" + uuid.NewString(),
+		Type:      typ,
+		TargetID:  t.ID,
+		URL:       t.URL,
+		State:     alert.Status,
+		Timestamp: at,
+		Labels:    t.Labels,
+		AlertID:   alert.ID,
+		Rule:      rule.Name,
+		Severity:  alert.Severity,
+		Summary:   alert.Summary,
+	}
+}
@@ -0,0 +1,172 @@
+package alerting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/notifier"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sent struct {
+	event    string
+	channels []string
+}
+
+type recordingSender struct {
+	sent []sent
+}
+
+func (r *recordingSender) Notify(ev *notifier.Event, t *storage.Target, channelIDs []string) {
+	r.sent = append(r.sent, sent{event: ev.Type, channels: channelIDs})
+}
+
+func save(t *testing.T, s storage.Storage, e *Engine, target *storage.Target, r *storage.CheckResult) {
+	r.CheckedAt = e.now()
+	require.NoError(t, s.SaveCheckResult(context.Background(), target.ID, r))
+	e.evaluateAll(target)
+}
+
+func TestEngine_FailuresRuleFiresAndResolves(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	sender := &recordingSender{}
+	e := NewEngine(s, sender, time.Minute)
+
+	target, _, err := s.CreateTarget(ctx, "https://example.com", "")
+	require.NoError(t, err)
+	rule := &storage.Rule{Name: "flaky", Kind: storage.RuleFailures, Severity: "warning", ChannelIDs: []string{"ch_1"},
+		Condition: storage.RuleCondition{Failures: 3, Of: 5}}
+	require.NoError(t, s.CreateRule(ctx, rule))
+
+	for _, code := range []int{500, 200, 500} {
+		save(t, s, e, target, &storage.CheckResult{StatusCode: code})
+	}
+	assert.Empty(t, sender.sent)
+
+	save(t, s, e, target, &storage.CheckResult{Error: "connection refused"})
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, sent{"alert.firing", []string{"ch_1"}}, sender.sent[0])
+
+	firing, err := s.ListAlerts(ctx, storage.AlertFiring, 10)
+	require.NoError(t, err)
+	require.Len(t, firing, 1)
+	assert.Equal(t, "3 of the last 4 checks failed", firing[0].Summary)
+
+	for i := 0; i < 3; i++ {
+		save(t, s, e, target, &storage.CheckResult{StatusCode: 200})
+	}
+	require.Len(t, sender.sent, 2)
+	assert.Equal(t, "alert.resolved", sender.sent[1].event)
+
+	firing, err = s.ListAlerts(ctx, storage.AlertFiring, 10)
+	require.NoError(t, err)
+	assert.Empty(t, firing)
+}
+
+func TestEngine_RepeatEscalateAndAcknowledge(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	sender := &recordingSender{}
+	e := NewEngine(s, sender, time.Minute)
+	now := time.Now().UTC()
+	e.now = func() time.Time { return now }
+
+	target, _, err := s.CreateTarget(ctx, "https://example.com", "")
+	require.NoError(t, err)
+	rule := &storage.Rule{Name: "down", Kind: storage.RuleFailures, Severity: "critical", ChannelIDs: []string{"ch_1"},
+		Condition:      storage.RuleCondition{Failures: 1, Of: 1},
+		RepeatInterval: 10 * time.Minute, EscalationChannelID: "ch_2", EscalateAfter: 15 * time.Minute}
+	require.NoError(t, s.CreateRule(ctx, rule))
+
+	save(t, s, e, target, &storage.CheckResult{StatusCode: 503})
+	require.Len(t, sender.sent, 1)
+
+	now = now.Add(5 * time.Minute)
+	e.followUp()
+	assert.Len(t, sender.sent, 1)
+
+	now = now.Add(5 * time.Minute)
+	e.followUp()
+	require.Len(t, sender.sent, 2)
+	assert.Equal(t, sent{"alert.repeat", []string{"ch_1"}}, sender.sent[1])
+
+	now = now.Add(5 * time.Minute)
+	e.followUp()
+	require.Len(t, sender.sent, 3)
+	assert.Equal(t, sent{"alert.escalated", []string{"ch_2"}}, sender.sent[2])
+
+	alerts, err := s.ListAlerts(ctx, storage.AlertFiring, 10)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	alerts[0].AcknowledgedAt = now
+	require.NoError(t, s.UpdateAlert(ctx, alerts[0]))
+
+	now = now.Add(time.Hour)
+	e.followUp()
+	assert.Len(t, sender.sent, 3)
+
+	save(t, s, e, target, &storage.CheckResult{StatusCode: 200})
+	require.Len(t, sender.sent, 5)
+	assert.Equal(t, sent{"alert.resolved", []string{"ch_1"}}, sender.sent[3])
+	assert.Equal(t, sent{"alert.resolved", []string{"ch_2"}}, sender.sent[4])
+}
+
+func TestEngine_LatencyAndCertExpiry(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	sender := &recordingSender{}
+	e := NewEngine(s, sender, time.Minute)
+
+	target, _, err := s.CreateTarget(ctx, "https://example.com", "")
+	require.NoError(t, err)
+	require.NoError(t, s.CreateRule(ctx, &storage.Rule{Name: "slow", Kind: storage.RuleLatency, Severity: "warning",
+		Condition: storage.RuleCondition{Percentile: 95, LatencyMs: 800, Window: 10 * time.Minute}}))
+	require.NoError(t, s.CreateRule(ctx, &storage.Rule{Name: "cert", Kind: storage.RuleCertExpiry, Severity: "info",
+		Condition: storage.RuleCondition{Within: 7 * 24 * time.Hour}}))
+
+	for i := 0; i < 18; i++ {
+		save(t, s, e, target, &storage.CheckResult{StatusCode: 200, LatencyMs: 100, CertExpiresAt: time.Now().Add(30 * 24 * time.Hour)})
+	}
+	assert.Empty(t, sender.sent)
+
+	save(t, s, e, target, &storage.CheckResult{StatusCode: 200, LatencyMs: 900, CertExpiresAt: time.Now().Add(30 * 24 * time.Hour)})
+	save(t, s, e, target, &storage.CheckResult{StatusCode: 200, LatencyMs: 900, CertExpiresAt: time.Now().Add(3 * 24 * time.Hour)})
+	require.Len(t, sender.sent, 2)
+
+	alerts, err := s.ListAlerts(ctx, storage.AlertFiring, 10)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	severities := []string{alerts[0].Severity, alerts[1].Severity}
+	assert.ElementsMatch(t, []string{"warning", "info"}, severities)
+}
+
+func TestEngine_ResultSavedDoesNotBlock(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	sender := &recordingSender{}
+	e := NewEngine(s, sender, time.Hour)
+
+	target, _, err := s.CreateTarget(ctx, "https://example.com", "")
+	require.NoError(t, err)
+	require.NoError(t, s.CreateRule(ctx, &storage.Rule{Name: "down", Kind: storage.RuleFailures, Severity: "critical",
+		Condition: storage.RuleCondition{Failures: 1, Of: 1}}))
+	require.NoError(t, s.SaveCheckResult(ctx, target.ID, &storage.CheckResult{CheckedAt: time.Now().UTC(), StatusCode: 503}))
+
+	// Nothing drains the queue yet; a full queue drops instead of waiting.
+	for i := 0; i < queueSize+10; i++ {
+		e.ResultSaved(target, &storage.CheckResult{StatusCode: 503})
+	}
+	assert.Len(t, e.queue, queueSize)
+
+	go e.Start()
+	defer e.Stop()
+	require.Eventually(t, func() bool {
+		alerts, err := s.ListAlerts(ctx, storage.AlertFiring, 10)
+		return err == nil && len(alerts) == 1 && len(e.queue) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+)
+
+var severities = map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+
+// parseDuration accepts time.ParseDuration syntax plus a whole-day suffix
+// such as "7d".
+func parseDuration(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", raw)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", raw)
+	}
+	return d, nil
+}
+
+func (h *Handler) PostRule(w http.ResponseWriter, r *http.Request) {
+	var body apitypes.CreateRuleRequest
+	if !decode(w, r, &body) {
+		return
+	}
+
+	rule := &storage.Rule{
+		Name:                body.Name,
+		Kind:                body.Kind,
+		Severity:            body.Severity,
+		TargetID:            body.TargetID,
+		ChannelIDs:          body.ChannelIDs,
+		EscalationChannelID: body.EscalationChannelID,
+		Condition: storage.RuleCondition{
+			Failures:   body.Condition.Failures,
+			Of:         body.Condition.Of,
+			Percentile: body.Condition.Percentile,
+			LatencyMs:  body.Condition.LatencyMs,
+		},
+	}
+	if rule.Severity == "" {
+		rule.Severity = "critical"
+	}
+	if rule.Name == "" {
+		problem.InvalidField(w, r, "name", "is required")
+		return
+	}
+	if !severities[rule.Severity] {
+		problem.InvalidField(w, r, "severity", "must be critical, error, warning or info")
+		return
+	}
+
+	var err error
+	durations := []struct {
+		field string
+		raw   string
+		dst   *time.Duration
+	}{
+		{"condition.window", body.Condition.Window, &rule.Condition.Window},
+		{"condition.within", body.Condition.Within, &rule.Condition.Within},
+		{"repeat_interval", body.RepeatInterval, &rule.RepeatInterval},
+		{"escalate_after", body.EscalateAfter, &rule.EscalateAfter},
+	}
+	for _, d := range durations {
+		if *d.dst, err = parseDuration(d.raw); err != nil || *d.dst < 0 {
+			problem.InvalidField(w, r, d.field, "must be a duration such as 5m or 7d")
+			return
+		}
+	}
+	if err := validateCondition(rule.Kind, rule.Condition); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if body.TargetID != "" && body.Selector != "" {
+		problem.InvalidField(w, r, "selector", "target_id and selector are mutually exclusive")
+		return
+	}
+	sel, err := labels.Parse(body.Selector)
+	if err != nil {
+		problem.InvalidField(w, r, "selector", err.Error())
+		return
+	}
+	rule.Selector = sel.String()
+
+	refs := append([]string{}, rule.ChannelIDs...)
+	if rule.EscalationChannelID != "" {
+		refs = append(refs, rule.EscalationChannelID)
+	}
+	for _, id := range refs {
+		if _, err := h.storage.GetChannel(r.Context(), id); errors.Is(err, storage.ErrNotFound) {
+			field := "channel_ids"
+			if id == rule.EscalationChannelID {
+				field = "escalation_channel_id"
+			}
+			problem.InvalidField(w, r, field, "channel "+id+" not found")
+			return
+		} else if err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+	}
+
+	if err := h.storage.CreateRule(r.Context(), rule); err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ruleJSON(rule))
+}
+
+func validateCondition(kind string, c storage.RuleCondition) error {
+	switch kind {
+	case storage.RuleFailures:
+		if c.Of < 1 || c.Failures < 1 || c.Failures > c.Of {
+			return problem.Invalid("condition", "failures rules need 1 <= failures <= of")
+		}
+	case storage.RuleLatency:
+		if c.Percentile <= 0 || c.Percentile > 100 || c.LatencyMs <= 0 || c.Window <= 0 {
+			return problem.Invalid("condition", "latency rules need percentile in (0, 100], latency_ms and window")
+		}
+	case storage.RuleCertExpiry:
+		if c.Within <= 0 {
+			return problem.Invalid("condition.within", "cert_expiry rules need within")
+		}
+	default:
+		return problem.Invalid("kind", "must be failures, latency or cert_expiry")
+	}
+	return nil
+}
+
+func (h *Handler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.storage.ListRules(r.Context())
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	resp := apitypes.RuleList{Items: []apitypes.Rule{}}
+	for _, rule := range rules {
+		resp.Items = append(resp.Items, ruleJSON(rule))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) GetRule(w http.ResponseWriter, r *http.Request, ruleID string) {
+	rule, err := h.storage.GetRule(r.Context(), ruleID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "rule not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ruleJSON(rule))
+}
+
+func (h *Handler) DeleteRule(w http.ResponseWriter, r *http.Request, ruleID string) {
+	err := h.storage.DeleteRule(r.Context(), ruleID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "rule not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ruleJSON(rule *storage.Rule) apitypes.Rule {
+	c := rule.Condition
+	var condition apitypes.RuleCondition
+	switch rule.Kind {
+	case storage.RuleFailures:
+		condition = apitypes.RuleCondition{Failures: c.Failures, Of: c.Of}
+	case storage.RuleLatency:
+		condition = apitypes.RuleCondition{Percentile: c.Percentile, LatencyMs: c.LatencyMs, Window: c.Window.String()}
+	case storage.RuleCertExpiry:
+		condition = apitypes.RuleCondition{Within: c.Within.String()}
+	}
+	channels := rule.ChannelIDs
+	if channels == nil {
+		channels = []string{}
+	}
+	return apitypes.Rule{
+		ID:                  rule.ID,
+		Name:                rule.Name,
+		Kind:                rule.Kind,
+		Severity:            rule.Severity,
+		Condition:           condition,
+		TargetID:            rule.TargetID,
+		Selector:            rule.Selector,
+		ChannelIDs:          channels,
+		RepeatInterval:      rule.RepeatInterval.String(),
+		EscalationChannelID: rule.EscalationChannelID,
+		EscalateAfter:       rule.EscalateAfter.String(),
+		CreatedAt:           timestamp(rule.CreatedAt),
+	}
+}
+
+func (h *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	status, ok := queryOneOf(w, r, "status", storage.AlertFiring, storage.AlertResolved)
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	alerts, err := h.storage.ListAlerts(r.Context(), status, limit)
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	resp := apitypes.AlertList{Items: []apitypes.Alert{}}
+	for _, a := range alerts {
+		resp.Items = append(resp.Items, alertJSON(a))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// AcknowledgeAlert stops repeat notifications and escalation for a firing
+// alert. The alert still resolves on its own once the rule stops matching.
+func (h *Handler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request, alertID string) {
+	var body apitypes.AcknowledgeAlertRequest
+	if r.ContentLength != 0 {
+		if !decode(w, r, &body) {
+			return
+		}
+	}
+
+	alert, err := h.storage.GetAlert(r.Context(), alertID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "alert not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	if alert.Status != storage.AlertFiring {
+		problem.Conflict(w, r, "alert is not firing")
+		return
+	}
+
+	if alert.AcknowledgedAt.IsZero() {
+		alert.AcknowledgedAt = time.Now().UTC()
+		alert.AcknowledgedBy = body.By
+		if err := h.storage.UpdateAlert(r.Context(), alert); err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alertJSON(alert))
+}
+
+func alertJSON(a *storage.Alert) apitypes.Alert {
+	return apitypes.Alert{
+		ID:             a.ID,
+		RuleID:         a.RuleID,
+		TargetID:       a.TargetID,
+		Severity:       a.Severity,
+		Status:         a.Status,
+		Summary:        a.Summary,
+		StartedAt:      timestamp(a.StartedAt),
+		Acknowledged:   !a.AcknowledgedAt.IsZero(),
+		AcknowledgedAt: optionalTime(a.AcknowledgedAt),
+		AcknowledgedBy: a.AcknowledgedBy,
+		EscalatedAt:    optionalTime(a.EscalatedAt),
+		ResolvedAt:     optionalTime(a.ResolvedAt),
+		LastNotifiedAt: timestamp(a.LastNotifiedAt),
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+)
+
+// PostAPIKey creates a key with the given scope. The key itself is only ever
+// returned in this response. Keys belong to the caller's tenant; operators
+// may create them for another tenant with "tenant_id".
+func (h *Handler) PostAPIKey(w http.ResponseWriter, r *http.Request) {
+	var body apitypes.CreateAPIKeyRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.Name == "" {
+		problem.InvalidField(w, r, "name", "is required")
+		return
+	}
+	if !auth.ValidScope(body.Scope) {
+		problem.InvalidField(w, r, "scope", "must be read, write or admin")
+		return
+	}
+
+	if body.TenantID != "" {
+		if key := auth.FromContext(r.Context()); key != nil && !auth.IsOperator(key) && body.TenantID != key.TenantID {
+			problem.Forbidden(w, r, "only operators may create keys for other tenants")
+			return
+		}
+		if _, err := h.storage.GetTenant(r.Context(), body.TenantID); errors.Is(err, storage.ErrNotFound) {
+			problem.InvalidField(w, r, "tenant_id", "tenant not found")
+			return
+		} else if err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+	}
+
+	plain, prefix, hash, err := auth.GenerateKey()
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	key := &storage.APIKey{TenantID: body.TenantID, Name: body.Name, Scope: body.Scope, Prefix: prefix, Hash: hash}
+	if err := h.storage.CreateAPIKey(r.Context(), key); err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	resp := apiKeyJSON(key)
+	resp.Key = plain
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.storage.ListAPIKeys(r.Context())
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	resp := apitypes.APIKeyList{Items: []apitypes.APIKey{}}
+	for _, key := range keys {
+		resp.Items = append(resp.Items, apiKeyJSON(key))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) DeleteAPIKey(w http.ResponseWriter, r *http.Request, keyID string) {
+	if keyID == auth.BootstrapKeyID {
+		problem.BadRequest(w, r, "the bootstrap key is configured by environment and cannot be revoked")
+		return
+	}
+	err := h.storage.RevokeAPIKey(r.Context(), keyID, time.Now().UTC())
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "API key not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func apiKeyJSON(key *storage.APIKey) apitypes.APIKey {
+	return apitypes.APIKey{
+		ID:         key.ID,
+		TenantID:   key.TenantID,
+		Name:       key.Name,
+		Scope:      key.Scope,
+		Prefix:     key.Prefix,
+		CreatedAt:  timestamp(key.CreatedAt),
+		LastUsedAt: optionalTime(key.LastUsedAt),
+		RevokedAt:  optionalTime(key.RevokedAt),
+	}
+}
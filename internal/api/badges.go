@@ -0,0 +1,283 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/badge"
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+)
+
+// Badges anyone may see can be stored by shared caches; badges that depend
+// on the caller's key, or show private targets, only by the client.
+const (
+	badgeCacheControl        = "public, max-age=60"
+	privateBadgeCacheControl = "private, max-age=60"
+)
+
+type badgeParams struct {
+	metric      string
+	label       string
+	window      time.Duration
+	windowLabel string
+}
+
+func parseBadgeParams(r *http.Request) (badgeParams, error) {
+	q := r.URL.Query()
+	p := badgeParams{metric: q.Get("metric"), label: q.Get("label"), window: 24 * time.Hour, windowLabel: "24h"}
+	if p.metric == "" {
+		p.metric = "status"
+	}
+	if p.metric != "status" && p.metric != "uptime" && p.metric != "latency" {
+		return p, problem.Invalid("metric", "must be status, uptime or latency")
+	}
+	if raw := q.Get("window"); raw != "" {
+		d, err := parseDuration(raw)
+		if err != nil || d <= 0 {
+			return p, problem.Invalid("window", "must be a positive duration such as 24h or 30d")
+		}
+		p.window, p.windowLabel = d, raw
+	}
+	if p.label == "" {
+		p.label = p.metric
+		if p.metric == "uptime" {
+			p.label = "uptime " + p.windowLabel
+		}
+	}
+	return p, nil
+}
+
+// targetState is "down" while the target has an open incident, otherwise
+// "up" or "maintenance" from its latest result, or "unknown" before the first
+// check. The latest result is returned alongside.
+func (h *Handler) targetState(ctx context.Context, t *storage.Target) (string, *storage.CheckResult, error) {
+	latest, err := h.storage.GetCheckResults(ctx, t.ID, time.Time{}, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	var last *storage.CheckResult
+	if len(latest) > 0 {
+		last = latest[0]
+	}
+	open, err := h.storage.GetOpenIncident(ctx, t.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	switch {
+	case open != nil:
+		return "down", last, nil
+	case last == nil:
+		return "unknown", nil, nil
+	case last.Maintenance:
+		return "maintenance", last, nil
+	}
+	return "up", last, nil
+}
+
+// TargetBadge renders an SVG badge for one target. ?metric= picks status
+// (default), uptime over ?window= (default 24h) or the last latency; ?label=
+// replaces the left-hand text. Badges for targets marked public can be
+// fetched without credentials.
+func (h *Handler) TargetBadge(w http.ResponseWriter, r *http.Request, targetID string) {
+	p, err := parseBadgeParams(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	t, ok := h.loadTarget(w, r, targetID)
+	if !ok {
+		return
+	}
+	if !t.Public && auth.FromContext(r.Context()) == nil {
+		// Anonymous callers must not learn which private targets exist.
+		problem.NotFound(w, r, "target not found")
+		return
+	}
+	h.badge(w, r, p, []*storage.Target{t})
+}
+
+// GroupBadge renders a badge summarizing all targets matching ?selector=.
+// Anonymous callers only see public targets of the tenant named by ?tenant=
+// (the default tenant when omitted).
+func (h *Handler) GroupBadge(w http.ResponseWriter, r *http.Request) {
+	p, err := parseBadgeParams(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	sel, err := labels.Parse(r.URL.Query().Get("selector"))
+	if err != nil {
+		problem.InvalidField(w, r, "selector", err.Error())
+		return
+	}
+	ctx := r.Context()
+	anonymous := auth.FromContext(ctx) == nil
+	if anonymous {
+		tenant := r.URL.Query().Get("tenant")
+		if tenant == "" {
+			tenant = storage.DefaultTenantID
+		}
+		ctx = storage.WithTenant(ctx, tenant)
+	}
+	all, _, err := h.storage.ListTargets(ctx, "", 10000, "")
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	var targets []*storage.Target
+	for _, t := range all {
+		if sel.Matches(t.Labels) && (t.Public || !anonymous) {
+			targets = append(targets, t)
+		}
+	}
+	h.badge(w, r, p, targets)
+}
+
+func (h *Handler) badge(w http.ResponseWriter, r *http.Request, p badgeParams, targets []*storage.Target) {
+	var message, color string
+	var err error
+	switch p.metric {
+	case "status":
+		message, color, err = h.statusBadge(r.Context(), targets)
+	case "uptime":
+		message, color, err = h.uptimeBadge(r.Context(), targets, p.window)
+	case "latency":
+		message, color, err = h.latencyBadge(r.Context(), targets)
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	cacheControl := badgeCacheControl
+	if auth.FromContext(r.Context()) != nil {
+		cacheControl = privateBadgeCacheControl
+	}
+	for _, t := range targets {
+		if !t.Public {
+			cacheControl = privateBadgeCacheControl
+		}
+	}
+
+	svg := badge.Render(p.label, message, color)
+	sum := sha256.Sum256(svg)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Vary", "Authorization")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(svg))
+}
+
+func (h *Handler) statusBadge(ctx context.Context, targets []*storage.Target) (string, string, error) {
+	if len(targets) == 0 {
+		return "no targets", badge.Grey, nil
+	}
+	if len(targets) == 1 {
+		state, _, err := h.targetState(ctx, targets[0])
+		if err != nil {
+			return "", "", err
+		}
+		switch state {
+		case "up":
+			return "up", badge.Green, nil
+		case "down":
+			return "down", badge.Red, nil
+		case "maintenance":
+			return "maintenance", badge.Blue, nil
+		}
+		return "unknown", badge.Grey, nil
+	}
+
+	var up, down, other int
+	for _, t := range targets {
+		state, _, err := h.targetState(ctx, t)
+		if err != nil {
+			return "", "", err
+		}
+		switch state {
+		case "up":
+			up++
+		case "down":
+			down++
+		default:
+			other++
+		}
+	}
+	switch {
+	case down == 0 && other == 0:
+		return "up", badge.Green, nil
+	case down == 0:
+		return fmt.Sprintf("%d of %d up", up, len(targets)), badge.Blue, nil
+	case up == 0 && other == 0:
+		return "down", badge.Red, nil
+	}
+	return fmt.Sprintf("%d of %d down", down, len(targets)), badge.Orange, nil
+}
+
+func (h *Handler) uptimeBadge(ctx context.Context, targets []*storage.Target, window time.Duration) (string, string, error) {
+	since := time.Now().UTC().Add(-window)
+	var checks, up int
+	for _, t := range targets {
+		c, u, err := h.storage.GetUptime(ctx, t.ID, since)
+		if err != nil {
+			return "", "", err
+		}
+		checks += c
+		up += u
+	}
+	if checks == 0 {
+		return "n/a", badge.Grey, nil
+	}
+	pct := 100 * float64(up) / float64(checks)
+	color := badge.Red
+	switch {
+	case pct >= 99.9:
+		color = badge.Green
+	case pct >= 99:
+		color = badge.YellowGreen
+	case pct >= 97:
+		color = badge.Yellow
+	case pct >= 95:
+		color = badge.Orange
+	}
+	message := strings.TrimRight(strings.TrimRight(strconv.FormatFloat(pct, 'f', 2, 64), "0"), ".")
+	return message + "%", color, nil
+}
+
+// latencyBadge shows the last latency, averaged over the group's targets
+// whose last check succeeded.
+func (h *Handler) latencyBadge(ctx context.Context, targets []*storage.Target) (string, string, error) {
+	var total, n int
+	for _, t := range targets {
+		_, last, err := h.targetState(ctx, t)
+		if err != nil {
+			return "", "", err
+		}
+		if last != nil && !last.Failed() {
+			total += last.LatencyMs
+			n++
+		}
+	}
+	if n == 0 {
+		return "n/a", badge.Grey, nil
+	}
+	ms := total / n
+	color := badge.Red
+	switch {
+	case ms < 300:
+		color = badge.Green
+	case ms < 1000:
+		color = badge.Yellow
+	}
+	return strconv.Itoa(ms) + "ms", color, nil
+}
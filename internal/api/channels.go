@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+)
+
+var channelTypes = map[string]bool{
+	storage.ChannelWebhook:   true,
+	storage.ChannelSlack:     true,
+	storage.ChannelTeams:     true,
+	storage.ChannelDiscord:   true,
+	storage.ChannelEmail:     true,
+	storage.ChannelPagerDuty: true,
+	storage.ChannelOpsgenie:  true,
+}
+
+func (h *Handler) PostChannel(w http.ResponseWriter, r *http.Request) {
+	var body apitypes.CreateChannelRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	if !channelTypes[body.Type] {
+		problem.InvalidField(w, r, "type", "must be one of webhook, slack, teams, discord, email, pagerduty, opsgenie")
+		return
+	}
+	if err := validateChannelURL(body.Type, body.URL); err != nil {
+		problem.InvalidField(w, r, "url", err.Error())
+		return
+	}
+	if (body.Type == storage.ChannelPagerDuty || body.Type == storage.ChannelOpsgenie) && body.Secret == "" {
+		problem.InvalidField(w, r, "secret", "is required for "+body.Type+" channels")
+		return
+	}
+	if body.TargetID != "" && body.Selector != "" {
+		problem.InvalidField(w, r, "selector", "target_id and selector are mutually exclusive")
+		return
+	}
+	sel, err := labels.Parse(body.Selector)
+	if err != nil {
+		problem.InvalidField(w, r, "selector", err.Error())
+		return
+	}
+	if body.TargetID != "" {
+		if _, err := h.storage.GetTarget(r.Context(), body.TargetID); errors.Is(err, storage.ErrNotFound) {
+			problem.InvalidField(w, r, "target_id", "target not found")
+			return
+		} else if err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+	}
+
+	ch := &storage.Channel{
+		Name:     body.Name,
+		Type:     body.Type,
+		URL:      body.URL,
+		Secret:   body.Secret,
+		TargetID: body.TargetID,
+		Selector: sel.String(),
+	}
+	if err := h.storage.CreateChannel(r.Context(), ch); err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(channelJSON(ch))
+}
+
+// validateChannelURL checks the destination for a channel type: a mailto:
+// list of addresses for email, an http(s) URL for everything else. PagerDuty
+// and Opsgenie fall back to their public endpoints when the URL is empty.
+func validateChannelURL(typ, raw string) error {
+	if raw == "" && (typ == storage.ChannelPagerDuty || typ == storage.ChannelOpsgenie) {
+		return nil
+	}
+	if typ == storage.ChannelEmail {
+		if !strings.HasPrefix(raw, "mailto:") {
+			return errors.New("email channel url must start with mailto:")
+		}
+		if _, err := mail.ParseAddressList(strings.TrimPrefix(raw, "mailto:")); err != nil {
+			return errors.New("invalid email address")
+		}
+		return nil
+	}
+	if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("invalid url")
+	}
+	return nil
+}
+
+func (h *Handler) ListChannels(w http.ResponseWriter, r *http.Request) {
+	channels, err := h.storage.ListChannels(r.Context())
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	resp := apitypes.ChannelList{Items: []apitypes.Channel{}}
+	for _, ch := range channels {
+		resp.Items = append(resp.Items, channelJSON(ch))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) GetChannel(w http.ResponseWriter, r *http.Request, channelID string) {
+	ch, ok := h.loadChannel(w, r, channelID)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channelJSON(ch))
+}
+
+func (h *Handler) DeleteChannel(w http.ResponseWriter, r *http.Request, channelID string) {
+	err := h.storage.DeleteChannel(r.Context(), channelID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "channel not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) TestChannel(w http.ResponseWriter, r *http.Request, channelID string) {
+	ch, ok := h.loadChannel(w, r, channelID)
+	if !ok {
+		return
+	}
+	if h.tester == nil {
+		problem.Error(w, r, http.StatusServiceUnavailable, problem.CodeUnavailable, "notifications are not enabled")
+		return
+	}
+
+	code, err := h.tester.TestChannel(r.Context(), ch)
+	resp := apitypes.ChannelTestResult{Delivered: err == nil, StatusCode: code}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		msg := err.Error()
+		resp.Error = &msg
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) loadChannel(w http.ResponseWriter, r *http.Request, channelID string) (*storage.Channel, bool) {
+	ch, err := h.storage.GetChannel(r.Context(), channelID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "channel not found")
+		return nil, false
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return nil, false
+	}
+	return ch, true
+}
+
+// channelJSON never includes the secret; callers only learn whether one is set.
+func channelJSON(ch *storage.Channel) apitypes.Channel {
+	return apitypes.Channel{
+		ID:        ch.ID,
+		Name:      ch.Name,
+		Type:      ch.Type,
+		URL:       ch.URL,
+		HasSecret: ch.Secret != "",
+		TargetID:  ch.TargetID,
+		Selector:  ch.Selector,
+		CreatedAt: timestamp(ch.CreatedAt),
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+)
+
+// maxBodyBytes caps request bodies; nothing the API accepts comes close.
+const maxBodyBytes = 1 << 20
+
+var errTrailingData = errors.New("trailing data after JSON object")
+
+const (
+	defaultLimit = 10
+	maxLimit     = 1000
+)
+
+// writeError answers with err when it is a *problem.Problem and with a
+// generic 500 otherwise.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var p *problem.Problem
+	if errors.As(err, &p) {
+		problem.Write(w, r, p)
+		return
+	}
+	problem.Internal(w, r, err)
+}
+
+// decode reads a single JSON object into v. Unknown fields, values of the
+// wrong type and trailing data are rejected with a 400 naming the field. It
+// reports whether decoding succeeded; on failure the response has been
+// written.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errTrailingData
+	}
+	if err != nil {
+		problem.Write(w, r, decodeProblem(err))
+		return false
+	}
+	return true
+}
+
+func decodeProblem(err error) *problem.Problem {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, io.EOF):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "request body is required")
+	case errors.As(err, &tooLarge):
+		return problem.New(http.StatusRequestEntityTooLarge, problem.CodeTooLarge,
+			fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit))
+	case errors.As(err, &syntaxErr):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidArgument,
+			fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "malformed JSON")
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "request body must be a JSON object")
+		}
+		return problem.Invalid(typeErr.Field, "must be "+jsonType(typeErr.Type))
+	case errors.As(err, &timeErr):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "times must be RFC 3339, e.g. 2024-01-02T15:04:05Z")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		return problem.Invalid(field, "unknown field")
+	case errors.Is(err, errTrailingData):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "request body must contain a single JSON object")
+	}
+	return problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "invalid request body")
+}
+
+// jsonType names a Go type the way API users know it.
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return "an RFC 3339 time"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	}
+	return "a valid " + t.Kind().String()
+}
+
+// queryLimit parses ?limit=, which must be between 1 and maxLimit and
+// defaults to defaultLimit. On failure the response has been written.
+func queryLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultLimit, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxLimit {
+		problem.InvalidField(w, r, "limit", fmt.Sprintf("must be an integer between 1 and %d", maxLimit))
+		return 0, false
+	}
+	return limit, true
+}
+
+// queryBool parses an optional boolean query parameter.
+func queryBool(w http.ResponseWriter, r *http.Request, name string) (bool, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, true
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		problem.InvalidField(w, r, name, "must be true or false")
+		return false, false
+	}
+	return v, true
+}
+
+// queryOneOf checks that an optional query parameter is one of values.
+func queryOneOf(w http.ResponseWriter, r *http.Request, name string, values ...string) (string, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return "", true
+	}
+	for _, v := range values {
+		if raw == v {
+			return raw, true
+		}
+	}
+	problem.InvalidField(w, r, name, "must be one of "+strings.Join(values, ", "))
+	return "", false
+}
+
+// loadTarget fetches a target, answering 404 when it does not exist.
+func (h *Handler) loadTarget(w http.ResponseWriter, r *http.Request, targetID string) (*storage.Target, bool) {
+	t, err := h.storage.GetTarget(r.Context(), targetID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "target not found")
+		return nil, false
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return nil, false
+	}
+	return t, true
+}
+
+// loadMutableTarget is loadTarget for changes, which targets managed by the
+// targets file refuse with a 409.
+func (h *Handler) loadMutableTarget(w http.ResponseWriter, r *http.Request, targetID string) (*storage.Target, bool) {
+	target, ok := h.loadTarget(w, r, targetID)
+	if ok && target.Managed {
+		problem.Conflict(w, r, "target is managed by the targets file; change it there")
+		return nil, false
+	}
+	return target, ok
+}
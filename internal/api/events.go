@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/events"
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+)
+
+// sseHeartbeat is how often an idle stream gets a comment line, which keeps
+// proxies from closing the connection.
+var sseHeartbeat = 15 * time.Second
+
+const sseBuffer = 256
+
+func (h *Handler) SetEvents(b *events.Broker) {
+	h.events = b
+}
+
+// eventFilter matches events for one target, a label selector and/or a set
+// of event types. Empty criteria match everything.
+func eventFilter(targetID, selector string, types []string) (func(*events.Event) bool, error) {
+	if targetID != "" && selector != "" {
+		return nil, problem.Invalid("selector", "target_id and selector are mutually exclusive")
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, problem.Invalid("selector", err.Error())
+	}
+	allowed := map[string]bool{}
+	for _, typ := range types {
+		switch typ {
+		case events.TypeResult, events.TypeState, events.TypeFlapping:
+			allowed[typ] = true
+		default:
+			return nil, problem.Invalid("types", fmt.Sprintf("unknown event type %q", typ))
+		}
+	}
+	return func(ev *events.Event) bool {
+		if len(allowed) > 0 && !allowed[ev.Type] {
+			return false
+		}
+		if targetID != "" {
+			return ev.Target.ID == targetID
+		}
+		return sel.Matches(ev.Target.Labels)
+	}, nil
+}
+
+// ownEvents narrows f to events for targets of the request's tenant.
+func ownEvents(ctx context.Context, f func(*events.Event) bool) func(*events.Event) bool {
+	tenant := storage.TenantFromContext(ctx)
+	if tenant == "" {
+		return f
+	}
+	return func(ev *events.Event) bool {
+		return ev.Target.TenantID == tenant && f(ev)
+	}
+}
+
+func eventJSON(ev *events.Event) apitypes.Event {
+	flapping, pct := ev.Flapping, ev.PercentChange
+	resp := apitypes.Event{
+		TargetID: ev.Target.ID,
+		URL:      ev.Target.URL,
+		Labels:   ev.Target.Labels,
+		At:       timestamp(ev.At),
+	}
+	switch ev.Type {
+	case events.TypeResult:
+		result := resultJSON(ev.Result)
+		resp.Result = &result
+	case events.TypeState:
+		resp.From = ev.From
+		resp.To = ev.To
+		resp.Flapping = &flapping
+		if ev.Result != nil {
+			result := resultJSON(ev.Result)
+			resp.Result = &result
+		}
+		if ev.Incident != nil {
+			inc := incidentJSON(ev.Incident)
+			resp.Incident = &inc
+		}
+	case events.TypeFlapping:
+		resp.Flapping = &flapping
+		resp.State = ev.To
+		resp.PercentStateChange = &pct
+	}
+	return resp
+}
+
+// Events streams check results and state changes as server-sent events.
+// ?target_id= or ?selector= narrow the stream, ?types= picks event types.
+// A client reconnecting with Last-Event-ID receives the buffered events it
+// missed before the live stream resumes.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	if h.events == nil {
+		problem.Error(w, r, http.StatusServiceUnavailable, problem.CodeUnavailable, "event stream not available")
+		return
+	}
+
+	q := r.URL.Query()
+	var types []string
+	if raw := q.Get("types"); raw != "" {
+		types = strings.Split(raw, ",")
+	}
+	filter, err := eventFilter(q.Get("target_id"), q.Get("selector"), types)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var lastID uint64
+	rawLast := r.Header.Get("Last-Event-ID")
+	if rawLast == "" {
+		rawLast = q.Get("last_event_id")
+	}
+	if rawLast != "" {
+		lastID, err = strconv.ParseUint(rawLast, 10, 64)
+		if err != nil {
+			problem.InvalidField(w, r, "Last-Event-ID", "must be an event id")
+			return
+		}
+	}
+
+	replay, ch, cancel := h.events.Subscribe(ownEvents(r.Context(), filter), lastID, sseBuffer)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for _, ev := range replay {
+		if writeEvent(w, ev) != nil {
+			return
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if writeEvent(w, ev) != nil || rc.Flush() != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev *events.Event) error {
+	data, err := json.Marshal(eventJSON(ev))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+	return err
+}
@@ -1,96 +1,235 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
-	"net/url"
-	"strconv"
-	"strings"
 	"time"
 
+	"github.com/AlanZeng-Coder/linkwatch/internal/events"
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/ratelimit"
 	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/urls"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
 )
 
 type Handler struct {
-	storage storage.Storage
+	storage     storage.Storage
+	tester      ChannelTester
+	cycles      CycleReporter
+	maxCycleAge time.Duration
+	events      *events.Broker
+
+	readLimit, writeLimit, publicLimit *ratelimit.Limiter
+	instrument                         Instrumenter
+	statusPages                        StatusPages
+	metrics                            http.Handler
+}
+
+type ChannelTester interface {
+	TestChannel(ctx context.Context, ch *storage.Channel) (int, error)
 }
 
 func NewHandler(s storage.Storage) *Handler {
 	return &Handler{storage: s}
 }
 
+func (h *Handler) SetChannelTester(t ChannelTester) {
+	h.tester = t
+}
+
 func (h *Handler) PostTarget(w http.ResponseWriter, r *http.Request) {
-	var body struct{ URL string }
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var body apitypes.CreateTargetRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	if err := labels.Validate(body.Labels); err != nil {
+		problem.InvalidField(w, r, "labels", err.Error())
+		return
+	}
+	interval, err := h.targetInterval(r.Context(), body.Interval)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	canonicalURL, err := canonicalizeURL(body.URL)
+	canonicalURL, err := urls.Canonicalize(body.URL)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.InvalidField(w, r, "url", "must be an http or https URL")
 		return
 	}
 
 	idempKey := r.Header.Get("Idempotency-Key")
 
 	target, isNew, err := h.storage.CreateTarget(r.Context(), canonicalURL, idempKey)
+	if errors.Is(err, storage.ErrQuotaExceeded) {
+		problem.Error(w, r, http.StatusForbidden, problem.CodeQuotaExceeded, "tenant has reached its target quota")
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Internal(w, r, err)
 		return
 	}
+	if isNew && len(body.Labels) > 0 {
+		target, err = h.storage.UpdateTargetLabels(r.Context(), target.ID, body.Labels)
+		if err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+	}
+	if isNew && body.Public {
+		target, err = h.storage.SetTargetPublic(r.Context(), target.ID, true)
+		if err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+	}
+	if isNew && interval > 0 {
+		target, err = h.storage.SetTargetInterval(r.Context(), target.ID, interval)
+		if err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	if isNew {
 		w.WriteHeader(http.StatusCreated) // 201
 	} else {
 		w.WriteHeader(http.StatusOK) // 200
 	}
-	json.NewEncoder(w).Encode(map[string]interface{}{"id": target.ID, "url": target.URL, "created_at": target.CreatedAt.Format(time.RFC3339)})
+	json.NewEncoder(w).Encode(targetJSON(target))
+}
+
+func (h *Handler) GetTarget(w http.ResponseWriter, r *http.Request, targetID string) {
+	target, ok := h.loadTarget(w, r, targetID)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(targetJSON(target))
 }
 
-func canonicalizeURL(raw string) (string, error) {
-	u, err := url.Parse(raw)
+// PatchTarget replaces the target's labels and/or sets whether it is public
+// or paused and its check interval ("" restores the default). Labels are
+// left alone when only "public", "paused" or "interval" is sent.
+func (h *Handler) PatchTarget(w http.ResponseWriter, r *http.Request, targetID string) {
+	var body apitypes.UpdateTargetRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	if err := labels.Validate(body.Labels); err != nil {
+		problem.InvalidField(w, r, "labels", err.Error())
+		return
+	}
+	var interval time.Duration
+	if body.Interval != nil {
+		var err error
+		if interval, err = h.targetInterval(r.Context(), *body.Interval); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	if _, ok := h.loadMutableTarget(w, r, targetID); !ok {
+		return
+	}
+
+	var target *storage.Target
+	var err error
+	if body.Labels != nil || (body.Public == nil && body.Paused == nil && body.Interval == nil) {
+		target, err = h.storage.UpdateTargetLabels(r.Context(), targetID, body.Labels)
+	}
+	if err == nil && body.Public != nil {
+		target, err = h.storage.SetTargetPublic(r.Context(), targetID, *body.Public)
+	}
+	if err == nil && body.Paused != nil {
+		target, err = h.storage.SetTargetPaused(r.Context(), targetID, *body.Paused)
+	}
+	if err == nil && body.Interval != nil {
+		target, err = h.storage.SetTargetInterval(r.Context(), targetID, interval)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "target not found")
+		return
+	}
 	if err != nil {
-		return "", err
+		problem.Internal(w, r, err)
+		return
 	}
-	if u.Scheme != "http" && u.Scheme != "https" {
-		return "", errors.New("invalid scheme")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(targetJSON(target))
+}
+
+func targetJSON(t *storage.Target) apitypes.Target {
+	lbls := t.Labels
+	if lbls == nil {
+		lbls = map[string]string{}
 	}
-	u.Scheme = strings.ToLower(u.Scheme)
-	u.Host = strings.ToLower(u.Host)
-	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
-		hostParts := strings.Split(u.Host, ":")
-		u.Host = hostParts[0]
+	resp := apitypes.Target{ID: t.ID, URL: t.URL, Labels: lbls, Flapping: t.Flapping, Public: t.Public, Paused: t.Paused, Managed: t.Managed, CreatedAt: timestamp(t.CreatedAt)}
+	if t.Interval > 0 {
+		resp.Interval = t.Interval.String()
 	}
-	u.Path = strings.TrimRight(u.Path, "/")
+	return resp
+}
+
+// timestamp drops the sub-second part, which the API has never exposed.
+func timestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
 
-	u.Fragment = ""
-	return u.String(), nil
+// optionalTime is nil for the zero time, which encodes as null.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	ts := timestamp(t)
+	return &ts
+}
+
+// DeleteTarget removes a target with its results and incidents.
+func (h *Handler) DeleteTarget(w http.ResponseWriter, r *http.Request, targetID string) {
+	if _, ok := h.loadMutableTarget(w, r, targetID); !ok {
+		return
+	}
+	err := h.storage.DeleteTarget(r.Context(), targetID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "target not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *Handler) ListTargets(w http.ResponseWriter, r *http.Request) {
 	host := r.URL.Query().Get("host")
-	limitStr := r.URL.Query().Get("limit")
-	limit := 10
-	if limitStr != "" {
-		limit, _ = strconv.Atoi(limitStr)
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
 	}
 	token := r.URL.Query().Get("page_token")
 
 	items, next, err := h.storage.ListTargets(r.Context(), host, limit, token)
+	if errors.Is(err, storage.ErrInvalidPageToken) {
+		problem.InvalidField(w, r, "page_token", "not a token returned by this API")
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Internal(w, r, err)
 		return
 	}
 
-	var respItems []map[string]string
+	resp := apitypes.TargetList{Items: []apitypes.Target{}, NextPageToken: next}
 	for _, item := range items {
-		respItems = append(respItems, map[string]string{"id": item.ID, "url": item.URL})
+		resp.Items = append(resp.Items, targetJSON(item))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"items": respItems, "next_page_token": next})
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *Handler) GetResults(w http.ResponseWriter, r *http.Request, targetID string) {
@@ -100,37 +239,139 @@ func (h *Handler) GetResults(w http.ResponseWriter, r *http.Request, targetID st
 		var err error
 		since, err = time.Parse(time.RFC3339, sinceStr)
 		if err != nil {
-			http.Error(w, "invalid since", http.StatusBadRequest)
+			problem.InvalidField(w, r, "since", "must be an RFC 3339 time")
 			return
 		}
 	}
-	limitStr := r.URL.Query().Get("limit")
-	limit := 10
-	if limitStr != "" {
-		limit, _ = strconv.Atoi(limitStr)
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := h.loadTarget(w, r, targetID); !ok {
+		return
 	}
 
 	results, err := h.storage.GetCheckResults(r.Context(), targetID, since, limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Internal(w, r, err)
 		return
 	}
 
-	var respItems []map[string]interface{}
+	resp := apitypes.ResultList{Items: []apitypes.CheckResult{}}
 	for _, res := range results {
-		item := map[string]interface{}{
-			"checked_at":  res.CheckedAt.Format(time.RFC3339),
-			"status_code": res.StatusCode,
-			"latency_ms":  res.LatencyMs,
-		}
-		if res.Error != "" {
-			item["error"] = res.Error
-		} else {
-			item["error"] = nil
+		resp.Items = append(resp.Items, resultJSON(res))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func resultJSON(res *storage.CheckResult) apitypes.CheckResult {
+	item := apitypes.CheckResult{
+		CheckedAt:     timestamp(res.CheckedAt),
+		StatusCode:    res.StatusCode,
+		LatencyMs:     res.LatencyMs,
+		CertExpiresAt: optionalTime(res.CertExpiresAt),
+		Maintenance:   res.Maintenance,
+	}
+	if res.Error != "" {
+		item.Error = &res.Error
+	}
+	return item
+}
+
+func (h *Handler) ListIncidents(w http.ResponseWriter, r *http.Request) {
+	h.listIncidents(w, r, "")
+}
+
+func (h *Handler) GetTargetIncidents(w http.ResponseWriter, r *http.Request, targetID string) {
+	h.listIncidents(w, r, targetID)
+}
+
+func (h *Handler) listIncidents(w http.ResponseWriter, r *http.Request, targetID string) {
+	openOnly, ok := queryBool(w, r, "open")
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+	if targetID != "" {
+		if _, ok := h.loadTarget(w, r, targetID); !ok {
+			return
 		}
-		respItems = append(respItems, item)
+	}
+
+	incidents, err := h.storage.ListIncidents(r.Context(), targetID, openOnly, limit)
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	resp := apitypes.IncidentList{Items: []apitypes.Incident{}}
+	for _, inc := range incidents {
+		resp.Items = append(resp.Items, incidentJSON(inc))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func incidentJSON(inc *storage.Incident) apitypes.Incident {
+	item := apitypes.Incident{
+		ID:             inc.ID,
+		TargetID:       inc.TargetID,
+		StartedAt:      timestamp(inc.StartedAt),
+		FirstError:     inc.FirstError,
+		Open:           inc.Open(),
+		TriggerResults: []apitypes.CheckResult{},
+	}
+	for _, res := range inc.TriggerResults {
+		item.TriggerResults = append(item.TriggerResults, resultJSON(res))
+	}
+	if !inc.Open() {
+		ms := inc.Duration.Milliseconds()
+		item.EndedAt = optionalTime(inc.EndedAt)
+		item.DurationMs = &ms
+	}
+	return item
+}
+
+func (h *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	targetID := r.URL.Query().Get("target_id")
+	status, ok := queryOneOf(w, r, "status", storage.NotificationPending, storage.NotificationDelivered, storage.NotificationFailed)
+	if !ok {
+		return
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := h.storage.ListNotifications(r.Context(), targetID, status, limit)
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	resp := apitypes.NotificationList{Items: []apitypes.Notification{}}
+	for _, n := range items {
+		resp.Items = append(resp.Items, apitypes.Notification{
+			ID:             n.ID,
+			TargetID:       n.TargetID,
+			Event:          n.Event,
+			URL:            n.URL,
+			Status:         n.Status,
+			Attempts:       n.Attempts,
+			LastStatusCode: n.LastStatusCode,
+			LastError:      n.LastError,
+			CreatedAt:      timestamp(n.CreatedAt),
+			NextAttemptAt:  timestamp(n.NextAttemptAt),
+			DeliveredAt:    optionalTime(n.DeliveredAt),
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"items": respItems})
+	json.NewEncoder(w).Encode(resp)
 }
@@ -1,42 +1,32 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/checker"
+	"github.com/AlanZeng-Coder/linkwatch/internal/events"
+	"github.com/AlanZeng-Coder/linkwatch/internal/statuspage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
 	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+	"github.com/gorilla/websocket"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestCanonicalizeURL(t *testing.T) {
-	tests := []struct {
-		raw      string
-		expected string
-		err      bool
-	}{
-		{"https://EXAMPLE.com/", "https://example.com", false},
-		{"HTTP://example.com:80/path/", "http://example.com/path", false},
-		{"https://example.com:443", "https://example.com", false},
-		{"ftp://invalid.com", "", true},
-		{"https://example.com#fragment", "https://example.com", false},
-	}
-
-	for _, tt := range tests {
-		res, err := canonicalizeURL(tt.raw)
-		if tt.err {
-			assert.Error(t, err)
-		} else {
-			assert.NoError(t, err)
-			assert.Equal(t, tt.expected, res)
-		}
-	}
-}
-
 func TestPostTarget(t *testing.T) {
 	s := testutil.SetupTestDB(t)
 	h := NewHandler(s)
@@ -82,4 +72,865 @@ func TestListTargets(t *testing.T) {
 	items := resp["items"].([]interface{})
 	assert.Len(t, items, 1)
 	assert.NotEmpty(t, resp["next_page_token"])
+	first := items[0].(map[string]interface{})["url"]
+
+	w = httptest.NewRecorder()
+	h.ListTargets(w, httptest.NewRequest("GET", "/v1/targets?limit=1&page_token="+url.QueryEscape(resp["next_page_token"].(string)), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	resp = nil
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	items = resp["items"].([]interface{})
+	require.Len(t, items, 1)
+	assert.NotEqual(t, first, items[0].(map[string]interface{})["url"])
+	assert.Empty(t, resp["next_page_token"])
+}
+
+func TestValidation_ProblemDetails(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	target, _, err := s.CreateTarget(context.Background(), "https://example.com", "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		call   func(http.ResponseWriter, *http.Request)
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{"limit not a number", h.ListTargets, "GET", "/v1/targets?limit=ten", "", 400, "invalid_argument", "limit"},
+		{"limit zero", h.ListTargets, "GET", "/v1/targets?limit=0", "", 400, "invalid_argument", "limit"},
+		{"limit too large", h.ListIncidents, "GET", "/v1/incidents?limit=100000", "", 400, "invalid_argument", "limit"},
+		{"bad page token", h.ListTargets, "GET", "/v1/targets?page_token=bm9wZQ==", "", 400, "invalid_argument", "page_token"},
+		{"bad open", h.ListIncidents, "GET", "/v1/incidents?open=maybe", "", 400, "invalid_argument", "open"},
+		{"bad alert status", h.ListAlerts, "GET", "/v1/alerts?status=open", "", 400, "invalid_argument", "status"},
+		{"unknown field", h.PostTarget, "POST", "/v1/targets", `{"url": "https://a.example.com", "lables": {}}`, 400, "invalid_argument", "lables"},
+		{"wrong type", h.PostTarget, "POST", "/v1/targets", `{"url": "https://a.example.com", "public": "yes"}`, 400, "invalid_argument", "public"},
+		{"nested wrong type", h.PostRule, "POST", "/v1/rules", `{"name": "r", "kind": "failures", "condition": {"of": "3"}}`, 400, "invalid_argument", "condition.of"},
+		{"bad url", h.PostTarget, "POST", "/v1/targets", `{"url": "ftp://example.com"}`, 400, "invalid_argument", "url"},
+		{"malformed JSON", h.PostTarget, "POST", "/v1/targets", `{"url": `, 400, "invalid_argument", ""},
+		{"trailing data", h.PostTarget, "POST", "/v1/targets", `{"url": "https://a.example.com"} {}`, 400, "invalid_argument", ""},
+		{"empty body", h.PostChannel, "POST", "/v1/channels", ``, 400, "invalid_argument", ""},
+		{"body too large", h.PostTarget, "POST", "/v1/targets", `{"url": "` + strings.Repeat("a", maxBodyBytes) + `"}`, 413, "request_too_large", ""},
+		{"results of unknown target", func(w http.ResponseWriter, r *http.Request) { h.GetResults(w, r, "t_missing") }, "GET", "/v1/targets/t_missing/results", "", 404, "not_found", ""},
+		{"incidents of unknown target", func(w http.ResponseWriter, r *http.Request) { h.GetTargetIncidents(w, r, "t_missing") }, "GET", "/v1/targets/t_missing/incidents", "", 404, "not_found", ""},
+		{"bad since", func(w http.ResponseWriter, r *http.Request) { h.GetResults(w, r, target.ID) }, "GET", "/v1/targets/x/results?since=yesterday", "", 400, "invalid_argument", "since"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.call(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			var p struct {
+				Status int
+				Code   string
+				Detail string
+				Errors []struct{ Field, Message string }
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, tt.code, p.Code)
+			assert.NotEmpty(t, p.Detail)
+			if tt.field != "" {
+				require.Len(t, p.Errors, 1)
+				assert.Equal(t, tt.field, p.Errors[0].Field)
+			}
+		})
+	}
+}
+
+type stubTester struct {
+	tested []string
+}
+
+func (s *stubTester) TestChannel(ctx context.Context, ch *storage.Channel) (int, error) {
+	s.tested = append(s.tested, ch.ID)
+	return http.StatusOK, nil
+}
+
+func TestChannels(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	tester := &stubTester{}
+	h.SetChannelTester(tester)
+
+	body := bytes.NewBufferString(`{"name": "ops", "type": "slack", "url": "https://hooks.slack.com/services/x", "selector": "env=prod"}`)
+	w := httptest.NewRecorder()
+	h.PostChannel(w, httptest.NewRequest("POST", "/v1/channels", body))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var ch map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &ch)
+	assert.Equal(t, "env=prod", ch["selector"])
+
+	w = httptest.NewRecorder()
+	h.TestChannel(w, httptest.NewRequest("POST", "/v1/channels/x/test", nil), ch["id"].(string))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{ch["id"].(string)}, tester.tested)
+
+	w = httptest.NewRecorder()
+	h.TestChannel(w, httptest.NewRequest("POST", "/v1/channels/missing/test", nil), "missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	body = bytes.NewBufferString(`{"type": "pager", "url": "https://example.com"}`)
+	w = httptest.NewRecorder()
+	h.PostChannel(w, httptest.NewRequest("POST", "/v1/channels", body))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRulesAndAcknowledge(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+
+	body := bytes.NewBufferString(`{"name": "cert", "kind": "cert_expiry", "condition": {"within": "7d"}}`)
+	w := httptest.NewRecorder()
+	h.PostRule(w, httptest.NewRequest("POST", "/v1/rules", body))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var rule map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &rule)
+	assert.Equal(t, "critical", rule["severity"])
+	assert.Equal(t, "168h0m0s", rule["condition"].(map[string]interface{})["within"])
+
+	body = bytes.NewBufferString(`{"name": "bad", "kind": "failures", "condition": {"failures": 6, "of": 5}}`)
+	w = httptest.NewRecorder()
+	h.PostRule(w, httptest.NewRequest("POST", "/v1/rules", body))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	alert := &storage.Alert{RuleID: rule["id"].(string), TargetID: "t_1", Severity: "critical", Summary: "x", StartedAt: time.Now(), LastNotifiedAt: time.Now()}
+	assert.NoError(t, s.CreateAlert(context.Background(), alert))
+
+	w = httptest.NewRecorder()
+	h.AcknowledgeAlert(w, httptest.NewRequest("POST", "/v1/alerts/x/ack", bytes.NewBufferString(`{"by": "oncall"}`)), alert.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var acked map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &acked)
+	assert.Equal(t, true, acked["acknowledged"])
+	assert.Equal(t, "oncall", acked["acknowledged_by"])
+}
+
+type fixedCycle time.Time
+
+func (f fixedCycle) LastCycle() time.Time { return time.Time(f) }
+
+func TestReadyz(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+
+	readyz := func() (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		h.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	h.SetReadiness(fixedCycle(time.Now().Add(-10*time.Second)), time.Minute)
+	code, resp := readyz()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp["status"])
+	components := resp["components"].(map[string]interface{})
+	assert.Equal(t, "ok", components["database"].(map[string]interface{})["status"])
+	assert.Equal(t, "ok", components["checker"].(map[string]interface{})["status"])
+
+	h.SetReadiness(fixedCycle(time.Now().Add(-2*time.Minute)), time.Minute)
+	code, resp = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	components = resp["components"].(map[string]interface{})
+	assert.Equal(t, "error", components["checker"].(map[string]interface{})["status"])
+
+	h.SetReadiness(fixedCycle(time.Now()), time.Minute)
+	s.Close()
+	code, resp = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	components = resp["components"].(map[string]interface{})
+	assert.Equal(t, "error", components["database"].(map[string]interface{})["status"])
+}
+
+func TestEvents_StreamAndResume(t *testing.T) {
+	h := NewHandler(testutil.SetupTestDB(t))
+	broker := events.NewBroker(10)
+	h.SetEvents(broker)
+	srv := httptest.NewServer(http.HandlerFunc(h.Events))
+	defer srv.Close()
+
+	prod := &storage.Target{ID: "t_prod", URL: "https://prod.example.com", Labels: map[string]string{"env": "prod"}}
+	dev := &storage.Target{ID: "t_dev", URL: "https://dev.example.com", Labels: map[string]string{"env": "dev"}}
+	result := func(code int) *storage.CheckResult {
+		return &storage.CheckResult{CheckedAt: time.Now(), StatusCode: code, LatencyMs: 12}
+	}
+	broker.ResultSaved(prod, result(200))
+
+	req, _ := http.NewRequest("GET", srv.URL+"?selector=env%3Dprod", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	broker.ResultSaved(dev, result(200))
+	broker.StateChanged(checker.Transition{Target: prod, From: checker.StateUp, To: checker.StateDown, At: time.Now()})
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() map[string]string {
+		ev := map[string]string{}
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				return ev
+			}
+			if k, v, ok := strings.Cut(line, ": "); ok {
+				ev[k] = v
+			}
+		}
+	}
+	ev := readEvent()
+	assert.Equal(t, "3", ev["id"])
+	assert.Equal(t, "state", ev["event"])
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(ev["data"]), &data))
+	assert.Equal(t, "t_prod", data["target_id"])
+	assert.Equal(t, "down", data["to"])
+
+	req, _ = http.NewRequest("GET", srv.URL+"?target_id=t_dev", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resume, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resume.Body.Close()
+	reader = bufio.NewReader(resume.Body)
+	ev = readEvent()
+	assert.Equal(t, "2", ev["id"])
+	assert.Equal(t, "result", ev["event"])
+
+	w := httptest.NewRecorder()
+	h.Events(w, httptest.NewRequest("GET", "/v1/events?target_id=a&selector=env%3Dprod", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWebSocket_Subscriptions(t *testing.T) {
+	h := NewHandler(testutil.SetupTestDB(t))
+	broker := events.NewBroker(0)
+	h.SetEvents(broker)
+	srv := httptest.NewServer(http.HandlerFunc(h.WebSocket))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	read := func() map[string]interface{} {
+		var msg map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&msg))
+		return msg
+	}
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "subscribe", "target_ids": []string{"t_1"}, "selectors": []string{"env = prod"}}))
+	msg := read()
+	assert.Equal(t, "subscriptions", msg["type"])
+	assert.Equal(t, []interface{}{"t_1"}, msg["target_ids"])
+	assert.Equal(t, []interface{}{"env=prod"}, msg["selectors"])
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "subscribe", "selectors": []string{"env in ("}}))
+	assert.Equal(t, "error", read()["type"])
+
+	t1 := &storage.Target{ID: "t_1", URL: "https://a.example.com"}
+	t2 := &storage.Target{ID: "t_2", URL: "https://b.example.com", Labels: map[string]string{"env": "dev"}}
+	t3 := &storage.Target{ID: "t_3", URL: "https://c.example.com", Labels: map[string]string{"env": "prod"}}
+	broker.ResultSaved(t2, &storage.CheckResult{CheckedAt: time.Now(), StatusCode: 200})
+	broker.ResultSaved(t1, &storage.CheckResult{CheckedAt: time.Now(), StatusCode: 200})
+	broker.StateChanged(checker.Transition{Target: t3, From: checker.StateUp, To: checker.StateDown, At: time.Now(),
+		Incident: &storage.Incident{ID: "inc_1", TargetID: "t_3", StartedAt: time.Now()}})
+
+	msg = read()
+	assert.Equal(t, "result", msg["type"])
+	assert.Equal(t, "t_1", msg["target_id"])
+	msg = read()
+	assert.Equal(t, "incident", msg["type"])
+	assert.Equal(t, "t_3", msg["target_id"])
+	assert.Equal(t, "inc_1", msg["incident"].(map[string]interface{})["id"])
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "unsubscribe", "target_ids": []string{"t_1"}}))
+	msg = read()
+	assert.Equal(t, []interface{}{}, msg["target_ids"])
+
+	broker.ResultSaved(t1, &storage.CheckResult{CheckedAt: time.Now(), StatusCode: 200})
+	broker.ResultSaved(t3, &storage.CheckResult{CheckedAt: time.Now(), StatusCode: 200})
+	assert.Equal(t, "t_3", read()["target_id"])
+
+	broker.Close()
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseGoingAway, closeErr.Code)
+}
+
+func TestStatusPages(t *testing.T) {
+	h := NewHandler(testutil.SetupTestDB(t))
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.PostStatusPage(w, httptest.NewRequest("POST", "/v1/status-pages", bytes.NewBufferString(body)))
+		return w
+	}
+
+	w := post(`{"slug": "acme", "title": "Acme", "groups": [{"name": "API", "selector": "tier = api"}]}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var page map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Equal(t, "/status/acme", page["path"])
+	assert.Equal(t, "tier=api", page["groups"].([]interface{})[0].(map[string]interface{})["selector"])
+
+	assert.Equal(t, http.StatusConflict, post(`{"slug": "acme", "title": "Again", "groups": [{"name": "All"}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"slug": "Not A Slug", "title": "x", "groups": [{"name": "All"}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"slug": "empty", "title": "x"}`).Code)
+
+	w = httptest.NewRecorder()
+	h.DeleteStatusPage(w, httptest.NewRequest("DELETE", "/v1/status-pages/x", nil), page["id"].(string))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestBadges(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	ctx := context.Background()
+
+	api, _, _ := s.CreateTarget(ctx, "https://api.example.com", "")
+	s.UpdateTargetLabels(ctx, api.ID, map[string]string{"tier": "api"})
+	web, _, _ := s.CreateTarget(ctx, "https://web.example.com", "")
+	s.UpdateTargetLabels(ctx, web.ID, map[string]string{"tier": "api"})
+	s.SetTargetPublic(ctx, api.ID, true)
+	s.SetTargetPublic(ctx, web.ID, true)
+	now := time.Now().UTC()
+	s.SaveCheckResult(ctx, api.ID, &storage.CheckResult{CheckedAt: now.Add(-2 * time.Minute), StatusCode: 200, LatencyMs: 120})
+	s.SaveCheckResult(ctx, api.ID, &storage.CheckResult{CheckedAt: now.Add(-time.Minute), StatusCode: 500, LatencyMs: 80})
+	s.SaveCheckResult(ctx, web.ID, &storage.CheckResult{CheckedAt: now.Add(-time.Minute), StatusCode: 200, LatencyMs: 400})
+	s.CreateIncident(ctx, &storage.Incident{TargetID: api.ID, StartedAt: now})
+
+	get := func(target, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/v1/targets/badge.svg"+query, nil)
+		if target != "" {
+			h.TargetBadge(w, r, target)
+		} else {
+			h.GroupBadge(w, r)
+		}
+		return w
+	}
+
+	w := get(api.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, badgeCacheControl, w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Authorization", w.Header().Get("Vary"))
+	assert.Contains(t, w.Body.String(), "<title>status: down</title>")
+
+	assert.Contains(t, get(api.ID, "?metric=uptime&window=1h").Body.String(), "<title>uptime 1h: 50%</title>")
+	assert.Contains(t, get(web.ID, "?metric=latency&label=api").Body.String(), "<title>api: 400ms</title>")
+	assert.Contains(t, get(api.ID, "?metric=latency").Body.String(), "<title>latency: n/a</title>")
+
+	assert.Contains(t, get("", "?selector=tier%3Dapi").Body.String(), "<title>status: 1 of 2 down</title>")
+	assert.Contains(t, get("", "?selector=tier%3Dapi&metric=uptime").Body.String(), "66.67%")
+	assert.Contains(t, get("", "?selector=tier%3Ddb").Body.String(), "no targets")
+
+	assert.Equal(t, http.StatusNotFound, get("t_missing", "").Code)
+	assert.Equal(t, http.StatusBadRequest, get(api.ID, "?metric=bogus").Code)
+
+	// Shared caches must not hand badges seen with a key, or of private
+	// targets, to anonymous callers.
+	keyed := httptest.NewRequest("GET", "/v1/targets/badge.svg?selector=tier%3Dapi", nil)
+	keyed = keyed.WithContext(auth.WithKey(keyed.Context(), &storage.APIKey{Scope: auth.ScopeRead}))
+	w2 := httptest.NewRecorder()
+	h.GroupBadge(w2, keyed)
+	assert.Equal(t, privateBadgeCacheControl, w2.Header().Get("Cache-Control"))
+	s.SetTargetPublic(ctx, web.ID, false)
+	w2 = httptest.NewRecorder()
+	h.TargetBadge(w2, httptest.NewRequest("GET", "/v1/targets/x/badge.svg", nil).WithContext(keyed.Context()), web.ID)
+	assert.Equal(t, privateBadgeCacheControl, w2.Header().Get("Cache-Control"))
+
+	etag := w.Header().Get("ETag")
+	r := httptest.NewRequest("GET", "/v1/targets/x/badge.svg", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.TargetBadge(w, r, api.ID)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestPatchTarget_Public(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	target, _, _ := s.CreateTarget(context.Background(), "https://example.com", "")
+	s.UpdateTargetLabels(context.Background(), target.ID, map[string]string{"env": "prod"})
+
+	w := httptest.NewRecorder()
+	h.PatchTarget(w, httptest.NewRequest("PATCH", "/v1/targets/"+target.ID, bytes.NewBufferString(`{"public": true}`)), target.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, true, resp["public"])
+	assert.Equal(t, map[string]interface{}{"env": "prod"}, resp["labels"])
+}
+
+func TestPatchTarget_Managed(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	target, _, _ := s.CreateTarget(context.Background(), "https://example.com", "")
+	s.SetTargetManaged(context.Background(), target.ID, true)
+
+	w := httptest.NewRecorder()
+	h.PatchTarget(w, httptest.NewRequest("PATCH", "/v1/targets/"+target.ID, bytes.NewBufferString(`{"public": true}`)), target.ID)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "targets file")
+
+	w = httptest.NewRecorder()
+	h.GetTarget(w, httptest.NewRequest("GET", "/v1/targets/"+target.ID, nil), target.ID)
+	assert.Contains(t, w.Body.String(), `"managed":true`)
+}
+
+func TestPauseAndDeleteTarget(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	routes := NewHandler(s).Routes(auth.New(s, "admin-key"))
+	target, _, _ := s.CreateTarget(context.Background(), "https://example.com", "")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer admin-key")
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("PATCH", "/v1/targets/"+target.ID, `{"paused": true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"paused":true`)
+	got, _ := s.GetTarget(context.Background(), target.ID)
+	assert.True(t, got.Paused)
+	assert.Empty(t, got.Labels, "labels are kept")
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/v1/targets/"+target.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/v1/targets/"+target.ID, "").Code)
+}
+
+func TestAPIKeys(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+
+	w := httptest.NewRecorder()
+	h.PostAPIKey(w, httptest.NewRequest("POST", "/v1/keys", bytes.NewBufferString(`{"name": "deploy", "scope": "write"}`)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	plain := created["key"].(string)
+	assert.True(t, strings.HasPrefix(plain, created["prefix"].(string)))
+
+	key, err := s.GetAPIKeyByHash(context.Background(), auth.HashKey(plain))
+	require.NoError(t, err)
+	assert.Equal(t, auth.ScopeWrite, key.Scope)
+
+	w = httptest.NewRecorder()
+	h.PostAPIKey(w, httptest.NewRequest("POST", "/v1/keys", bytes.NewBufferString(`{"name": "x", "scope": "root"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ListAPIKeys(w, httptest.NewRequest("GET", "/v1/keys", nil))
+	assert.NotContains(t, w.Body.String(), plain)
+
+	w = httptest.NewRecorder()
+	h.DeleteAPIKey(w, httptest.NewRequest("DELETE", "/v1/keys/x", nil), key.ID)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = httptest.NewRecorder()
+	h.DeleteAPIKey(w, httptest.NewRequest("DELETE", "/v1/keys/x", nil), key.ID)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBadges_Anonymous(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	ctx := context.Background()
+	private, _, _ := s.CreateTarget(ctx, "https://private.example.com", "")
+	open, _, _ := s.CreateTarget(ctx, "https://open.example.com", "")
+	s.SetTargetPublic(ctx, open.ID, true)
+	s.CreateIncident(ctx, &storage.Incident{TargetID: private.ID, StartedAt: time.Now()})
+
+	w := httptest.NewRecorder()
+	h.TargetBadge(w, httptest.NewRequest("GET", "/v1/targets/x/badge.svg", nil), private.ID)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.TargetBadge(w, httptest.NewRequest("GET", "/v1/targets/x/badge.svg", nil), open.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	h.GroupBadge(w, httptest.NewRequest("GET", "/v1/targets/badge.svg", nil))
+	assert.Contains(t, w.Body.String(), "<title>status: unknown</title>", "private down target is not counted")
+
+	r := httptest.NewRequest("GET", "/v1/targets/x/badge.svg", nil)
+	r = r.WithContext(auth.WithKey(r.Context(), &storage.APIKey{Scope: auth.ScopeRead}))
+	w = httptest.NewRecorder()
+	h.TargetBadge(w, r, private.ID)
+	assert.Contains(t, w.Body.String(), "<title>status: down</title>")
+}
+
+func TestTenants_QuotasAndIsolation(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	operator := storage.WithTenant(auth.WithKey(context.Background(), &storage.APIKey{TenantID: storage.DefaultTenantID, Scope: auth.ScopeAdmin}), storage.DefaultTenantID)
+	acme := storage.WithTenant(auth.WithKey(context.Background(), &storage.APIKey{TenantID: "acme", Scope: auth.ScopeAdmin}), "acme")
+	do := func(ctx context.Context, f func(http.ResponseWriter, *http.Request), method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		f(w, httptest.NewRequest(method, "/v1/x", bytes.NewBufferString(body)).WithContext(ctx))
+		return w
+	}
+
+	w := do(operator, h.PostTenant, "POST", `{"id": "acme", "name": "Acme", "max_targets": 1, "min_interval": "1m"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"min_interval":"1m0s"`)
+	assert.Equal(t, http.StatusConflict, do(operator, h.PostTenant, "POST", `{"id": "acme"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(operator, h.PostTenant, "POST", `{"id": "Not Valid"}`).Code)
+
+	w = do(acme, h.PostTarget, "POST", `{"url": "https://acme.example.com", "interval": "30s"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "at least 1m0s")
+	w = do(acme, h.PostTarget, "POST", `{"url": "https://acme.example.com", "interval": "5m"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"interval":"5m0s"`)
+	w = do(acme, h.PostTarget, "POST", `{"url": "https://second.example.com"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// The same URL is a separate target in another tenant.
+	w = do(operator, h.PostTarget, "POST", `{"url": "https://acme.example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	id := created["id"].(string)
+	w = httptest.NewRecorder()
+	h.GetTarget(w, httptest.NewRequest("GET", "/v1/targets/"+id, nil).WithContext(acme), id)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.GetTenant(w, httptest.NewRequest("GET", "/v1/tenants/acme", nil).WithContext(acme), "acme")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"targets":1`)
+	w = httptest.NewRecorder()
+	h.GetTenant(w, httptest.NewRequest("GET", "/v1/tenants/default", nil).WithContext(acme), storage.DefaultTenantID)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.Equal(t, http.StatusForbidden, do(acme, h.PostAPIKey, "POST", `{"name": "x", "scope": "read", "tenant_id": "default"}`).Code)
+	w = do(operator, h.PostAPIKey, "POST", `{"name": "acme-admin", "scope": "admin", "tenant_id": "acme"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"tenant_id":"acme"`)
+}
+
+func TestOpenAPI_MatchesTypes(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewHandler(nil).OpenAPI(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var spec struct {
+		OpenAPI    string
+		Paths      map[string]map[string]interface{}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+
+	types := map[string]interface{}{
+		"Target":                         apitypes.Target{},
+		"CreateTargetRequest":            apitypes.CreateTargetRequest{},
+		"UpdateTargetRequest":            apitypes.UpdateTargetRequest{},
+		"TargetList":                     apitypes.TargetList{},
+		"ImportResult":                   apitypes.ImportResult{},
+		"ImportRow":                      apitypes.ImportRow{},
+		"CheckResult":                    apitypes.CheckResult{},
+		"ResultList":                     apitypes.ResultList{},
+		"Incident":                       apitypes.Incident{},
+		"IncidentList":                   apitypes.IncidentList{},
+		"Uptime":                         apitypes.Uptime{},
+		"Notification":                   apitypes.Notification{},
+		"NotificationList":               apitypes.NotificationList{},
+		"Channel":                        apitypes.Channel{},
+		"CreateChannelRequest":           apitypes.CreateChannelRequest{},
+		"ChannelList":                    apitypes.ChannelList{},
+		"ChannelTestResult":              apitypes.ChannelTestResult{},
+		"RuleCondition":                  apitypes.RuleCondition{},
+		"Rule":                           apitypes.Rule{},
+		"CreateRuleRequest":              apitypes.CreateRuleRequest{},
+		"RuleList":                       apitypes.RuleList{},
+		"Alert":                          apitypes.Alert{},
+		"AlertList":                      apitypes.AlertList{},
+		"AcknowledgeAlertRequest":        apitypes.AcknowledgeAlertRequest{},
+		"MaintenanceWindow":              apitypes.MaintenanceWindow{},
+		"CreateMaintenanceWindowRequest": apitypes.CreateMaintenanceWindowRequest{},
+		"MaintenanceWindowList":          apitypes.MaintenanceWindowList{},
+		"Silence":                        apitypes.Silence{},
+		"CreateSilenceRequest":           apitypes.CreateSilenceRequest{},
+		"SilenceList":                    apitypes.SilenceList{},
+		"APIKey":                         apitypes.APIKey{},
+		"CreateAPIKeyRequest":            apitypes.CreateAPIKeyRequest{},
+		"APIKeyList":                     apitypes.APIKeyList{},
+		"Tenant":                         apitypes.Tenant{},
+		"CreateTenantRequest":            apitypes.CreateTenantRequest{},
+		"UpdateTenantRequest":            apitypes.UpdateTenantRequest{},
+		"TenantList":                     apitypes.TenantList{},
+		"StatusGroup":                    apitypes.StatusGroup{},
+		"StatusPage":                     apitypes.StatusPage{},
+		"CreateStatusPageRequest":        apitypes.CreateStatusPageRequest{},
+		"StatusPageList":                 apitypes.StatusPageList{},
+		"Event":                          apitypes.Event{},
+		"WSRequest":                      apitypes.WSRequest{},
+		"WSSubscriptions":                apitypes.WSSubscriptions{},
+		"WSNotice":                       apitypes.WSNotice{},
+		"Readiness":                      apitypes.Readiness{},
+		"ComponentStatus":                apitypes.ComponentStatus{},
+		"Problem":                        apitypes.Problem{},
+		"FieldError":                     apitypes.FieldError{},
+	}
+	assert.Len(t, spec.Components.Schemas, len(types))
+	for name, v := range types {
+		var fields []string
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			fields = append(fields, tag)
+		}
+		var props []string
+		for p := range spec.Components.Schemas[name].Properties {
+			props = append(props, p)
+		}
+		assert.ElementsMatch(t, fields, props, name)
+	}
+
+	// Every $ref points at a defined component.
+	for _, m := range regexp.MustCompile(`"#/components/(\w+)/(\w+)"`).FindAllStringSubmatch(w.Body.String(), -1) {
+		assert.Contains(t, w.Body.String(), `"`+m[2]+`": {`, "dangling %s", m[0])
+	}
+
+	// Every route is documented, including the optional ones.
+	h := NewHandler(nil)
+	h.SetStatusPages(statuspage.New(nil))
+	h.SetMetricsHandler(http.NotFoundHandler())
+	for _, pattern := range h.router().patterns {
+		method, path, _ := strings.Cut(pattern, " ")
+		if strings.HasSuffix(path, "/") {
+			continue // static files
+		}
+		assert.Contains(t, spec.Paths[path], strings.ToLower(method), "undocumented route %s", pattern)
+	}
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.PostMaintenanceWindow(w, httptest.NewRequest("POST", "/v1/maintenance", strings.NewReader(body)))
+		return w
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"bad mode", `{"mode": "mute", "selector": "env=prod", "schedule": "0 2 * * *", "duration": "1h"}`, "mode"},
+		{"no scope", `{"schedule": "0 2 * * *", "duration": "1h"}`, "selector"},
+		{"target and selector", `{"target_id": "t_1", "selector": "env=prod", "schedule": "0 2 * * *", "duration": "1h"}`, "selector"},
+		{"bad selector", `{"selector": "=prod", "schedule": "0 2 * * *", "duration": "1h"}`, "selector"},
+		{"bad schedule", `{"selector": "env=prod", "schedule": "at two", "duration": "1h"}`, "schedule"},
+		{"schedule and times", `{"selector": "env=prod", "schedule": "0 2 * * *", "duration": "1h", "starts_at": "2026-01-01T00:00:00Z"}`, "schedule"},
+		{"no duration", `{"selector": "env=prod", "schedule": "0 2 * * *"}`, "duration"},
+		{"ends before start", `{"selector": "env=prod", "starts_at": "2026-01-02T00:00:00Z", "ends_at": "2026-01-01T00:00:00Z"}`, "ends_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `"field":"`+tt.field+`"`)
+		})
+	}
+
+	w := post(`{"name": "patching", "mode": "pause", "selector": "tier=db", "schedule": "0 2 * * SUN", "duration": "2h"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var win map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &win))
+	assert.Equal(t, "pause", win["mode"])
+	assert.Equal(t, "2h0m0s", win["duration"])
+	w = post(`{"target_id": "t_1", "starts_at": "2026-01-01T00:00:00Z", "ends_at": "2026-01-01T01:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"mode":"suppress"`)
+
+	w = httptest.NewRecorder()
+	h.ListMaintenanceWindows(w, httptest.NewRequest("GET", "/v1/maintenance", nil))
+	assert.Equal(t, 2, strings.Count(w.Body.String(), `"id":`))
+
+	w = httptest.NewRecorder()
+	h.DeleteMaintenanceWindow(w, httptest.NewRequest("DELETE", "/v1/maintenance/x", nil), win["id"].(string))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = httptest.NewRecorder()
+	h.DeleteMaintenanceWindow(w, httptest.NewRequest("DELETE", "/v1/maintenance/x", nil), win["id"].(string))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSilences(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.PostSilence(w, httptest.NewRequest("POST", "/v1/silences", strings.NewReader(body)))
+		return w
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"no scope", `{"duration": "1h"}`, "selector"},
+		{"target and selector", `{"target_id": "t_1", "selector": "env=prod", "duration": "1h"}`, "selector"},
+		{"duration and expiry", `{"target_id": "t_1", "duration": "1h", "expires_at": "2099-01-01T00:00:00Z"}`, "duration"},
+		{"negative duration", `{"target_id": "t_1", "duration": "-1h"}`, "duration"},
+		{"bad duration", `{"target_id": "t_1", "duration": "a while"}`, "duration"},
+		{"past expiry", `{"target_id": "t_1", "expires_at": "2000-01-01T00:00:00Z"}`, "expires_at"},
+		{"no expiry", `{"target_id": "t_1"}`, "expires_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `"field":"`+tt.field+`"`)
+		})
+	}
+
+	w := post(`{"selector": "env=prod", "duration": "1h", "comment": "known outage", "created_by": "alice"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var silence map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &silence))
+	assert.Equal(t, true, silence["active"])
+	assert.Equal(t, "env=prod", silence["selector"])
+
+	w = httptest.NewRecorder()
+	h.DeleteSilence(w, httptest.NewRequest("DELETE", "/v1/silences/x", nil), silence["id"].(string))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = httptest.NewRecorder()
+	h.DeleteSilence(w, httptest.NewRequest("DELETE", "/v1/silences/x", nil), silence["id"].(string))
+	assert.Equal(t, http.StatusNotFound, w.Code, "expired silences cannot be deleted again")
+	w = httptest.NewRecorder()
+	h.DeleteSilence(w, httptest.NewRequest("DELETE", "/v1/silences/x", nil), "sil_missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ListSilences(w, httptest.NewRequest("GET", "/v1/silences", nil))
+	assert.NotContains(t, w.Body.String(), silence["id"].(string))
+	w = httptest.NewRecorder()
+	h.ListSilences(w, httptest.NewRequest("GET", "/v1/silences?all=true", nil))
+	assert.Contains(t, w.Body.String(), silence["id"].(string))
+}
+
+func TestRoutes(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	h.SetMetricsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	routes := h.Routes(auth.New(s, "admin-key"))
+	target, _, err := s.CreateTarget(context.Background(), "https://example.com", "")
+	require.NoError(t, err)
+	do := func(method, path, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			r.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("GET", "/v1/targets/"+target.ID+"/results", "admin-key")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"items"`)
+
+	w = do("GET", "/v1/targets/"+target.ID+"/foo", "admin-key")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, http.StatusNotFound, do("GET", "/v1/nothing", "admin-key").Code)
+
+	w = do("DELETE", "/v1/targets", "admin-key")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD, POST", w.Header().Get("Allow"))
+	assert.Contains(t, w.Body.String(), `"code":"method_not_allowed"`)
+	assert.Equal(t, "GET, HEAD, PATCH, DELETE", do("PUT", "/v1/targets/"+target.ID, "admin-key").Header().Get("Allow"))
+
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/v1/targets", "").Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/v1/openapi.json", "").Code, "public routes need no key")
+	assert.Equal(t, http.StatusOK, do("GET", "/healthz", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/metrics", "").Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/metrics", "admin-key").Code)
+}
+
+func TestImportExport(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	h := NewHandler(s)
+	importBody := func(h *Handler, query, contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/v1/targets:import"+query, strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		h.ImportTargets(w, r)
+		return w
+	}
+
+	// A transaction with an invalid row writes nothing.
+	w := importBody(h, "", "application/json", `[{"url": "https://a.example.com"}, {"url": "ftp://b.example.com"}, {"url": "https://c.example.com", "interval": "soon"}]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"[1].url"`)
+	assert.Contains(t, w.Body.String(), `"field":"[2].interval"`)
+	n, _ := s.CountTargets(context.Background())
+	assert.Equal(t, 0, n)
+
+	w = importBody(h, "?mode=best_effort", "text/csv", "url,public,interval,label:env,label:team\n"+
+		"https://a.example.com,true,5m,prod,\"web,api\"\n"+
+		"ftp://b.example.com,,,,\n"+
+		"https://c.example.com,,,staging,\n"+
+		"https://A.example.com/,,,,\n")
+	require.Equal(t, http.StatusOK, w.Code)
+	var result apitypes.ImportResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Created)
+	assert.Equal(t, 1, result.Existing)
+	assert.Equal(t, 1, result.Invalid)
+	assert.Equal(t, apitypes.ImportInvalid, result.Items[1].Status)
+	assert.Contains(t, result.Items[1].Error, "url")
+	assert.Equal(t, result.Items[0].ID, result.Items[3].ID)
+	a, err := s.GetTarget(context.Background(), result.Items[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "web,api"}, a.Labels)
+	assert.True(t, a.Public)
+	assert.Equal(t, 5*time.Minute, a.Interval)
+
+	w = importBody(h, "", "application/yaml", "- url: https://d.example.com\n  labels: {env: dev}\n- url: https://c.example.com\n")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"created":1,"existing":1,"invalid":0`)
+	assert.Equal(t, http.StatusBadRequest, importBody(h, "", "application/yaml", "- url: https://e.example.com\n  color: red\n").Code)
+	assert.Equal(t, http.StatusUnsupportedMediaType, importBody(h, "", "text/plain", "https://e.example.com").Code)
+
+	// Each export format can be imported into another instance unchanged.
+	for _, format := range []string{"json", "csv", "yaml"} {
+		w = httptest.NewRecorder()
+		h.ExportTargets(w, httptest.NewRequest("GET", "/v1/targets:export?format="+format, nil))
+		require.Equal(t, http.StatusOK, w.Code, format)
+		other := NewHandler(testutil.SetupTestDB(t))
+		imported := importBody(other, "", w.Header().Get("Content-Type"), w.Body.String())
+		require.Equal(t, http.StatusOK, imported.Code, imported.Body.String())
+		assert.Contains(t, imported.Body.String(), `"created":3,"existing":0`, format)
+
+		again := httptest.NewRecorder()
+		other.ExportTargets(again, httptest.NewRequest("GET", "/v1/targets:export?format="+format, nil))
+		assert.Equal(t, w.Body.String(), again.Body.String(), format)
+	}
 }
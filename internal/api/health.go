@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+)
+
+// CycleReporter is implemented by the checker.
+type CycleReporter interface {
+	LastCycle() time.Time
+}
+
+// SetReadiness makes Readyz require that the checker finished a cycle
+// within maxAge.
+func (h *Handler) SetReadiness(c CycleReporter, maxAge time.Duration) {
+	h.cycles = c
+	h.maxCycleAge = maxAge
+}
+
+// Readyz reports whether the service can do useful work: the database
+// answers and the checker is still completing cycles. Unlike /healthz, which
+// only shows the process is alive, it returns 503 when a component is down.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ready := true
+	components := map[string]apitypes.ComponentStatus{}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := h.storage.Ping(ctx); err != nil {
+		slog.ErrorContext(r.Context(), "readiness: database ping failed", "err", err)
+		ready = false
+		components["database"] = apitypes.ComponentStatus{Status: "error", Error: "ping failed"}
+	} else {
+		components["database"] = apitypes.ComponentStatus{Status: "ok"}
+	}
+
+	if h.cycles != nil {
+		status := apitypes.ComponentStatus{Status: "ok"}
+		last := h.cycles.LastCycle()
+		if last.IsZero() {
+			ready = false
+			status.Status = "error"
+			status.Error = "not started"
+		} else {
+			age := time.Since(last)
+			seconds := int(age.Seconds())
+			status.LastCycleAt = optionalTime(last)
+			status.AgeSeconds = &seconds
+			if h.maxCycleAge > 0 && age > h.maxCycleAge {
+				ready = false
+				status.Status = "error"
+				status.Error = "no completed cycle in " + h.maxCycleAge.String()
+			}
+		}
+		components["checker"] = status
+	}
+
+	code, overall := http.StatusOK, "ok"
+	if !ready {
+		code, overall = http.StatusServiceUnavailable, "unavailable"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(apitypes.Readiness{Status: overall, Components: components})
+}
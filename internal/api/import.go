@@ -0,0 +1,368 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/urls"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	maxImportBytes = 10 << 20
+	maxImportRows  = 10000
+
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatYAML = "yaml"
+
+	// CSV files have one column per label, named with this prefix.
+	csvLabelPrefix = "label:"
+)
+
+// importRow is a parsed row, or the reason it could not be parsed.
+type importRow struct {
+	req apitypes.CreateTargetRequest
+	err error
+}
+
+// ImportTargets creates targets from a JSON array, a CSV file or a YAML list,
+// chosen by Content-Type. By default the import is one transaction: any
+// invalid row fails it with a 400 listing every problem, and nothing is
+// written. With mode=best_effort valid rows are imported and invalid ones
+// reported. Existing targets are never changed.
+func (h *Handler) ImportTargets(w http.ResponseWriter, r *http.Request) {
+	mode, ok := queryOneOf(w, r, "mode", "transaction", "best_effort")
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		problem.Write(w, r, decodeProblem(err))
+		return
+	}
+	rows, err := parseImport(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(rows) > maxImportRows {
+		problem.Error(w, r, http.StatusRequestEntityTooLarge, problem.CodeTooLarge,
+			fmt.Sprintf("at most %d targets can be imported at once", maxImportRows))
+		return
+	}
+
+	targets := make([]*storage.Target, len(rows))
+	var invalid []problem.FieldError
+	for i := range rows {
+		if rows[i].err == nil {
+			targets[i], rows[i].err = h.importTarget(r, rows[i].req)
+		}
+		var p *problem.Problem
+		if errors.As(rows[i].err, &p) {
+			for _, fe := range p.Errors {
+				invalid = append(invalid, problem.FieldError{Field: fmt.Sprintf("[%d].%s", i, fe.Field), Message: fe.Message})
+			}
+		} else if rows[i].err != nil {
+			problem.Internal(w, r, rows[i].err)
+			return
+		}
+	}
+
+	result := apitypes.ImportResult{Items: make([]apitypes.ImportRow, len(rows))}
+	if mode != "best_effort" {
+		if len(invalid) > 0 {
+			p := problem.New(http.StatusBadRequest, problem.CodeInvalidArgument,
+				fmt.Sprintf("%d of %d rows are invalid; nothing was imported", countErrors(rows), len(rows)))
+			p.Errors = invalid
+			problem.Write(w, r, p)
+			return
+		}
+		stored, created, err := h.storage.ImportTargets(r.Context(), targets)
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			problem.Error(w, r, http.StatusForbidden, problem.CodeQuotaExceeded, "the import would exceed the tenant's target quota; nothing was imported")
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+		for i := range rows {
+			result.Items[i] = imported(i, stored[i], created[i])
+		}
+	} else {
+		for i, row := range rows {
+			if row.err != nil {
+				result.Items[i] = apitypes.ImportRow{Index: i, URL: row.req.URL, Status: apitypes.ImportInvalid, Error: row.err.Error()}
+				continue
+			}
+			stored, created, err := h.storage.ImportTargets(r.Context(), targets[i:i+1])
+			if errors.Is(err, storage.ErrQuotaExceeded) {
+				result.Items[i] = apitypes.ImportRow{Index: i, URL: targets[i].URL, Status: apitypes.ImportInvalid, Error: "tenant has reached its target quota"}
+				continue
+			}
+			if err != nil {
+				problem.Internal(w, r, err)
+				return
+			}
+			result.Items[i] = imported(i, stored[0], created[0])
+		}
+	}
+
+	for _, item := range result.Items {
+		switch item.Status {
+		case apitypes.ImportCreated:
+			result.Created++
+		case apitypes.ImportExisting:
+			result.Existing++
+		default:
+			result.Invalid++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func imported(i int, t *storage.Target, created bool) apitypes.ImportRow {
+	status := apitypes.ImportExisting
+	if created {
+		status = apitypes.ImportCreated
+	}
+	return apitypes.ImportRow{Index: i, URL: t.URL, Status: status, ID: t.ID}
+}
+
+func countErrors(rows []importRow) int {
+	n := 0
+	for _, row := range rows {
+		if row.err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// importTarget validates a row the way PostTarget validates its body.
+func (h *Handler) importTarget(r *http.Request, req apitypes.CreateTargetRequest) (*storage.Target, error) {
+	canonicalURL, err := urls.Canonicalize(req.URL)
+	if err != nil {
+		return nil, problem.Invalid("url", "must be an http or https URL")
+	}
+	if err := labels.Validate(req.Labels); err != nil {
+		return nil, problem.Invalid("labels", err.Error())
+	}
+	interval, err := h.targetInterval(r.Context(), req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.Target{URL: canonicalURL, Labels: req.Labels, Public: req.Public, Interval: interval}, nil
+}
+
+func parseImport(contentType string, body []byte) ([]importRow, error) {
+	format := formatJSON
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMedia, "malformed Content-Type")
+		}
+		switch mediaType {
+		case "application/json":
+		case "text/csv":
+			format = formatCSV
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			format = formatYAML
+		default:
+			return nil, problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMedia,
+				"Content-Type must be application/json, text/csv or application/yaml")
+		}
+	}
+
+	var reqs []apitypes.CreateTargetRequest
+	switch format {
+	case formatCSV:
+		return parseCSV(body)
+	case formatYAML:
+		dec := yaml.NewDecoder(bytes.NewReader(body))
+		dec.KnownFields(true)
+		if err := dec.Decode(&reqs); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "request body is required")
+			}
+			return nil, problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "malformed YAML: "+strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&reqs)
+		if err == nil && dec.More() {
+			err = errTrailingData
+		}
+		if err != nil {
+			return nil, decodeProblem(err)
+		}
+	}
+	rows := make([]importRow, len(reqs))
+	for i, req := range reqs {
+		rows[i].req = req
+	}
+	return rows, nil
+}
+
+// parseCSV reads a file with a header row naming the url, public, interval
+// and label:<key> columns. Only url is required; empty cells are unset.
+func parseCSV(body []byte) ([]importRow, error) {
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))))
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "request body is required")
+	}
+	if err != nil {
+		return nil, problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "malformed CSV: "+err.Error())
+	}
+	hasURL := false
+	for i, col := range header {
+		col = strings.TrimSpace(col)
+		header[i] = col
+		switch {
+		case col == "url":
+			hasURL = true
+		case col == "public", col == "interval":
+		case strings.HasPrefix(col, csvLabelPrefix) && len(col) > len(csvLabelPrefix):
+		default:
+			return nil, problem.Invalid(col, "unknown CSV column")
+		}
+	}
+	if !hasURL {
+		return nil, problem.Invalid("url", "CSV header must have a url column")
+	}
+
+	var rows []importRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, problem.New(http.StatusBadRequest, problem.CodeInvalidArgument, "malformed CSV: "+err.Error())
+		}
+		var row importRow
+		for i, cell := range record {
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				continue
+			}
+			switch col := header[i]; col {
+			case "url":
+				row.req.URL = cell
+			case "public":
+				public, err := strconv.ParseBool(cell)
+				if err != nil && row.err == nil {
+					row.err = problem.Invalid("public", "must be true or false")
+				}
+				row.req.Public = public
+			case "interval":
+				row.req.Interval = cell
+			default:
+				if row.req.Labels == nil {
+					row.req.Labels = map[string]string{}
+				}
+				row.req.Labels[strings.TrimPrefix(col, csvLabelPrefix)] = cell
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// ExportTargets writes every target of the tenant with its labels and
+// settings, in a format ImportTargets accepts: format=json (the default),
+// csv or yaml.
+func (h *Handler) ExportTargets(w http.ResponseWriter, r *http.Request) {
+	format, ok := queryOneOf(w, r, "format", formatJSON, formatCSV, formatYAML)
+	if !ok {
+		return
+	}
+	if format == "" {
+		format = formatJSON
+	}
+
+	rows := []apitypes.CreateTargetRequest{}
+	pageToken := ""
+	for {
+		targets, next, err := h.storage.ListTargets(r.Context(), "", maxLimit, pageToken)
+		if err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+		for _, t := range targets {
+			row := apitypes.CreateTargetRequest{URL: t.URL, Public: t.Public}
+			if len(t.Labels) > 0 {
+				row.Labels = t.Labels
+			}
+			if t.Interval > 0 {
+				row.Interval = t.Interval.String()
+			}
+			rows = append(rows, row)
+		}
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="linkwatch-targets.`+format+`"`)
+	switch format {
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		writeCSV(w, rows)
+	case formatYAML:
+		w.Header().Set("Content-Type", "application/yaml")
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		enc.Encode(rows)
+		enc.Close()
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rows)
+	}
+}
+
+func writeCSV(w io.Writer, rows []apitypes.CreateTargetRequest) {
+	keys := map[string]bool{}
+	for _, row := range rows {
+		for k := range row.Labels {
+			keys[k] = true
+		}
+	}
+	labelKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		labelKeys = append(labelKeys, k)
+	}
+	sort.Strings(labelKeys)
+
+	cw := csv.NewWriter(w)
+	header := []string{"url", "public", "interval"}
+	for _, k := range labelKeys {
+		header = append(header, csvLabelPrefix+k)
+	}
+	cw.Write(header)
+	for _, row := range rows {
+		record := []string{row.URL, strconv.FormatBool(row.Public), row.Interval}
+		for _, k := range labelKeys {
+			record = append(record, row.Labels[k])
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/maintenance"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+)
+
+// scope validates a target_id/selector pair shared by windows and silences
+// and returns the normalized selector.
+func scope(targetID, selector string) (string, error) {
+	if targetID != "" && selector != "" {
+		return "", problem.Invalid("selector", "target_id and selector are mutually exclusive")
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return "", problem.Invalid("selector", err.Error())
+	}
+	if targetID == "" && sel.Empty() {
+		return "", problem.Invalid("selector", "target_id or selector is required")
+	}
+	return sel.String(), nil
+}
+
+func (h *Handler) PostMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	var body apitypes.CreateMaintenanceWindowRequest
+	if !decode(w, r, &body) {
+		return
+	}
+
+	win := &storage.MaintenanceWindow{
+		Name:     body.Name,
+		Mode:     body.Mode,
+		TargetID: body.TargetID,
+		Schedule: body.Schedule,
+	}
+	if win.Mode == "" {
+		win.Mode = storage.MaintenanceSuppress
+	}
+	if win.Mode != storage.MaintenancePause && win.Mode != storage.MaintenanceSuppress {
+		problem.InvalidField(w, r, "mode", "must be pause or suppress")
+		return
+	}
+
+	var err error
+	if win.Selector, err = scope(body.TargetID, body.Selector); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if body.Schedule != "" {
+		if body.StartsAt != nil || body.EndsAt != nil {
+			problem.InvalidField(w, r, "schedule", "schedule and starts_at/ends_at are mutually exclusive")
+			return
+		}
+		if _, err := maintenance.ParseSchedule(body.Schedule); err != nil {
+			problem.InvalidField(w, r, "schedule", err.Error())
+			return
+		}
+		if win.Duration, err = parseDuration(body.Duration); err != nil || win.Duration <= 0 {
+			problem.InvalidField(w, r, "duration", "recurring windows need a positive duration")
+			return
+		}
+	} else {
+		if body.StartsAt == nil || body.EndsAt == nil || !body.EndsAt.After(*body.StartsAt) {
+			problem.InvalidField(w, r, "ends_at", "one-off windows need starts_at before ends_at")
+			return
+		}
+		win.StartsAt, win.EndsAt = body.StartsAt.UTC(), body.EndsAt.UTC()
+	}
+
+	if err := h.storage.CreateMaintenanceWindow(r.Context(), win); err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(windowJSON(win, time.Now().UTC()))
+}
+
+func (h *Handler) ListMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	windows, err := h.storage.ListMaintenanceWindows(r.Context())
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	resp := apitypes.MaintenanceWindowList{Items: []apitypes.MaintenanceWindow{}}
+	for _, win := range windows {
+		resp.Items = append(resp.Items, windowJSON(win, now))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) DeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request, windowID string) {
+	err := h.storage.DeleteMaintenanceWindow(r.Context(), windowID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "maintenance window not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func windowJSON(win *storage.MaintenanceWindow, now time.Time) apitypes.MaintenanceWindow {
+	item := apitypes.MaintenanceWindow{
+		ID:        win.ID,
+		Name:      win.Name,
+		Mode:      win.Mode,
+		TargetID:  win.TargetID,
+		Selector:  win.Selector,
+		Active:    maintenance.Active(win, now),
+		CreatedAt: timestamp(win.CreatedAt),
+	}
+	if win.Schedule != "" {
+		item.Schedule = win.Schedule
+		item.Duration = win.Duration.String()
+	} else {
+		item.StartsAt = optionalTime(win.StartsAt)
+		item.EndsAt = optionalTime(win.EndsAt)
+	}
+	return item
+}
+
+// PostSilence mutes alerts for a target or selector for the given duration
+// (or until expires_at) while checks and uptime carry on as usual.
+func (h *Handler) PostSilence(w http.ResponseWriter, r *http.Request) {
+	var body apitypes.CreateSilenceRequest
+	if !decode(w, r, &body) {
+		return
+	}
+
+	silence := &storage.Silence{TargetID: body.TargetID, Comment: body.Comment, CreatedBy: body.CreatedBy}
+	var err error
+	if silence.Selector, err = scope(body.TargetID, body.Selector); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case body.Duration != "" && body.ExpiresAt != nil:
+		problem.InvalidField(w, r, "duration", "duration and expires_at are mutually exclusive")
+		return
+	case body.Duration != "":
+		d, err := parseDuration(body.Duration)
+		if err != nil || d <= 0 {
+			problem.InvalidField(w, r, "duration", "must be positive")
+			return
+		}
+		silence.ExpiresAt = now.Add(d)
+	case body.ExpiresAt != nil:
+		silence.ExpiresAt = body.ExpiresAt.UTC()
+	}
+	if !silence.ExpiresAt.After(now) {
+		problem.InvalidField(w, r, "expires_at", "a duration or a future expires_at is required")
+		return
+	}
+
+	if err := h.storage.CreateSilence(r.Context(), silence); err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(silenceJSON(silence, now))
+}
+
+// ListSilences returns active silences, or all of them with ?all=true.
+func (h *Handler) ListSilences(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	activeAt := now
+	all, ok := queryBool(w, r, "all")
+	if !ok {
+		return
+	}
+	if all {
+		activeAt = time.Time{}
+	}
+
+	silences, err := h.storage.ListSilences(r.Context(), activeAt)
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	resp := apitypes.SilenceList{Items: []apitypes.Silence{}}
+	for _, s := range silences {
+		resp.Items = append(resp.Items, silenceJSON(s, now))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteSilence expires a silence immediately; it stays in the history.
+func (h *Handler) DeleteSilence(w http.ResponseWriter, r *http.Request, silenceID string) {
+	err := h.storage.ExpireSilence(r.Context(), silenceID, time.Now().UTC())
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "active silence not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func silenceJSON(s *storage.Silence, now time.Time) apitypes.Silence {
+	return apitypes.Silence{
+		ID:        s.ID,
+		TargetID:  s.TargetID,
+		Selector:  s.Selector,
+		Comment:   s.Comment,
+		CreatedBy: s.CreatedBy,
+		Active:    s.ExpiresAt.After(now),
+		CreatedAt: timestamp(s.CreatedAt),
+		ExpiresAt: timestamp(s.ExpiresAt),
+	}
+}
+
+// GetUptime reports the share of successful checks over ?window= (default
+// 24h). Checks taken during suppress maintenance windows are not counted.
+func (h *Handler) GetUptime(w http.ResponseWriter, r *http.Request, targetID string) {
+	window := 24 * time.Hour
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		d, err := parseDuration(raw)
+		if err != nil || d <= 0 {
+			problem.InvalidField(w, r, "window", "must be a positive duration such as 24h or 30d")
+			return
+		}
+		window = d
+	}
+
+	if _, ok := h.loadTarget(w, r, targetID); !ok {
+		return
+	}
+
+	checks, up, err := h.storage.GetUptime(r.Context(), targetID, time.Now().UTC().Add(-window))
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	resp := apitypes.Uptime{TargetID: targetID, Window: window.String(), Checks: checks, Successful: up}
+	if checks > 0 {
+		uptime := float64(up) / float64(checks) * 100
+		resp.Uptime = &uptime
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
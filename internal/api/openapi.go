@@ -0,0 +1,21 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"net/http"
+	"time"
+)
+
+// openAPISpec describes every route. Its schemas mirror pkg/apitypes;
+// TestOpenAPI_MatchesTypes keeps them and the registered routes in step.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPI serves the OpenAPI 3 document. It needs no key.
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(openAPISpec))
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/ratelimit"
+)
+
+// StatusPages serves public status pages; it is implemented by
+// statuspage.Server.
+type StatusPages interface {
+	Page(w http.ResponseWriter, r *http.Request, slug string)
+	Assets() http.Handler
+}
+
+// Instrumenter wraps the handler of one route, for example to record
+// metrics and traces. route is the path pattern without the method.
+type Instrumenter func(route string, next http.HandlerFunc) http.HandlerFunc
+
+// SetRateLimits limits authenticated reads and writes and public routes.
+// A nil limiter leaves its routes unlimited.
+func (h *Handler) SetRateLimits(read, write, public *ratelimit.Limiter) {
+	h.readLimit, h.writeLimit, h.publicLimit = read, write, public
+}
+
+func (h *Handler) SetInstrumenter(i Instrumenter) {
+	h.instrument = i
+}
+
+// SetStatusPages serves status pages under /status/.
+func (h *Handler) SetStatusPages(p StatusPages) {
+	h.statusPages = p
+}
+
+// SetMetricsHandler serves metrics at /metrics to operator keys.
+func (h *Handler) SetMetricsHandler(m http.Handler) {
+	h.metrics = m
+}
+
+// Routes returns the whole HTTP API behind authn. Unknown paths get a 404
+// and known paths with an unsupported method a 405, both as problem+json.
+func (h *Handler) Routes(authn *auth.Authenticator) http.Handler {
+	return authn.Middleware(h.router())
+}
+
+// router registers every route; each must also be described in openapi.json.
+func (h *Handler) router() *router {
+	rt := &router{h: h, mux: http.NewServeMux()}
+
+	rt.handle("GET /v1/targets", auth.ScopeRead, h.ListTargets)
+	rt.handle("POST /v1/targets", auth.ScopeWrite, h.PostTarget)
+	rt.handle("POST /v1/targets:import", auth.ScopeWrite, h.ImportTargets)
+	rt.handle("GET /v1/targets:export", auth.ScopeRead, h.ExportTargets)
+	rt.handle("GET /v1/targets/{id}", auth.ScopeRead, withID(h.GetTarget))
+	rt.handle("PATCH /v1/targets/{id}", auth.ScopeWrite, withID(h.PatchTarget))
+	rt.handle("DELETE /v1/targets/{id}", auth.ScopeWrite, withID(h.DeleteTarget))
+	rt.handle("GET /v1/targets/{id}/results", auth.ScopeRead, withID(h.GetResults))
+	rt.handle("GET /v1/targets/{id}/incidents", auth.ScopeRead, withID(h.GetTargetIncidents))
+	rt.handle("GET /v1/targets/{id}/uptime", auth.ScopeRead, withID(h.GetUptime))
+	// Badges are public; the handlers hide private targets from anonymous
+	// callers.
+	rt.public("GET /v1/targets/badge.svg", h.GroupBadge)
+	rt.public("GET /v1/targets/{id}/badge.svg", withID(h.TargetBadge))
+	rt.public("GET /v1/openapi.json", h.OpenAPI)
+
+	rt.handle("POST /v1/keys", auth.ScopeAdmin, h.PostAPIKey)
+	rt.handle("GET /v1/keys", auth.ScopeAdmin, h.ListAPIKeys)
+	rt.handle("DELETE /v1/keys/{id}", auth.ScopeAdmin, withID(h.DeleteAPIKey))
+
+	rt.operator("POST /v1/tenants", h.PostTenant)
+	rt.operator("GET /v1/tenants", h.ListTenants)
+	rt.handle("GET /v1/tenants/{id}", auth.ScopeRead, withID(h.GetTenant))
+	rt.operator("PATCH /v1/tenants/{id}", withID(h.PatchTenant))
+
+	rt.handle("POST /v1/channels", auth.ScopeAdmin, h.PostChannel)
+	rt.handle("GET /v1/channels", auth.ScopeAdmin, h.ListChannels)
+	rt.handle("GET /v1/channels/{id}", auth.ScopeAdmin, withID(h.GetChannel))
+	rt.handle("DELETE /v1/channels/{id}", auth.ScopeAdmin, withID(h.DeleteChannel))
+	rt.handle("POST /v1/channels/{id}/test", auth.ScopeAdmin, withID(h.TestChannel))
+
+	rt.handle("POST /v1/status-pages", auth.ScopeAdmin, h.PostStatusPage)
+	rt.handle("GET /v1/status-pages", auth.ScopeRead, h.ListStatusPages)
+	rt.handle("DELETE /v1/status-pages/{id}", auth.ScopeAdmin, withID(h.DeleteStatusPage))
+
+	rt.handle("GET /v1/incidents", auth.ScopeRead, h.ListIncidents)
+	rt.handle("GET /v1/notifications", auth.ScopeAdmin, h.ListNotifications)
+
+	rt.handle("POST /v1/rules", auth.ScopeAdmin, h.PostRule)
+	rt.handle("GET /v1/rules", auth.ScopeRead, h.ListRules)
+	rt.handle("GET /v1/rules/{id}", auth.ScopeRead, withID(h.GetRule))
+	rt.handle("DELETE /v1/rules/{id}", auth.ScopeAdmin, withID(h.DeleteRule))
+	rt.handle("GET /v1/alerts", auth.ScopeRead, h.ListAlerts)
+	rt.handle("POST /v1/alerts/{id}/ack", auth.ScopeAdmin, withID(h.AcknowledgeAlert))
+
+	rt.handle("POST /v1/maintenance", auth.ScopeAdmin, h.PostMaintenanceWindow)
+	rt.handle("GET /v1/maintenance", auth.ScopeRead, h.ListMaintenanceWindows)
+	rt.handle("DELETE /v1/maintenance/{id}", auth.ScopeAdmin, withID(h.DeleteMaintenanceWindow))
+	rt.handle("POST /v1/silences", auth.ScopeAdmin, h.PostSilence)
+	rt.handle("GET /v1/silences", auth.ScopeRead, h.ListSilences)
+	rt.handle("DELETE /v1/silences/{id}", auth.ScopeAdmin, withID(h.DeleteSilence))
+
+	rt.handle("GET /v1/events", auth.ScopeRead, h.Events)
+	rt.handle("GET /v1/ws", auth.ScopeRead, h.WebSocket)
+
+	if h.statusPages != nil {
+		rt.mount("GET /status/assets/", h.statusPages.Assets())
+		rt.public("GET /status/{slug}", func(w http.ResponseWriter, r *http.Request) {
+			h.statusPages.Page(w, r, r.PathValue("slug"))
+		})
+	}
+	if h.metrics != nil {
+		// Metrics cover every tenant, so only operators may scrape them.
+		rt.mount("GET /metrics", auth.RequireOperator(h.metrics.ServeHTTP))
+	}
+	rt.register("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	rt.register("GET /readyz", h.Readyz)
+	return rt
+}
+
+// withID adapts handlers that take the {id} path segment.
+func withID(f func(w http.ResponseWriter, r *http.Request, id string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f(w, r, r.PathValue("id"))
+	}
+}
+
+type router struct {
+	h   *Handler
+	mux *http.ServeMux
+	// patterns lists the registered routes in order.
+	patterns []string
+}
+
+// probeMethods are tried to tell a 405 from a 404.
+var probeMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern != "" {
+		rt.mux.ServeHTTP(w, r)
+		return
+	}
+	var allowed []string
+	for _, m := range probeMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = m
+		if _, pattern := rt.mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, m)
+		}
+	}
+	if len(allowed) == 0 {
+		problem.NotFound(w, r, "no such resource")
+		return
+	}
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	problem.MethodNotAllowed(w, r)
+}
+
+// mount adds a route as is, without instrumentation.
+func (rt *router) mount(pattern string, h http.Handler) {
+	rt.mux.Handle(pattern, h)
+	rt.patterns = append(rt.patterns, pattern)
+}
+
+// register adds an instrumented route.
+func (rt *router) register(pattern string, f http.HandlerFunc) {
+	if rt.h.instrument != nil {
+		_, route, _ := strings.Cut(pattern, " ")
+		f = rt.h.instrument(route, f)
+	}
+	rt.mount(pattern, f)
+}
+
+// handle registers a route that needs an API key with scope, limited by the
+// read limit for GET and the write limit for other methods.
+func (rt *router) handle(pattern, scope string, f http.HandlerFunc) {
+	limit := rt.h.writeLimit
+	if strings.HasPrefix(pattern, http.MethodGet+" ") {
+		limit = rt.h.readLimit
+	}
+	rt.register(pattern, limited(limit, auth.Require(scope, f)))
+}
+
+// operator registers a route only operators of the default tenant may use.
+func (rt *router) operator(pattern string, f http.HandlerFunc) {
+	rt.register(pattern, limited(rt.h.writeLimit, auth.RequireOperator(f)))
+}
+
+func (rt *router) public(pattern string, f http.HandlerFunc) {
+	rt.register(pattern, limited(rt.h.publicLimit, f))
+}
+
+func limited(l *ratelimit.Limiter, f http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return f
+	}
+	return l.Handler(f)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// PostStatusPage creates a public status page. Every group needs a name; an
+// empty selector lists all targets.
+func (h *Handler) PostStatusPage(w http.ResponseWriter, r *http.Request) {
+	var body apitypes.CreateStatusPageRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	if !slugPattern.MatchString(body.Slug) {
+		problem.InvalidField(w, r, "slug", "must be lowercase letters, digits and dashes")
+		return
+	}
+	if body.Title == "" {
+		problem.InvalidField(w, r, "title", "is required")
+		return
+	}
+	if len(body.Groups) == 0 {
+		problem.InvalidField(w, r, "groups", "at least one group is required")
+		return
+	}
+	for i, g := range body.Groups {
+		if g.Name == "" {
+			problem.InvalidField(w, r, fmt.Sprintf("groups[%d].name", i), "is required")
+			return
+		}
+		sel, err := labels.Parse(g.Selector)
+		if err != nil {
+			problem.InvalidField(w, r, fmt.Sprintf("groups[%d].selector", i), err.Error())
+			return
+		}
+		body.Groups[i].Selector = sel.String()
+	}
+
+	// Slugs are shared by all tenants, so look beyond the caller's own pages.
+	if _, err := h.storage.GetStatusPage(storage.WithTenant(r.Context(), ""), body.Slug); err == nil {
+		problem.Conflict(w, r, "slug already in use")
+		return
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	page := &storage.StatusPage{Slug: body.Slug, Title: body.Title, Description: body.Description}
+	for _, g := range body.Groups {
+		page.Groups = append(page.Groups, storage.StatusGroup{Name: g.Name, Selector: g.Selector})
+	}
+	if err := h.storage.CreateStatusPage(r.Context(), page); err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(statusPageJSON(page))
+}
+
+func (h *Handler) ListStatusPages(w http.ResponseWriter, r *http.Request) {
+	pages, err := h.storage.ListStatusPages(r.Context())
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	resp := apitypes.StatusPageList{Items: []apitypes.StatusPage{}}
+	for _, page := range pages {
+		resp.Items = append(resp.Items, statusPageJSON(page))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) DeleteStatusPage(w http.ResponseWriter, r *http.Request, pageID string) {
+	err := h.storage.DeleteStatusPage(r.Context(), pageID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "status page not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func statusPageJSON(page *storage.StatusPage) apitypes.StatusPage {
+	groups := []apitypes.StatusGroup{}
+	for _, g := range page.Groups {
+		groups = append(groups, apitypes.StatusGroup{Name: g.Name, Selector: g.Selector})
+	}
+	return apitypes.StatusPage{
+		ID:          page.ID,
+		Slug:        page.Slug,
+		Title:       page.Title,
+		Description: page.Description,
+		Groups:      groups,
+		Path:        "/status/" + page.Slug,
+		CreatedAt:   timestamp(page.CreatedAt),
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+)
+
+// minTargetInterval keeps per-target intervals from turning the checker into
+// a load generator.
+const minTargetInterval = time.Second
+
+// tenant returns the tenant the request is scoped to.
+func (h *Handler) tenant(ctx context.Context) (*storage.Tenant, error) {
+	id := storage.TenantFromContext(ctx)
+	if id == "" {
+		id = storage.DefaultTenantID
+	}
+	return h.storage.GetTenant(ctx, id)
+}
+
+// targetInterval parses a target's check interval and checks it against the
+// tenant's minimum. An empty interval means the checker's default. Invalid
+// intervals are reported as a *problem.Problem.
+func (h *Handler) targetInterval(ctx context.Context, raw string) (time.Duration, error) {
+	d, err := parseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, problem.Invalid("interval", "must be a duration such as 30s or 5m")
+	}
+	if d == 0 {
+		return 0, nil
+	}
+	if d < minTargetInterval {
+		return 0, problem.Invalid("interval", fmt.Sprintf("must be at least %s", minTargetInterval))
+	}
+	tenant, err := h.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if d < tenant.MinInterval {
+		return 0, problem.Invalid("interval", fmt.Sprintf("must be at least %s for this tenant", tenant.MinInterval))
+	}
+	return d, nil
+}
+
+// applyTenant validates the quotas in body and copies them onto t.
+func applyTenant(t *storage.Tenant, body apitypes.UpdateTenantRequest) error {
+	if body.Name != "" {
+		t.Name = body.Name
+	}
+	if body.MaxTargets != nil {
+		if *body.MaxTargets < 0 {
+			return problem.Invalid("max_targets", "must not be negative")
+		}
+		t.MaxTargets = *body.MaxTargets
+	}
+	if body.MinInterval != nil {
+		d, err := parseDuration(*body.MinInterval)
+		if err != nil || d < 0 {
+			return problem.Invalid("min_interval", "must be a duration such as 30s or 5m")
+		}
+		t.MinInterval = d
+	}
+	return nil
+}
+
+// PostTenant creates a tenant. IDs follow the same rules as status page slugs.
+func (h *Handler) PostTenant(w http.ResponseWriter, r *http.Request) {
+	var body apitypes.CreateTenantRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	if !slugPattern.MatchString(body.ID) {
+		problem.InvalidField(w, r, "id", "must be lowercase letters, digits and dashes")
+		return
+	}
+	tenant := &storage.Tenant{ID: body.ID, Name: body.ID}
+	update := apitypes.UpdateTenantRequest{Name: body.Name, MaxTargets: body.MaxTargets, MinInterval: body.MinInterval}
+	if err := applyTenant(tenant, update); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := h.storage.GetTenant(r.Context(), body.ID); err == nil {
+		problem.Conflict(w, r, "tenant already exists")
+		return
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		problem.Internal(w, r, err)
+		return
+	}
+	if err := h.storage.CreateTenant(r.Context(), tenant); err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	h.writeTenant(w, r, tenant, http.StatusCreated)
+}
+
+func (h *Handler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := h.storage.ListTenants(r.Context())
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+
+	resp := apitypes.TenantList{Items: []apitypes.Tenant{}}
+	for _, t := range tenants {
+		item, err := h.tenantJSON(r.Context(), t)
+		if err != nil {
+			problem.Internal(w, r, err)
+			return
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetTenant shows a tenant's quotas and usage. Keys of other tenants may
+// only look at their own.
+func (h *Handler) GetTenant(w http.ResponseWriter, r *http.Request, tenantID string) {
+	if key := auth.FromContext(r.Context()); key != nil && !auth.IsOperator(key) && key.TenantID != tenantID {
+		problem.NotFound(w, r, "tenant not found")
+		return
+	}
+	tenant, err := h.storage.GetTenant(r.Context(), tenantID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "tenant not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	h.writeTenant(w, r, tenant, http.StatusOK)
+}
+
+// PatchTenant changes a tenant's name and quotas.
+func (h *Handler) PatchTenant(w http.ResponseWriter, r *http.Request, tenantID string) {
+	var body apitypes.UpdateTenantRequest
+	if !decode(w, r, &body) {
+		return
+	}
+	tenant, err := h.storage.GetTenant(r.Context(), tenantID)
+	if errors.Is(err, storage.ErrNotFound) {
+		problem.NotFound(w, r, "tenant not found")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	if err := applyTenant(tenant, body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.storage.UpdateTenant(r.Context(), tenant); err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	h.writeTenant(w, r, tenant, http.StatusOK)
+}
+
+func (h *Handler) writeTenant(w http.ResponseWriter, r *http.Request, t *storage.Tenant, status int) {
+	resp, err := h.tenantJSON(r.Context(), t)
+	if err != nil {
+		problem.Internal(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) tenantJSON(ctx context.Context, t *storage.Tenant) (apitypes.Tenant, error) {
+	targets, err := h.storage.CountTargets(storage.WithTenant(ctx, t.ID))
+	if err != nil {
+		return apitypes.Tenant{}, err
+	}
+	return apitypes.Tenant{
+		ID:          t.ID,
+		Name:        t.Name,
+		MaxTargets:  t.MaxTargets,
+		MinInterval: t.MinInterval.String(),
+		Targets:     targets,
+		CreatedAt:   timestamp(t.CreatedAt),
+	}, nil
+}
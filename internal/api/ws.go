@@ -0,0 +1,212 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/events"
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	wsPingInterval = 30 * time.Second
+	wsWriteWait    = 10 * time.Second
+)
+
+// wsBuffer is how many events may queue for a connection before the oldest
+// are discarded.
+const wsBuffer = 64
+
+var wsUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096}
+
+// wsSubscriptions is replaced, never modified, so the broker can read it
+// without locking.
+type wsSubscriptions struct {
+	targets   map[string]bool
+	selectors map[string]labels.Selector
+}
+
+func (s *wsSubscriptions) matches(ev *events.Event) bool {
+	if s.targets[ev.Target.ID] {
+		return true
+	}
+	for _, sel := range s.selectors {
+		if sel.Matches(ev.Target.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns a copy of s with the request's target IDs and selectors
+// added or removed.
+func (s *wsSubscriptions) apply(req apitypes.WSRequest) (*wsSubscriptions, error) {
+	if req.Type != "subscribe" && req.Type != "unsubscribe" {
+		return nil, errors.New("type must be subscribe or unsubscribe")
+	}
+	next := &wsSubscriptions{targets: map[string]bool{}, selectors: map[string]labels.Selector{}}
+	for id := range s.targets {
+		next.targets[id] = true
+	}
+	for raw, sel := range s.selectors {
+		next.selectors[raw] = sel
+	}
+
+	for _, id := range req.TargetIDs {
+		if req.Type == "subscribe" {
+			next.targets[id] = true
+		} else {
+			delete(next.targets, id)
+		}
+	}
+	for _, raw := range req.Selectors {
+		sel, err := labels.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if sel.Empty() {
+			return nil, errors.New("empty selector")
+		}
+		if req.Type == "subscribe" {
+			next.selectors[sel.String()] = sel
+		} else {
+			delete(next.selectors, sel.String())
+		}
+	}
+	return next, nil
+}
+
+func (s *wsSubscriptions) reply() apitypes.WSSubscriptions {
+	targets := []string{}
+	for id := range s.targets {
+		targets = append(targets, id)
+	}
+	selectors := []string{}
+	for raw := range s.selectors {
+		selectors = append(selectors, raw)
+	}
+	sort.Strings(targets)
+	sort.Strings(selectors)
+	return apitypes.WSSubscriptions{Type: "subscriptions", TargetIDs: targets, Selectors: selectors}
+}
+
+// wsMessage renders an event. State changes that open or resolve an
+// incident are sent as "incident" messages.
+func wsMessage(ev *events.Event) apitypes.Event {
+	msg := eventJSON(ev)
+	msg.ID = ev.ID
+	msg.Type = ev.Type
+	if ev.Type == events.TypeState && ev.Incident != nil {
+		msg.Type = "incident"
+	}
+	return msg
+}
+
+// WebSocket serves live events to dashboards. Clients send
+// {"type":"subscribe"|"unsubscribe","target_ids":[...],"selectors":[...]}
+// and receive their current subscriptions in reply, followed by result,
+// incident, state and flapping messages for matching targets. The server
+// pings every 30 seconds. A client that reads too slowly loses its oldest
+// queued events and is told how many with a "dropped" message.
+func (h *Handler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	if h.events == nil {
+		problem.Error(w, r, http.StatusServiceUnavailable, problem.CodeUnavailable, "event stream not available")
+		return
+	}
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error.
+		return
+	}
+	defer conn.Close()
+
+	var subs atomic.Pointer[wsSubscriptions]
+	subs.Store(&wsSubscriptions{})
+	sub := h.events.SubscribeDropOldest(ownEvents(r.Context(), func(ev *events.Event) bool {
+		return subs.Load().matches(ev)
+	}), wsBuffer)
+	defer sub.Cancel()
+
+	replies := make(chan interface{}, 8)
+	readerDone := make(chan struct{})
+	writerDone := make(chan struct{})
+	defer close(writerDone)
+
+	go func() {
+		defer close(readerDone)
+		conn.SetReadLimit(64 << 10)
+		conn.SetReadDeadline(time.Now().Add(2 * wsPingInterval))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * wsPingInterval))
+		})
+		for {
+			var req apitypes.WSRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				if _, ok := err.(*websocket.CloseError); !ok && !errors.Is(err, net.ErrClosed) {
+					slog.DebugContext(r.Context(), "websocket read", "err", err)
+				}
+				return
+			}
+			var reply interface{}
+			next, err := subs.Load().apply(req)
+			if err != nil {
+				reply = apitypes.WSNotice{Type: "error", Error: err.Error()}
+			} else {
+				subs.Store(next)
+				reply = next.reply()
+			}
+			select {
+			case replies <- reply:
+			case <-writerDone:
+				return
+			}
+		}
+	}()
+
+	write := func(v interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(v)
+	}
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	var reported uint64
+	for {
+		select {
+		case <-readerDone:
+			return
+		case reply := <-replies:
+			if write(reply) != nil {
+				return
+			}
+		case ev, ok := <-sub.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+					time.Now().Add(wsWriteWait))
+				return
+			}
+			if dropped := sub.Dropped(); dropped > reported {
+				if write(apitypes.WSNotice{Type: "dropped", Count: dropped - reported}) != nil {
+					return
+				}
+				reported = dropped
+			}
+			if write(wsMessage(ev)) != nil {
+				return
+			}
+		case <-ping.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+)
+
+// Scopes, from least to most privileged; each includes the ones before it.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var scopeRank = map[string]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+// ValidScope reports whether s is one of the scopes above.
+func ValidScope(s string) bool {
+	return scopeRank[s] > 0
+}
+
+// Allows reports whether a key with scope have may use a route needing need.
+func Allows(have, need string) bool {
+	return scopeRank[have] > 0 && scopeRank[have] >= scopeRank[need]
+}
+
+const keyPrefix = "lw_"
+
+// GenerateKey returns a new random key, the prefix shown in listings and the
+// hash to store.
+func GenerateKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:len(keyPrefix)+6], HashKey(key), nil
+}
+
+// HashKey hashes a key for storage. Keys are long random strings, so a plain
+// SHA-256 is enough; there is nothing to brute-force.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// BootstrapKeyID identifies requests made with the key from the environment.
+const BootstrapKeyID = "bootstrap"
+
+// touchInterval limits how often a key's last-used time is written.
+const touchInterval = time.Minute
+
+type keyContextKey struct{}
+
+// FromContext returns the key that authenticated the request, or nil for
+// anonymous requests.
+func FromContext(ctx context.Context) *storage.APIKey {
+	key, _ := ctx.Value(keyContextKey{}).(*storage.APIKey)
+	return key
+}
+
+func WithKey(ctx context.Context, key *storage.APIKey) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// Authenticator resolves bearer tokens to API keys.
+type Authenticator struct {
+	storage       storage.Storage
+	bootstrapHash string
+}
+
+// New checks keys against storage and, when bootstrapKey is set, accepts it
+// as an admin key that is never stored.
+func New(s storage.Storage, bootstrapKey string) *Authenticator {
+	a := &Authenticator{storage: s}
+	if bootstrapKey != "" {
+		a.bootstrapHash = HashKey(bootstrapKey)
+	}
+	return a
+}
+
+var errInvalidKey = errors.New("invalid API key")
+
+func (a *Authenticator) lookup(ctx context.Context, token string) (*storage.APIKey, error) {
+	hash := HashKey(token)
+	if a.bootstrapHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(a.bootstrapHash)) == 1 {
+		return &storage.APIKey{ID: BootstrapKeyID, TenantID: storage.DefaultTenantID, Name: "bootstrap", Scope: ScopeAdmin}, nil
+	}
+	key, err := a.storage.GetAPIKeyByHash(ctx, hash)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, errInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if now := time.Now().UTC(); now.Sub(key.LastUsedAt) > touchInterval {
+		if err := a.storage.TouchAPIKey(ctx, key.ID, now); err != nil {
+			slog.WarnContext(ctx, "recording API key use", "key_id", key.ID, "err", err)
+		}
+	}
+	return key, nil
+}
+
+// Middleware attaches the caller's key to the request context and scopes
+// storage access to the key's tenant. Requests
+// without credentials continue anonymously and are turned away by Require;
+// requests with an unknown or revoked key are rejected here. Browsers cannot
+// set headers on EventSource and WebSocket connections, so for those the key
+// may also be passed as ?access_token=.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			unauthorized(w, r, "malformed Authorization header")
+			return
+		}
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, err := a.lookup(r.Context(), token)
+		if errors.Is(err, errInvalidKey) {
+			unauthorized(w, r, err.Error())
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, fmt.Errorf("looking up API key: %w", err))
+			return
+		}
+		ctx := storage.WithTenant(WithKey(r.Context(), key), key.TenantID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// bearerToken returns the token from the request, "" when there is none and
+// false when the Authorization header is not a bearer token.
+func bearerToken(r *http.Request) (string, bool) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return "", false
+		}
+		return strings.TrimSpace(token), true
+	}
+	if streaming(r) {
+		return r.URL.Query().Get("access_token"), true
+	}
+	return "", true
+}
+
+func streaming(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// Require rejects requests whose key lacks scope: 401 without a key, 403 with
+// one that is not privileged enough.
+func Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := FromContext(r.Context())
+		if key == nil {
+			unauthorized(w, r, "API key required")
+			return
+		}
+		if !Allows(key.Scope, scope) {
+			problem.Forbidden(w, r, "API key lacks the "+scope+" scope")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// IsOperator reports whether key may manage tenants and see data of all
+// tenants: admin keys of the default tenant, including the bootstrap key.
+func IsOperator(key *storage.APIKey) bool {
+	return key != nil && key.TenantID == storage.DefaultTenantID && key.Scope == ScopeAdmin
+}
+
+// RequireOperator rejects requests not made with an operator key.
+func RequireOperator(next http.HandlerFunc) http.HandlerFunc {
+	return Require(ScopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		if !IsOperator(FromContext(r.Context())) {
+			problem.Forbidden(w, r, "only operators of the default tenant may do this")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="linkwatch"`)
+	problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, msg)
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllows(t *testing.T) {
+	assert.True(t, Allows(ScopeAdmin, ScopeWrite))
+	assert.True(t, Allows(ScopeWrite, ScopeRead))
+	assert.False(t, Allows(ScopeRead, ScopeWrite))
+	assert.False(t, Allows("", ScopeRead))
+	assert.False(t, ValidScope("root"))
+}
+
+func TestMiddleware(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+
+	plain, prefix, hash, err := GenerateKey()
+	require.NoError(t, err)
+	assert.Equal(t, plain[:len(prefix)], prefix)
+	readKey := &storage.APIKey{Name: "ci", Scope: ScopeRead, Prefix: prefix, Hash: hash}
+	require.NoError(t, s.CreateAPIKey(ctx, readKey))
+
+	a := New(s, "bootstrap-secret")
+	name := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).Name))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /", Require(ScopeRead, name))
+	mux.Handle("POST /", Require(ScopeWrite, name))
+	h := a.Middleware(mux)
+	do := func(method, token string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/v1/targets", nil)
+		if header != nil {
+			r.Header = header
+		}
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("GET", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="linkwatch"`, w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	w = do("GET", plain, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ci", w.Body.String())
+	assert.Equal(t, http.StatusForbidden, do("POST", plain, nil).Code)
+
+	w = do("POST", "bootstrap-secret", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bootstrap", w.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "lw_wrong", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "", http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}}).Code)
+
+	r := httptest.NewRequest("GET", "/v1/events?access_token="+plain, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "query tokens are only for streams")
+	r.Header.Set("Accept", "text/event-stream")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	keys, err := s.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	assert.False(t, keys[0].LastUsedAt.IsZero())
+
+	require.NoError(t, s.RevokeAPIKey(ctx, readKey.ID, time.Now()))
+	assert.Equal(t, http.StatusUnauthorized, do("GET", plain, nil).Code)
+}
@@ -0,0 +1,76 @@
+package badge
+
+import (
+	"bytes"
+	"html/template"
+	"math"
+)
+
+// Shields-style colors.
+const (
+	Green       = "#4c1"
+	YellowGreen = "#a4a61d"
+	Yellow      = "#dfb317"
+	Orange      = "#fe7d37"
+	Red         = "#e05d44"
+	Blue        = "#007ec6"
+	Grey        = "#9f9f9f"
+)
+
+var svg = template.Must(template.New("badge").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{.Label}}: {{.Message}}">
+<title>{{.Label}}: {{.Message}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="20" fill="#555"/>
+<rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{.Color}}"/>
+<rect width="{{.Width}}" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="15" fill="#010101" fill-opacity=".3">{{.Label}}</text>
+<text x="{{.LabelX}}" y="14">{{.Label}}</text>
+<text x="{{.MessageX}}" y="15" fill="#010101" fill-opacity=".3">{{.Message}}</text>
+<text x="{{.MessageX}}" y="14">{{.Message}}</text>
+</g>
+</svg>
+`))
+
+// Render draws a flat two-part badge: label on grey, message on color.
+func Render(label, message, color string) []byte {
+	lw := textWidth(label) + 10
+	mw := textWidth(message) + 10
+	var buf bytes.Buffer
+	svg.Execute(&buf, map[string]interface{}{
+		"Label":        label,
+		"Message":      message,
+		"Color":        color,
+		"LabelWidth":   lw,
+		"MessageWidth": mw,
+		"Width":        lw + mw,
+		"LabelX":       float64(lw) / 2,
+		"MessageX":     float64(lw) + float64(mw)/2,
+	})
+	return buf.Bytes()
+}
+
+// textWidth approximates the width of s in 11px Verdana.
+func textWidth(s string) int {
+	var w float64
+	for _, c := range s {
+		switch {
+		case c == 'i' || c == 'l' || c == 'j' || c == '.' || c == ',' || c == ':' || c == ';' || c == '!' || c == '|' || c == '\'':
+			w += 3.5
+		case c == ' ' || c == 'f' || c == 't' || c == 'r' || c == '(' || c == ')' || c == '/':
+			w += 4.5
+		case c == 'm' || c == 'w' || c == '%':
+			w += 10.5
+		case c == 'M' || c == 'W':
+			w += 11.5
+		case c >= 'A' && c <= 'Z':
+			w += 7.5
+		default:
+			w += 7
+		}
+	}
+	return int(math.Ceil(w))
+}
@@ -0,0 +1,23 @@
+package badge
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	svg := Render("status <a&b>", "up", Green)
+
+	var doc struct {
+		Width string `xml:"width,attr"`
+		Title string `xml:"title"`
+	}
+	assert.NoError(t, xml.Unmarshal(svg, &doc), "badge is well-formed XML")
+	assert.Equal(t, "status <a&b>: up", doc.Title)
+	assert.Contains(t, string(svg), `fill="#4c1"`)
+
+	assert.Less(t, textWidth("up"), textWidth("maintenance"))
+	assert.Less(t, textWidth("iiii"), textWidth("MMMM"))
+}
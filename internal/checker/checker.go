@@ -3,36 +3,62 @@ package checker
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/AlanZeng-Coder/linkwatch/internal/checker")
+
 type Checker struct {
-	storage        storage.Storage
-	interval       time.Duration
-	maxConcurrency int
-	httpTimeout    time.Duration
-	httpClient     *http.Client
-	hostMu         sync.Map
-	wg             sync.WaitGroup
-	ctx            context.Context
-	cancel         context.CancelFunc
+	storage          storage.Storage
+	interval         time.Duration
+	maxConcurrency   int
+	httpTimeout      time.Duration
+	httpClient       *http.Client
+	hostMu           sync.Map
+	states           sync.Map
+	lastQueued       sync.Map
+	failThreshold    int
+	recoverThreshold int
+	degradedLatency  time.Duration
+	maintenance      Maintenance
+	flapWindow       int
+	flapLow          float64
+	flapHigh         float64
+	listeners        []Listener
+	queued           atomic.Int64
+	inFlight         atomic.Int64
+	lastCycle        atomic.Int64
+	wg               sync.WaitGroup
+	ctx              context.Context
+	cancel           context.CancelFunc
+}
+
+// Maintenance reports the maintenance mode (storage.MaintenancePause,
+// storage.MaintenanceSuppress or "") that applies to a target at a given time.
+type Maintenance interface {
+	Mode(ctx context.Context, t *storage.Target, at time.Time) string
 }
 
 func NewChecker(s storage.Storage, interval time.Duration, maxConc int, httpTimeout time.Duration) *Checker {
 	ctx, cancel := context.WithCancel(context.Background())
 	client := &http.Client{
-		Timeout: httpTimeout,
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost: 1,
-		},
+		Timeout:   httpTimeout,
+		Transport: newTransport(false),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 5 {
 				return errors.New("stopped after 5 redirects")
@@ -41,17 +67,36 @@ func NewChecker(s storage.Storage, interval time.Duration, maxConc int, httpTime
 		},
 	}
 	return &Checker{
-		storage:        s,
-		interval:       interval,
-		maxConcurrency: maxConc,
-		httpTimeout:    httpTimeout,
-		httpClient:     client,
-		ctx:            ctx,
-		cancel:         cancel,
+		storage:          s,
+		interval:         interval,
+		maxConcurrency:   maxConc,
+		httpTimeout:      httpTimeout,
+		httpClient:       client,
+		failThreshold:    1,
+		recoverThreshold: 1,
+		ctx:              ctx,
+		cancel:           cancel,
+	}
+}
+
+// newTransport traces every request as a client span. Trace context headers
+// are only added to outgoing checks when propagate is set, since most
+// monitored endpoints are not ours.
+func newTransport(propagate bool) http.RoundTripper {
+	var propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator()
+	if propagate {
+		propagator = propagation.TraceContext{}
 	}
+	return otelhttp.NewTransport(&http.Transport{MaxIdleConnsPerHost: 1}, otelhttp.WithPropagators(propagator))
+}
+
+// SetTracePropagation controls whether checks send W3C traceparent headers.
+func (c *Checker) SetTracePropagation(propagate bool) {
+	c.httpClient.Transport = newTransport(propagate)
 }
 
 func (c *Checker) Start() {
+	c.lastCycle.Store(time.Now().UnixNano())
 	ticker := time.NewTicker(c.interval)
 	defer ticker.Stop()
 
@@ -70,28 +115,86 @@ func (c *Checker) Stop() {
 	c.cancel()
 }
 
+func (c *Checker) SetMaintenance(m Maintenance) {
+	c.maintenance = m
+}
+
+// QueueDepth is the number of targets waiting to be checked in the current
+// cycle.
+func (c *Checker) QueueDepth() int {
+	return int(c.queued.Load())
+}
+
+// InFlight is the number of checks currently running.
+func (c *Checker) InFlight() int {
+	return int(c.inFlight.Load())
+}
+
+// LastCycle returns when the most recent check cycle finished, or when the
+// checker started if no cycle has finished yet. It is zero before Start.
+func (c *Checker) LastCycle() time.Time {
+	ns := c.lastCycle.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
 func (c *Checker) checkAll() {
-	targets, _, err := c.storage.ListTargets(c.ctx, "", 10000, "")
+	all, next, err := c.storage.ListTargets(c.ctx, "", 10000, "")
 	if err != nil {
-		log.Printf("Error listing targets: %v", err)
+		slog.Error("listing targets", "err", err)
 		return
 	}
+	if next == "" {
+		c.forgetMissing(all)
+	}
+	tenants, err := c.storage.ListTenants(c.ctx)
+	if err != nil {
+		slog.Error("listing tenants", "err", err)
+		return
+	}
+	minIntervals := make(map[string]time.Duration, len(tenants))
+	for _, tn := range tenants {
+		minIntervals[tn.ID] = tn.MinInterval
+	}
+	now := time.Now()
+	var targets []*storage.Target
+	for _, t := range all {
+		if c.due(t, minIntervals[t.TenantID], now) {
+			c.lastQueued.Store(t.ID, now)
+			targets = append(targets, t)
+		}
+	}
 
 	queue := make(chan *storage.Target, len(targets))
 	for _, t := range targets {
 		queue <- t
 	}
 	close(queue)
+	c.queued.Add(int64(len(targets)))
 
 	sem := make(chan struct{}, c.maxConcurrency)
+	var cycle sync.WaitGroup
+	cycle.Add(c.maxConcurrency)
 	c.wg.Add(c.maxConcurrency)
+	go func() {
+		cycle.Wait()
+		if c.ctx.Err() == nil {
+			c.lastCycle.Store(time.Now().UnixNano())
+		}
+	}()
 	for i := 0; i < c.maxConcurrency; i++ {
 		go func() {
 			defer c.wg.Done()
+			defer cycle.Done()
 			for target := range queue {
+				c.queued.Add(-1)
 				select {
 				case sem <- struct{}{}:
+					c.inFlight.Add(1)
 					c.checkOne(target)
+					c.inFlight.Add(-1)
 					<-sem
 				case <-c.ctx.Done():
 					return
@@ -101,7 +204,43 @@ func (c *Checker) checkAll() {
 	}
 }
 
+// due reports whether t should be checked in the cycle starting at now.
+// Paused targets never are; others are checked every Interval, or every
+// cycle when it has none, but never more often than its tenant's minimum
+// interval. Half a cycle of slack keeps ticker jitter from pushing a check
+// into the following cycle.
+func (c *Checker) due(t *storage.Target, minInterval time.Duration, now time.Time) bool {
+	if t.Paused {
+		return false
+	}
+	interval := max(t.Interval, minInterval)
+	if interval <= c.interval {
+		return true
+	}
+	last, ok := c.lastQueued.Load(t.ID)
+	return !ok || now.Sub(last.(time.Time)) >= interval-c.interval/2
+}
+
 func (c *Checker) checkOne(t *storage.Target) {
+	ctx, span := tracer.Start(c.ctx, "checker.checkOne", trace.WithAttributes(
+		attribute.String("linkwatch.target.id", t.ID),
+		attribute.String("url.full", t.URL),
+	))
+	defer span.End()
+	logger := slog.With("target_id", t.ID, "url", t.URL)
+
+	var mode string
+	if c.maintenance != nil {
+		mode = c.maintenance.Mode(ctx, t, time.Now().UTC())
+	}
+	if mode != "" {
+		span.SetAttributes(attribute.String("linkwatch.maintenance", mode))
+	}
+	if mode == storage.MaintenancePause {
+		logger.DebugContext(ctx, "check paused for maintenance")
+		return
+	}
+
 	u, _ := url.Parse(t.URL)
 	host := u.Host
 
@@ -110,15 +249,16 @@ func (c *Checker) checkOne(t *storage.Target) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	result := &storage.CheckResult{CheckedAt: time.Now().UTC()}
+	result := &storage.CheckResult{CheckedAt: time.Now().UTC(), Maintenance: mode == storage.MaintenanceSuppress}
 	backoff := 200 * time.Millisecond
 	var resp *http.Response
 	var err error
 	for attempt := 0; attempt < 3; attempt++ {
 		start := time.Now()
-		resp, err = c.httpClient.Get(t.URL)
+		resp, err = c.attempt(ctx, t.URL, attempt+1)
 		result.LatencyMs = int(time.Since(start).Milliseconds())
 		if err != nil {
+			logger.DebugContext(ctx, "check attempt failed", "attempt", attempt+1, "err", err)
 			if attempt < 2 && isRetryableError(err) {
 				time.Sleep(backoff)
 				backoff *= 2
@@ -129,6 +269,10 @@ func (c *Checker) checkOne(t *storage.Target) {
 		}
 		defer resp.Body.Close()
 		result.StatusCode = resp.StatusCode
+		if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+			result.CertExpiresAt = resp.TLS.PeerCertificates[0].NotAfter
+		}
+		logger.DebugContext(ctx, "check attempt completed", "attempt", attempt+1, "status_code", resp.StatusCode)
 		if attempt < 2 && (resp.StatusCode >= 500) {
 			time.Sleep(backoff)
 			backoff *= 2
@@ -140,9 +284,36 @@ func (c *Checker) checkOne(t *storage.Target) {
 		break
 	}
 
-	if err := c.storage.SaveCheckResult(c.ctx, t.ID, result); err != nil {
-		log.Printf("Error saving result: %v", err)
+	logger.DebugContext(ctx, "check completed", "status_code", result.StatusCode, "latency_ms", result.LatencyMs, "error", result.Error)
+	span.SetAttributes(attribute.Int("http.response.status_code", result.StatusCode))
+	if result.Failed() {
+		span.SetStatus(codes.Error, describeFailure(result))
+	}
+
+	if err := c.storage.SaveCheckResult(ctx, t.ID, result); err != nil {
+		logger.ErrorContext(ctx, "saving result", "err", err)
+		return
+	}
+	c.observe(ctx, t, result)
+}
+
+// attempt performs one GET of rawURL inside its own span.
+func (c *Checker) attempt(ctx context.Context, rawURL string, n int) (*http.Response, error) {
+	ctx, span := tracer.Start(ctx, "checker.attempt", trace.WithAttributes(attribute.Int("linkwatch.attempt", n)))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	return resp, nil
 }
 
 func isRetryableError(err error) bool {
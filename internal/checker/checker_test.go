@@ -10,6 +10,12 @@ import (
 	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
 	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestChecker_ConcurrencyAndPerHost(t *testing.T) {
@@ -56,3 +62,300 @@ func TestCheckOne_RetryBackoff(t *testing.T) {
 	assert.Greater(t, duration, 600*time.Millisecond)
 	assert.Equal(t, 3, count)
 }
+
+func TestCheckOne_IncidentLifecycle(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	c := NewChecker(s, 1*time.Second, 1, 2*time.Second)
+	c.SetThresholds(2, 2)
+
+	status := http.StatusNotFound
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	target, _, err := s.CreateTarget(context.Background(), srv.URL, "")
+	assert.NoError(t, err)
+
+	c.checkOne(target)
+	assert.Equal(t, StateUnknown, c.TargetState(target.ID))
+	c.checkOne(target)
+	assert.Equal(t, StateDown, c.TargetState(target.ID))
+
+	open, err := s.ListIncidents(context.Background(), target.ID, true, 10)
+	assert.NoError(t, err)
+	assert.Len(t, open, 1)
+	assert.Equal(t, "HTTP 404", open[0].FirstError)
+	assert.Len(t, open[0].TriggerResults, 2)
+
+	for i := 0; i < 3; i++ {
+		c.checkOne(target)
+	}
+	st, _ := c.states.Load(target.ID)
+	assert.Empty(t, st.(*targetState).failures, "failures are not kept while down")
+
+	status = http.StatusOK
+	c.checkOne(target)
+	assert.Equal(t, StateDown, c.TargetState(target.ID))
+	c.checkOne(target)
+	assert.Equal(t, StateUp, c.TargetState(target.ID))
+
+	open, err = s.ListIncidents(context.Background(), target.ID, true, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, open)
+
+	all, err := s.ListIncidents(context.Background(), target.ID, false, 10)
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.False(t, all[0].Open())
+	assert.GreaterOrEqual(t, all[0].Duration, time.Duration(0))
+}
+
+type recordingListener struct {
+	transitions []Transition
+	flaps       []FlapChange
+	removed     []string
+}
+
+func (l *recordingListener) ResultSaved(t *storage.Target, r *storage.CheckResult) {}
+
+func (l *recordingListener) StateChanged(tr Transition) {
+	l.transitions = append(l.transitions, tr)
+}
+
+func (l *recordingListener) FlappingChanged(fc FlapChange) {
+	l.flaps = append(l.flaps, fc)
+}
+
+func (l *recordingListener) TargetRemoved(targetID string) {
+	l.removed = append(l.removed, targetID)
+}
+
+func TestForgetMissing(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	c := NewChecker(s, time.Second, 1, time.Second)
+	l := &recordingListener{}
+	c.AddListener(l)
+	kept, _, _ := s.CreateTarget(context.Background(), "https://a.example.com", "")
+	deleted, _, _ := s.CreateTarget(context.Background(), "https://b.example.com", "")
+	for _, target := range []*storage.Target{kept, deleted} {
+		c.observe(context.Background(), target, &storage.CheckResult{CheckedAt: time.Now().UTC(), StatusCode: 200})
+		c.lastQueued.Store(target.ID, time.Now())
+	}
+	require.NoError(t, s.DeleteTarget(context.Background(), deleted.ID))
+
+	all, _, err := s.ListTargets(context.Background(), "", 100, "")
+	require.NoError(t, err)
+	c.forgetMissing(all)
+	_, ok := c.states.Load(deleted.ID)
+	assert.False(t, ok)
+	_, ok = c.lastQueued.Load(deleted.ID)
+	assert.False(t, ok)
+	assert.Equal(t, StateUp, c.TargetState(kept.ID))
+	assert.Equal(t, []string{deleted.ID}, l.removed)
+}
+
+func TestObserve_FlapDetection(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	c := NewChecker(s, time.Second, 1, time.Second)
+	c.SetFlapDetection(5, 20, 50)
+	l := &recordingListener{}
+	c.AddListener(l)
+
+	target, _, err := s.CreateTarget(context.Background(), "https://example.com", "")
+	assert.NoError(t, err)
+
+	for _, code := range []int{200, 500, 200, 500} {
+		c.observe(context.Background(), target, &storage.CheckResult{CheckedAt: time.Now().UTC(), StatusCode: code})
+	}
+	assert.Empty(t, l.flaps)
+	for _, tr := range l.transitions {
+		assert.False(t, tr.Flapping)
+	}
+
+	c.observe(context.Background(), target, &storage.CheckResult{CheckedAt: time.Now().UTC(), StatusCode: 200})
+	if assert.Len(t, l.flaps, 1) {
+		assert.True(t, l.flaps[0].Flapping)
+		assert.Equal(t, 100.0, l.flaps[0].PercentChange)
+	}
+	assert.True(t, l.transitions[len(l.transitions)-1].Flapping)
+
+	stored, err := s.GetTarget(context.Background(), target.ID)
+	assert.NoError(t, err)
+	assert.True(t, stored.Flapping)
+
+	for i := 0; i < 4; i++ {
+		c.observe(context.Background(), target, &storage.CheckResult{CheckedAt: time.Now().UTC(), StatusCode: 200})
+	}
+	if assert.Len(t, l.flaps, 2) {
+		assert.False(t, l.flaps[1].Flapping)
+		assert.Equal(t, StateUp, l.flaps[1].State)
+	}
+
+	stored, err = s.GetTarget(context.Background(), target.ID)
+	assert.NoError(t, err)
+	assert.False(t, stored.Flapping)
+}
+
+func TestObserve_FlappingAfterRestart(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	target, _, err := s.CreateTarget(context.Background(), "https://example.com", "")
+	assert.NoError(t, err)
+	assert.NoError(t, s.SetTargetFlapping(context.Background(), target.ID, true))
+	target, err = s.GetTarget(context.Background(), target.ID)
+	assert.NoError(t, err)
+
+	c := NewChecker(s, time.Second, 1, time.Second)
+	c.SetFlapDetection(5, 20, 50)
+	l := &recordingListener{}
+	c.AddListener(l)
+	for i := 0; i < 5; i++ {
+		c.observe(context.Background(), target, &storage.CheckResult{CheckedAt: time.Now().UTC(), StatusCode: 200})
+	}
+	if assert.Len(t, l.flaps, 1) {
+		assert.False(t, l.flaps[0].Flapping)
+	}
+	stored, err := s.GetTarget(context.Background(), target.ID)
+	assert.NoError(t, err)
+	assert.False(t, stored.Flapping)
+}
+
+func TestPercentStateChange(t *testing.T) {
+	assert.Equal(t, 0.0, percentStateChange([]State{StateUp, StateUp, StateUp}))
+	assert.InDelta(t, 100.0, percentStateChange([]State{StateUp, StateDown, StateUp, StateDown}), 0.001)
+	// A single change counts for more when it is recent.
+	older := percentStateChange([]State{StateUp, StateDown, StateDown, StateDown, StateDown})
+	newer := percentStateChange([]State{StateUp, StateUp, StateUp, StateUp, StateDown})
+	assert.Less(t, older, newer)
+}
+
+func TestCheckOne_Tracing(t *testing.T) {
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	}()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparents []string
+	count := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		count++
+		if count == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	s := testutil.SetupTestDB(t)
+	c := NewChecker(s, time.Second, 1, time.Second)
+	target, _, err := s.CreateTarget(context.Background(), srv.URL, "")
+	assert.NoError(t, err)
+
+	c.checkOne(target)
+	assert.Equal(t, []string{"", ""}, traceparents)
+
+	names := map[string]int{}
+	var root sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		names[span.Name()]++
+		if span.Name() == "checker.checkOne" {
+			root = span
+		}
+	}
+	assert.Equal(t, 1, names["checker.checkOne"])
+	assert.Equal(t, 2, names["checker.attempt"])
+	assert.Equal(t, 2, names["HTTP GET"])
+	if assert.NotNil(t, root) {
+		for _, span := range recorder.Ended() {
+			assert.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID())
+		}
+	}
+
+	c.SetTracePropagation(true)
+	c.checkOne(target)
+	assert.Len(t, traceparents, 3)
+	assert.NotEmpty(t, traceparents[2])
+
+	// Incident writes run inside the check's span, so the database spans
+	// nest under it.
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	spans := &spanStorage{Storage: s}
+	c = NewChecker(spans, time.Second, 1, time.Second)
+	c.SetThresholds(1, 1)
+	target, _, err = s.CreateTarget(context.Background(), down.URL, "")
+	require.NoError(t, err)
+	c.checkOne(target)
+	assert.Equal(t, map[string]bool{"GetOpenIncident": true, "CreateIncident": true}, spans.traced)
+}
+
+// spanStorage records whether incident calls carried a span.
+type spanStorage struct {
+	storage.Storage
+	traced map[string]bool
+}
+
+func (s *spanStorage) record(ctx context.Context, method string) {
+	if s.traced == nil {
+		s.traced = map[string]bool{}
+	}
+	s.traced[method] = trace.SpanContextFromContext(ctx).IsValid()
+}
+
+func (s *spanStorage) GetOpenIncident(ctx context.Context, targetID string) (*storage.Incident, error) {
+	s.record(ctx, "GetOpenIncident")
+	return s.Storage.GetOpenIncident(ctx, targetID)
+}
+
+func (s *spanStorage) CreateIncident(ctx context.Context, inc *storage.Incident) error {
+	s.record(ctx, "CreateIncident")
+	return s.Storage.CreateIncident(ctx, inc)
+}
+
+func TestChecker_LastCycle(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	c := NewChecker(s, 50*time.Millisecond, 2, time.Second)
+	assert.True(t, c.LastCycle().IsZero())
+
+	go c.Start()
+	defer c.Stop()
+	time.Sleep(10 * time.Millisecond)
+	started := c.LastCycle()
+	assert.False(t, started.IsZero())
+
+	time.Sleep(200 * time.Millisecond)
+	assert.True(t, c.LastCycle().After(started))
+}
+
+func TestDue_IntervalsAndTenantMinimum(t *testing.T) {
+	c := NewChecker(testutil.SetupTestDB(t), 15*time.Second, 1, time.Second)
+	now := time.Now()
+
+	everyCycle := &storage.Target{ID: "t_a"}
+	assert.True(t, c.due(everyCycle, 0, now))
+	c.lastQueued.Store(everyCycle.ID, now)
+	assert.True(t, c.due(everyCycle, 0, now.Add(15*time.Second)))
+
+	slow := &storage.Target{ID: "t_b", Interval: time.Minute}
+	assert.True(t, c.due(slow, 0, now), "never checked")
+	c.lastQueued.Store(slow.ID, now)
+	assert.False(t, c.due(slow, 0, now.Add(45*time.Second)))
+	assert.True(t, c.due(slow, 0, now.Add(59*time.Second)), "ticker jitter is tolerated")
+
+	// The tenant minimum raises both explicit and default intervals.
+	assert.False(t, c.due(slow, 2*time.Minute, now.Add(time.Minute)))
+	c.lastQueued.Store(everyCycle.ID, now)
+	assert.False(t, c.due(everyCycle, 2*time.Minute, now.Add(time.Minute)))
+	assert.True(t, c.due(everyCycle, 2*time.Minute, now.Add(2*time.Minute)))
+
+	paused := &storage.Target{ID: "t_c", Paused: true}
+	assert.False(t, c.due(paused, 0, now))
+}
@@ -0,0 +1,303 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+)
+
+type State string
+
+const (
+	StateUnknown State = "unknown"
+	StateUp      State = "up"
+	StateDown    State = "down"
+	// StateDegraded means the target responds successfully but slower than
+	// the configured latency threshold.
+	StateDegraded State = "degraded"
+)
+
+type Transition struct {
+	Target   *storage.Target
+	From     State
+	To       State
+	At       time.Time
+	Result   *storage.CheckResult
+	Incident *storage.Incident
+	// Flapping is set when the transition happens while the target is
+	// flapping; listeners should not alert on it individually.
+	Flapping bool
+}
+
+// FlapChange reports that a target started or stopped flapping.
+type FlapChange struct {
+	Target        *storage.Target
+	Flapping      bool
+	State         State
+	PercentChange float64
+	At            time.Time
+}
+
+// Listener is notified synchronously from the worker goroutines, so
+// implementations must not block.
+type Listener interface {
+	ResultSaved(t *storage.Target, r *storage.CheckResult)
+	StateChanged(tr Transition)
+	FlappingChanged(fc FlapChange)
+	// TargetRemoved is called once for a target that was deleted, so
+	// listeners can drop what they keep about it.
+	TargetRemoved(targetID string)
+}
+
+type targetState struct {
+	mu        sync.Mutex
+	loaded    bool
+	state     State
+	failures  []*storage.CheckResult
+	successes int
+	incident  *storage.Incident
+	history   []State
+	flapping  bool
+}
+
+func (c *Checker) SetThresholds(failures, successes int) {
+	if failures < 1 {
+		failures = 1
+	}
+	if successes < 1 {
+		successes = 1
+	}
+	c.failThreshold = failures
+	c.recoverThreshold = successes
+}
+
+// SetDegradedLatency marks successful checks at or above d as degraded.
+// Zero disables the degraded state.
+func (c *Checker) SetDegradedLatency(d time.Duration) {
+	c.degradedLatency = d
+}
+
+// SetFlapDetection enables Nagios-style flap detection over the raw outcome
+// of the last window checks. A target starts flapping when the weighted
+// percentage of state changes reaches high and stops once it falls below
+// low. A window below 3 disables detection.
+func (c *Checker) SetFlapDetection(window int, low, high float64) {
+	c.flapWindow = window
+	c.flapLow = low
+	c.flapHigh = high
+}
+
+func (c *Checker) AddListener(l Listener) {
+	c.listeners = append(c.listeners, l)
+}
+
+func (c *Checker) TargetState(targetID string) State {
+	stI, ok := c.states.Load(targetID)
+	if !ok {
+		return StateUnknown
+	}
+	st := stI.(*targetState)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.state
+}
+
+// forgetMissing drops the state of targets that are no longer in all.
+func (c *Checker) forgetMissing(all []*storage.Target) {
+	present := make(map[string]bool, len(all))
+	for _, t := range all {
+		present[t.ID] = true
+	}
+	c.states.Range(func(id, _ any) bool {
+		if !present[id.(string)] {
+			c.states.Delete(id)
+			for _, l := range c.listeners {
+				l.TargetRemoved(id.(string))
+			}
+		}
+		return true
+	})
+	c.lastQueued.Range(func(id, _ any) bool {
+		if !present[id.(string)] {
+			c.lastQueued.Delete(id)
+		}
+		return true
+	})
+}
+
+// observe runs on ctx, the context of the check, so storage calls show up
+// under its span.
+func (c *Checker) observe(ctx context.Context, t *storage.Target, result *storage.CheckResult) {
+	for _, l := range c.listeners {
+		l.ResultSaved(t, result)
+	}
+
+	stI, _ := c.states.LoadOrStore(t.ID, &targetState{state: StateUnknown})
+	st := stI.(*targetState)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if !st.loaded {
+		// An incident left open by a previous run means the target was down
+		// when we stopped; resume from there instead of opening a duplicate.
+		inc, err := c.storage.GetOpenIncident(ctx, t.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "loading open incident", "target_id", t.ID, "err", err)
+			return
+		}
+		if inc != nil {
+			st.state = StateDown
+			st.incident = inc
+		}
+		// Resume flapping too, so the stored flag is cleared when it stops.
+		st.flapping = t.Flapping
+		st.loaded = true
+	}
+
+	fc := c.detectFlapping(st, t, result)
+	if fc != nil && fc.Flapping {
+		c.notifyFlapping(ctx, fc)
+	}
+
+	tr := c.advance(ctx, st, t, result)
+	if tr != nil {
+		tr.Flapping = st.flapping
+		for _, l := range c.listeners {
+			l.StateChanged(*tr)
+		}
+	}
+
+	if fc != nil && !fc.Flapping {
+		fc.State = st.state
+		c.notifyFlapping(ctx, fc)
+	}
+}
+
+func (c *Checker) notifyFlapping(ctx context.Context, fc *FlapChange) {
+	if err := c.storage.SetTargetFlapping(ctx, fc.Target.ID, fc.Flapping); err != nil {
+		slog.ErrorContext(ctx, "marking target flapping", "target_id", fc.Target.ID, "err", err)
+	}
+	for _, l := range c.listeners {
+		l.FlappingChanged(*fc)
+	}
+}
+
+// detectFlapping records the raw outcome of result and returns a FlapChange
+// when the target starts or stops flapping.
+func (c *Checker) detectFlapping(st *targetState, t *storage.Target, result *storage.CheckResult) *FlapChange {
+	if c.flapWindow < 3 {
+		return nil
+	}
+	st.history = append(st.history, c.rawState(result))
+	if len(st.history) > c.flapWindow {
+		st.history = st.history[len(st.history)-c.flapWindow:]
+	}
+	if len(st.history) < c.flapWindow {
+		return nil
+	}
+
+	pct := percentStateChange(st.history)
+	switch {
+	case !st.flapping && pct >= c.flapHigh:
+		st.flapping = true
+	case st.flapping && pct < c.flapLow:
+		st.flapping = false
+	default:
+		return nil
+	}
+	return &FlapChange{Target: t, Flapping: st.flapping, State: st.state, PercentChange: pct, At: result.CheckedAt}
+}
+
+func (c *Checker) rawState(result *storage.CheckResult) State {
+	if result.Failed() {
+		return StateDown
+	}
+	if c.degradedLatency > 0 && time.Duration(result.LatencyMs)*time.Millisecond >= c.degradedLatency {
+		return StateDegraded
+	}
+	return StateUp
+}
+
+// percentStateChange weights changes linearly from 0.75 for the oldest to
+// 1.25 for the newest, as Nagios does, so recent changes count for more.
+func percentStateChange(history []State) float64 {
+	n := len(history)
+	var changes float64
+	for i := 1; i < n; i++ {
+		if history[i] != history[i-1] {
+			changes += 0.75 + float64(i-1)*0.5/float64(n-2)
+		}
+	}
+	return changes * 100 / float64(n-1)
+}
+
+func (c *Checker) advance(ctx context.Context, st *targetState, t *storage.Target, result *storage.CheckResult) *Transition {
+	if result.Failed() {
+		st.successes = 0
+		if st.state == StateDown {
+			return nil
+		}
+		st.failures = append(st.failures, result)
+		if len(st.failures) < c.failThreshold {
+			return nil
+		}
+
+		inc := &storage.Incident{
+			TargetID:       t.ID,
+			StartedAt:      st.failures[0].CheckedAt,
+			FirstError:     describeFailure(st.failures[0]),
+			TriggerResults: st.failures,
+		}
+		if err := c.storage.CreateIncident(ctx, inc); err != nil {
+			slog.ErrorContext(ctx, "creating incident", "target_id", t.ID, "err", err)
+			return nil
+		}
+		tr := &Transition{Target: t, From: st.state, To: StateDown, At: result.CheckedAt, Result: result, Incident: inc}
+		st.state = StateDown
+		st.incident = inc
+		st.failures = nil
+		return tr
+	}
+
+	st.failures = nil
+	if st.state == StateDown {
+		st.successes++
+		if st.successes < c.recoverThreshold {
+			return nil
+		}
+	}
+	st.successes = 0
+
+	next := StateUp
+	if c.degradedLatency > 0 && time.Duration(result.LatencyMs)*time.Millisecond >= c.degradedLatency {
+		next = StateDegraded
+	}
+	if st.state == next {
+		return nil
+	}
+
+	tr := &Transition{Target: t, From: st.state, To: next, At: result.CheckedAt, Result: result}
+	if st.incident != nil {
+		if err := c.storage.ResolveIncident(ctx, st.incident.ID, result.CheckedAt); err != nil {
+			slog.ErrorContext(ctx, "resolving incident", "target_id", t.ID, "incident_id", st.incident.ID, "err", err)
+			return nil
+		}
+		st.incident.EndedAt = result.CheckedAt
+		st.incident.Duration = result.CheckedAt.Sub(st.incident.StartedAt)
+		tr.Incident = st.incident
+		st.incident = nil
+	}
+	st.state = next
+	return tr
+}
+
+func describeFailure(r *storage.CheckResult) string {
+	if r.Error != "" {
+		return r.Error
+	}
+	return fmt.Sprintf("HTTP %d", r.StatusCode)
+}
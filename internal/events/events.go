@@ -0,0 +1,200 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/checker"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+)
+
+const (
+	TypeResult   = "result"
+	TypeState    = "state"
+	TypeFlapping = "flapping"
+)
+
+// Event is a check result, state transition or flapping change published by
+// the checker. IDs increase by one per event within a process.
+type Event struct {
+	ID       uint64
+	Type     string
+	At       time.Time
+	Target   *storage.Target
+	Result   *storage.CheckResult
+	From     string
+	To       string
+	Incident *storage.Incident
+	Flapping bool
+	// PercentChange is set on flapping events.
+	PercentChange float64
+}
+
+type subscriber struct {
+	ch         chan *Event
+	filter     func(*Event) bool
+	dropOldest bool
+	dropped    atomic.Uint64
+}
+
+// Broker fans checker events out to subscribers. It keeps the most recent
+// events so that a reconnecting client can resume after the last ID it saw.
+// Publishing never blocks: a subscriber whose buffer is full is dropped and
+// has to resubscribe, unless it asked to lose its oldest events instead.
+type Broker struct {
+	mu     sync.Mutex
+	nextID uint64
+	recent []*Event
+	size   int
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+// NewBroker keeps the last history events for resumption.
+func NewBroker(history int) *Broker {
+	return &Broker{nextID: 1, size: history, subs: map[*subscriber]struct{}{}}
+}
+
+func (b *Broker) ResultSaved(t *storage.Target, r *storage.CheckResult) {
+	b.Publish(&Event{Type: TypeResult, At: r.CheckedAt, Target: t, Result: r})
+}
+
+func (b *Broker) StateChanged(tr checker.Transition) {
+	b.Publish(&Event{Type: TypeState, At: tr.At, Target: tr.Target, Result: tr.Result,
+		From: string(tr.From), To: string(tr.To), Incident: tr.Incident, Flapping: tr.Flapping})
+}
+
+func (b *Broker) FlappingChanged(fc checker.FlapChange) {
+	b.Publish(&Event{Type: TypeFlapping, At: fc.At, Target: fc.Target, To: string(fc.State),
+		Flapping: fc.Flapping, PercentChange: fc.PercentChange})
+}
+
+func (b *Broker) TargetRemoved(targetID string) {}
+
+// Publish assigns ev the next ID and delivers it to matching subscribers.
+func (b *Broker) Publish(ev *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	ev.ID = b.nextID
+	b.nextID++
+	if b.size > 0 {
+		if len(b.recent) == b.size {
+			b.recent = append(b.recent[:0], b.recent[1:]...)
+		}
+		b.recent = append(b.recent, ev)
+	}
+
+	for sub := range b.subs {
+		if !sub.filter(ev) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+			continue
+		default:
+		}
+		if !sub.dropOldest {
+			delete(b.subs, sub)
+			close(sub.ch)
+			continue
+		}
+		// Only Publish sends, under b.mu, so after taking one event off the
+		// full buffer there is room for ev.
+		select {
+		case <-sub.ch:
+			sub.dropped.Add(1)
+		default:
+		}
+		sub.ch <- ev
+	}
+}
+
+// Subscribe returns buffered events after lastID that match filter, followed
+// on the channel by every new match. The channel is closed when the
+// subscriber falls behind by more than buffer events, on cancel, or when the
+// broker is closed.
+func (b *Broker) Subscribe(filter func(*Event) bool, lastID uint64, buffer int) ([]*Event, <-chan *Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []*Event
+	if lastID > 0 {
+		for _, ev := range b.recent {
+			if ev.ID > lastID && filter(ev) {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	sub := &subscriber{ch: make(chan *Event, buffer), filter: filter}
+	if b.closed {
+		close(sub.ch)
+		return replay, sub.ch, func() {}
+	}
+	b.subs[sub] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return replay, sub.ch, cancel
+}
+
+// Subscription is a live feed that is never closed for being slow: when its
+// buffer is full the oldest queued event is discarded.
+type Subscription struct {
+	C   <-chan *Event
+	b   *Broker
+	sub *subscriber
+}
+
+// SubscribeDropOldest delivers new events matching filter. The filter is
+// called with the broker lock held and must not call back into the broker.
+func (b *Broker) SubscribeDropOldest(filter func(*Event) bool, buffer int) *Subscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub := &subscriber{ch: make(chan *Event, buffer), filter: filter, dropOldest: true}
+	if b.closed {
+		close(sub.ch)
+	} else {
+		b.subs[sub] = struct{}{}
+	}
+	return &Subscription{C: sub.ch, b: b, sub: sub}
+}
+
+// Dropped returns how many events were discarded so far.
+func (s *Subscription) Dropped() uint64 {
+	return s.sub.dropped.Load()
+}
+
+func (s *Subscription) Cancel() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	if _, ok := s.b.subs[s.sub]; ok {
+		delete(s.b.subs, s.sub)
+		close(s.sub.ch)
+	}
+}
+
+// Close ends every subscription so that streaming handlers return.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
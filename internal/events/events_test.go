@@ -0,0 +1,76 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func all(*Event) bool { return true }
+
+func TestBroker_ReplayAndHistory(t *testing.T) {
+	b := NewBroker(3)
+	target := &storage.Target{ID: "t_1"}
+	for i := 0; i < 5; i++ {
+		b.ResultSaved(target, &storage.CheckResult{CheckedAt: time.Now()})
+	}
+
+	replay, _, cancel := b.Subscribe(all, 0, 1)
+	cancel()
+	assert.Empty(t, replay, "no replay without a last ID")
+
+	replay, _, cancel = b.Subscribe(all, 1, 1)
+	cancel()
+	var ids []uint64
+	for _, ev := range replay {
+		ids = append(ids, ev.ID)
+	}
+	assert.Equal(t, []uint64{3, 4, 5}, ids, "only the last 3 events are kept")
+
+	replay, _, cancel = b.Subscribe(all, 4, 1)
+	cancel()
+	assert.Len(t, replay, 1)
+}
+
+func TestBroker_SlowSubscriberDropped(t *testing.T) {
+	b := NewBroker(0)
+	target := &storage.Target{ID: "t_1"}
+	_, slow, cancel := b.Subscribe(all, 0, 1)
+	defer cancel()
+	_, filtered, cancelFiltered := b.Subscribe(func(ev *Event) bool { return ev.Type == TypeState }, 0, 1)
+	defer cancelFiltered()
+
+	b.ResultSaved(target, &storage.CheckResult{})
+	b.ResultSaved(target, &storage.CheckResult{})
+
+	ev, ok := <-slow
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), ev.ID)
+	_, ok = <-slow
+	assert.False(t, ok, "subscriber that fell behind is closed")
+
+	b.Close()
+	_, ok = <-filtered
+	assert.False(t, ok, "close ends remaining subscriptions")
+	b.ResultSaved(target, &storage.CheckResult{})
+}
+
+func TestBroker_DropOldest(t *testing.T) {
+	b := NewBroker(0)
+	target := &storage.Target{ID: "t_1"}
+	sub := b.SubscribeDropOldest(all, 2)
+	defer sub.Cancel()
+
+	for i := 0; i < 5; i++ {
+		b.ResultSaved(target, &storage.CheckResult{})
+	}
+	assert.Equal(t, uint64(3), sub.Dropped())
+	assert.Equal(t, uint64(4), (<-sub.C).ID)
+	assert.Equal(t, uint64(5), (<-sub.C).ID)
+
+	sub.Cancel()
+	_, ok := <-sub.C
+	assert.False(t, ok)
+}
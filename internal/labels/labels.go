@@ -0,0 +1,125 @@
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,62}[A-Za-z0-9])?$`)
+
+type op int
+
+const (
+	opEquals op = iota
+	opNotEquals
+	opExists
+	opNotExists
+)
+
+type requirement struct {
+	key   string
+	op    op
+	value string
+}
+
+// Selector is a comma-separated list of requirements that must all hold:
+// "env=prod", "tier!=internal", "team" (key present) or "!canary" (key absent).
+// The empty selector matches everything.
+type Selector struct {
+	reqs []requirement
+}
+
+func Parse(raw string) (Selector, error) {
+	var sel Selector
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var req requirement
+		switch {
+		case strings.Contains(part, "!="):
+			kv := strings.SplitN(part, "!=", 2)
+			req = requirement{key: strings.TrimSpace(kv[0]), op: opNotEquals, value: strings.TrimSpace(kv[1])}
+		case strings.Contains(part, "="):
+			kv := strings.SplitN(strings.Replace(part, "==", "=", 1), "=", 2)
+			req = requirement{key: strings.TrimSpace(kv[0]), op: opEquals, value: strings.TrimSpace(kv[1])}
+		case strings.HasPrefix(part, "!"):
+			req = requirement{key: strings.TrimSpace(part[1:]), op: opNotExists}
+		default:
+			req = requirement{key: part, op: opExists}
+		}
+		if !keyPattern.MatchString(req.key) {
+			return Selector{}, fmt.Errorf("invalid label key %q", req.key)
+		}
+		sel.reqs = append(sel.reqs, req)
+	}
+	return sel, nil
+}
+
+func (s Selector) Empty() bool {
+	return len(s.reqs) == 0
+}
+
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s.reqs {
+		v, ok := labels[req.key]
+		switch req.op {
+		case opEquals:
+			if !ok || v != req.value {
+				return false
+			}
+		case opNotEquals:
+			if ok && v == req.value {
+				return false
+			}
+		case opExists:
+			if !ok {
+				return false
+			}
+		case opNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	var parts []string
+	for _, req := range s.reqs {
+		switch req.op {
+		case opEquals:
+			parts = append(parts, req.key+"="+req.value)
+		case opNotEquals:
+			parts = append(parts, req.key+"!="+req.value)
+		case opExists:
+			parts = append(parts, req.key)
+		case opNotExists:
+			parts = append(parts, "!"+req.key)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Validate checks that every key in labels is well formed.
+func Validate(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !keyPattern.MatchString(k) {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if len(labels[k]) > 63 {
+			return fmt.Errorf("label %q value too long", k)
+		}
+	}
+	return nil
+}
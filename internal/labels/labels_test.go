@@ -0,0 +1,46 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelector_Matches(t *testing.T) {
+	lbls := map[string]string{"env": "prod", "team": "payments"}
+
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env==prod", true},
+		{"env=staging", false},
+		{"env!=staging", true},
+		{"env=prod,team=payments", true},
+		{"env=prod,team=search", false},
+		{"team", true},
+		{"canary", false},
+		{"!canary", true},
+		{"!team", false},
+	}
+
+	for _, tt := range tests {
+		sel, err := Parse(tt.selector)
+		require.NoError(t, err, tt.selector)
+		assert.Equal(t, tt.matches, sel.Matches(lbls), tt.selector)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse("=prod")
+	assert.Error(t, err)
+	_, err = Parse("env=prod,bad key=x")
+	assert.Error(t, err)
+
+	sel, err := Parse(" env = prod , !canary ")
+	require.NoError(t, err)
+	assert.Equal(t, "env=prod,!canary", sel.String())
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "warn")
+	require.NoError(t, err)
+
+	logger.Info("dropped")
+	logger.Warn("kept", "target_id", "t_1")
+
+	var rec map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal(t, "kept", rec["msg"])
+	assert.Equal(t, "t_1", rec["target_id"])
+
+	_, err = New(&buf, "xml", "info")
+	assert.Error(t, err)
+	_, err = New(&buf, "text", "loud")
+	assert.Error(t, err)
+}
+
+func TestMiddleware_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	require.NoError(t, err)
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(prev)
+
+	var seen string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/targets", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "abc-123", seen)
+	assert.Equal(t, "abc-123", rec.Header().Get(RequestIDHeader))
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "http request", line["msg"])
+	assert.Equal(t, "abc-123", line["request_id"])
+	assert.Equal(t, float64(http.StatusTeapot), line["status"])
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/targets", nil)
+	req.Header.Set(RequestIDHeader, "has spaces")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.NotEqual(t, "has spaces", seen)
+	assert.Len(t, seen, 36)
+	assert.Equal(t, seen, rec.Header().Get(RequestIDHeader))
+}
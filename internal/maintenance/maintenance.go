@@ -0,0 +1,86 @@
+package maintenance
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+)
+
+// Manager answers whether a target is inside a maintenance window or covered
+// by a silence at a given time. It reads windows and silences from storage on
+// every call so API changes take effect immediately.
+type Manager struct {
+	storage storage.Storage
+}
+
+func NewManager(s storage.Storage) *Manager {
+	return &Manager{storage: s}
+}
+
+// Mode returns the mode of the maintenance window covering t at the given
+// time, or "" if there is none. Pause wins over suppress when both apply.
+// Only windows of t's tenant are considered.
+func (m *Manager) Mode(ctx context.Context, t *storage.Target, at time.Time) string {
+	ctx = storage.WithTenant(ctx, t.TenantID)
+	windows, err := m.storage.ListMaintenanceWindows(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "listing maintenance windows", "err", err)
+		return ""
+	}
+	mode := ""
+	for _, w := range windows {
+		if !covers(w.TargetID, w.Selector, t) || !Active(w, at) {
+			continue
+		}
+		if w.Mode == storage.MaintenancePause {
+			return storage.MaintenancePause
+		}
+		mode = w.Mode
+	}
+	return mode
+}
+
+// Suppressed reports whether alerts for t should be held back: the target is
+// in a maintenance window of either mode or matches an unexpired silence.
+func (m *Manager) Suppressed(ctx context.Context, t *storage.Target, at time.Time) bool {
+	if m.Mode(ctx, t, at) != "" {
+		return true
+	}
+	silences, err := m.storage.ListSilences(storage.WithTenant(ctx, t.TenantID), at)
+	if err != nil {
+		slog.ErrorContext(ctx, "listing silences", "err", err)
+		return false
+	}
+	for _, s := range silences {
+		if covers(s.TargetID, s.Selector, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether w is open at the given time.
+func Active(w *storage.MaintenanceWindow, at time.Time) bool {
+	if w.Schedule == "" {
+		return !at.Before(w.StartsAt) && at.Before(w.EndsAt)
+	}
+	sched, err := ParseSchedule(w.Schedule)
+	if err != nil {
+		return false
+	}
+	return sched.ActiveAt(at, w.Duration)
+}
+
+func covers(targetID, selector string, t *storage.Target) bool {
+	if targetID != "" {
+		return targetID == t.ID
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return false
+	}
+	return sel.Matches(t.Labels)
+}
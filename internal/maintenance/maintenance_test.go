@@ -0,0 +1,92 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "*/15 2-4 1,15 JAN-JUN mon-fri", "0 0 * * 7", "30 3 * * SUN"} {
+		_, err := ParseSchedule(expr)
+		assert.NoError(t, err, expr)
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * FOO *"} {
+		_, err := ParseSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	from := time.Date(2024, 3, 9, 22, 17, 0, 0, time.UTC) // a Saturday
+	until := from.AddDate(1, 0, 0)
+
+	sched, err := ParseSchedule("0 2 * * SUN")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC), sched.Next(from, until))
+
+	sched, err = ParseSchedule("*/20 * * * *")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 9, 22, 20, 0, 0, time.UTC), sched.Next(from, until))
+
+	// Day of month and day of week are ORed when both are restricted.
+	sched, err = ParseSchedule("0 0 15 * MON")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), sched.Next(from, until))
+
+	// The next leap day is out of range.
+	sched, err = ParseSchedule("0 0 29 2 *")
+	require.NoError(t, err)
+	assert.True(t, sched.Next(from, until).IsZero())
+}
+
+func TestSchedule_ActiveAt(t *testing.T) {
+	sched, err := ParseSchedule("0 2 * * SUN")
+	require.NoError(t, err)
+
+	sunday := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	assert.False(t, sched.ActiveAt(sunday.Add(time.Hour+59*time.Minute), 2*time.Hour))
+	assert.True(t, sched.ActiveAt(sunday.Add(2*time.Hour), 2*time.Hour))
+	assert.True(t, sched.ActiveAt(sunday.Add(3*time.Hour+59*time.Minute), 2*time.Hour))
+	assert.False(t, sched.ActiveAt(sunday.Add(4*time.Hour), 2*time.Hour))
+	assert.False(t, sched.ActiveAt(sunday.AddDate(0, 0, 1).Add(2*time.Hour), 2*time.Hour))
+}
+
+func TestManager(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	m := NewManager(s)
+	now := time.Now().UTC()
+
+	db, _, err := s.CreateTarget(ctx, "https://db.example.com", "")
+	require.NoError(t, err)
+	db, err = s.UpdateTargetLabels(ctx, db.ID, map[string]string{"tier": "db"})
+	require.NoError(t, err)
+	web, _, err := s.CreateTarget(ctx, "https://www.example.com", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "", m.Mode(ctx, db, now))
+	assert.False(t, m.Suppressed(ctx, db, now))
+
+	require.NoError(t, s.CreateMaintenanceWindow(ctx, &storage.MaintenanceWindow{
+		Name: "db upgrade", Mode: storage.MaintenanceSuppress, Selector: "tier=db",
+		StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)}))
+	assert.Equal(t, storage.MaintenanceSuppress, m.Mode(ctx, db, now))
+	assert.Equal(t, "", m.Mode(ctx, db, now.Add(2*time.Hour)))
+	assert.Equal(t, "", m.Mode(ctx, web, now))
+	assert.True(t, m.Suppressed(ctx, db, now))
+
+	require.NoError(t, s.CreateMaintenanceWindow(ctx, &storage.MaintenanceWindow{
+		Name: "always", Mode: storage.MaintenancePause, TargetID: db.ID, Schedule: "* * * * *", Duration: time.Minute}))
+	assert.Equal(t, storage.MaintenancePause, m.Mode(ctx, db, now))
+
+	require.NoError(t, s.CreateSilence(ctx, &storage.Silence{TargetID: web.ID, ExpiresAt: now.Add(time.Hour)}))
+	assert.True(t, m.Suppressed(ctx, web, now))
+	assert.Equal(t, "", m.Mode(ctx, web, now))
+	assert.False(t, m.Suppressed(ctx, web, now.Add(2*time.Hour)))
+}
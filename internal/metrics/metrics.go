@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/checker"
+	"github.com/AlanZeng-Coder/linkwatch/internal/recorder"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// CheckerStats exposes the checker's work queue.
+type CheckerStats interface {
+	QueueDepth() int
+	InFlight() int
+}
+
+// Metrics owns a Prometheus registry with the checker, per-target and HTTP
+// API metrics. It is a checker.Listener, so per-target gauges follow every
+// saved result.
+type Metrics struct {
+	registry        *prometheus.Registry
+	targetUp        *prometheus.GaugeVec
+	statusCode      *prometheus.GaugeVec
+	latency         *prometheus.GaugeVec
+	certExpiry      *prometheus.GaugeVec
+	checks          *prometheus.CounterVec
+	checkDuration   prometheus.Histogram
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	now             func() time.Time
+}
+
+func New() *Metrics {
+	targetLabels := []string{"target_id"}
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		targetUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "linkwatch_target_up",
+			Help: "Whether the last check of the target succeeded (1) or failed (0).",
+		}, targetLabels),
+		statusCode: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "linkwatch_target_last_status_code",
+			Help: "HTTP status code of the last check, 0 on network errors.",
+		}, targetLabels),
+		latency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "linkwatch_target_last_latency_seconds",
+			Help: "Latency of the last check.",
+		}, targetLabels),
+		certExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "linkwatch_target_cert_expiry_seconds",
+			Help: "Seconds until the target's TLS certificate expires, as seen by the last HTTPS check.",
+		}, targetLabels),
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "linkwatch_checks_total",
+			Help: "Completed checks by outcome (success, http_error, network_error).",
+		}, []string{"outcome"}),
+		checkDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "linkwatch_check_duration_seconds",
+			Help:    "Latency of completed checks.",
+			Buckets: []float64{.025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "linkwatch_http_requests_total",
+			Help: "HTTP API requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "linkwatch_http_request_duration_seconds",
+			Help:    "HTTP API request latency by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		now: time.Now,
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.targetUp, m.statusCode, m.latency, m.certExpiry,
+		m.checks, m.checkDuration, m.requests, m.requestDuration,
+	)
+	return m
+}
+
+// RegisterChecker adds gauges for the checker's queue depth and in-flight
+// checks.
+func (m *Metrics) RegisterChecker(c CheckerStats) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "linkwatch_checker_queue_depth",
+			Help: "Targets waiting to be checked in the current cycle.",
+		}, func() float64 { return float64(c.QueueDepth()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "linkwatch_checker_in_flight",
+			Help: "Checks currently running.",
+		}, func() float64 { return float64(c.InFlight()) }),
+	)
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ResultSaved(t *storage.Target, r *storage.CheckResult) {
+	up, outcome := 1.0, "success"
+	switch {
+	case r.Error != "" || r.StatusCode == 0:
+		up, outcome = 0, "network_error"
+	case r.Failed():
+		up, outcome = 0, "http_error"
+	}
+	m.checks.WithLabelValues(outcome).Inc()
+	m.checkDuration.Observe(float64(r.LatencyMs) / 1000)
+
+	m.targetUp.WithLabelValues(t.ID).Set(up)
+	m.statusCode.WithLabelValues(t.ID).Set(float64(r.StatusCode))
+	m.latency.WithLabelValues(t.ID).Set(float64(r.LatencyMs) / 1000)
+	if !r.CertExpiresAt.IsZero() {
+		m.certExpiry.WithLabelValues(t.ID).Set(r.CertExpiresAt.Sub(m.now()).Seconds())
+	}
+}
+
+func (m *Metrics) StateChanged(tr checker.Transition) {}
+
+func (m *Metrics) FlappingChanged(fc checker.FlapChange) {}
+
+// TargetRemoved stops exporting the series of a deleted target.
+func (m *Metrics) TargetRemoved(targetID string) {
+	for _, g := range []*prometheus.GaugeVec{m.targetUp, m.statusCode, m.latency, m.certExpiry} {
+		g.DeleteLabelValues(targetID)
+	}
+}
+
+// Instrument records request count and latency for h under route, which
+// should be the mux pattern rather than the raw path to keep label
+// cardinality bounded.
+func (m *Metrics) Instrument(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := recorder.New(w)
+		h(rec, r)
+		m.requests.WithLabelValues(route, r.Method, strconv.Itoa(rec.Status)).Inc()
+		m.requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	}
+}
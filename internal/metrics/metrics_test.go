@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stats struct{ queued, inFlight int }
+
+func (s stats) QueueDepth() int { return s.queued }
+func (s stats) InFlight() int   { return s.inFlight }
+
+func scrape(t *testing.T, m *Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics_Targets(t *testing.T) {
+	m := New()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	m.RegisterChecker(stats{queued: 7, inFlight: 2})
+
+	target := &storage.Target{ID: "t_1", URL: "https://example.com"}
+	m.ResultSaved(target, &storage.CheckResult{StatusCode: 200, LatencyMs: 250, CertExpiresAt: now.Add(time.Hour)})
+	m.ResultSaved(target, &storage.CheckResult{StatusCode: 503, LatencyMs: 40, CertExpiresAt: now.Add(time.Hour)})
+	m.ResultSaved(&storage.Target{ID: "t_2", URL: "http://down.example"}, &storage.CheckResult{Error: "connection refused"})
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `linkwatch_target_up{target_id="t_1"} 0`)
+	assert.Contains(t, out, `linkwatch_target_last_status_code{target_id="t_1"} 503`)
+	assert.Contains(t, out, `linkwatch_target_last_latency_seconds{target_id="t_1"} 0.04`)
+	assert.Contains(t, out, `linkwatch_target_cert_expiry_seconds{target_id="t_1"} 3600`)
+	assert.NotContains(t, out, `linkwatch_target_cert_expiry_seconds{target_id="t_2"`)
+	assert.Contains(t, out, `linkwatch_checks_total{outcome="success"} 1`)
+	assert.Contains(t, out, `linkwatch_checks_total{outcome="http_error"} 1`)
+	assert.Contains(t, out, `linkwatch_checks_total{outcome="network_error"} 1`)
+	assert.Contains(t, out, `linkwatch_check_duration_seconds_count 3`)
+	assert.Contains(t, out, `linkwatch_checker_queue_depth 7`)
+	assert.Contains(t, out, `linkwatch_checker_in_flight 2`)
+
+	m.TargetRemoved("t_1")
+	out = scrape(t, m)
+	assert.NotContains(t, out, `target_id="t_1"`)
+	assert.Contains(t, out, `linkwatch_target_up{target_id="t_2"} 0`)
+}
+
+func TestMetrics_Instrument(t *testing.T) {
+	m := New()
+	h := m.Instrument("/v1/targets/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "target not found", http.StatusNotFound)
+	})
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/targets/t_1", nil))
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/targets/t_2", nil))
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `linkwatch_http_requests_total{code="404",method="GET",route="/v1/targets/"} 2`)
+	assert.Contains(t, out, `linkwatch_http_request_duration_seconds_count{method="GET",route="/v1/targets/"} 2`)
+}
@@ -0,0 +1,207 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultEmailSubject = `[linkwatch] {{if .Test}}[TEST] {{end}}{{if .AlertID}}[{{upper .Severity}}] {{.Rule}} {{.State}} on {{.URL}}{{else if eq .Type "target.flapping"}}{{.URL}} is FLAPPING{{else if eq .Type "target.flapping_stopped"}}{{.URL}} stopped flapping{{else}}{{.URL}} is {{upper .State}}{{end}}`
+	DefaultEmailBody    = `{{if .AlertID}}Alert "{{.Rule}}" is {{.State}}: {{.Summary}}{{else if eq .Type "target.flapping"}}{{.URL}} is flapping; individual state changes are not reported until it settles.{{else if eq .Type "target.flapping_stopped"}}{{.URL}} stopped flapping and is {{.State}}.{{else if eq .State "up"}}{{.URL}} has recovered.{{else}}{{.URL}} is {{.State}}.{{end}}
+
+Target:      {{.URL}} ({{.TargetID}})
+{{- if .PreviousState}}
+State:       {{.PreviousState}} -> {{.State}}
+{{- end}}
+Time:        {{.Timestamp.UTC.Format "2006-01-02 15:04:05 MST"}}
+{{- if .StatusCode}}
+Status code: {{.StatusCode}}
+{{- end}}
+{{- if .Error}}
+Error:       {{.Error}}
+{{- end}}
+{{- if .PercentStateChange}}
+State change: {{printf "%.1f" .PercentStateChange}}%
+{{- end}}
+{{- if .IncidentDurationMs}}
+Incident duration: {{duration .IncidentDurationMs}}
+{{- end}}
+
+--
+Sent by linkwatch
+`
+)
+
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	// StartTLS requires the server to offer STARTTLS. When false the
+	// connection is still upgraded if the server supports it.
+	StartTLS bool
+}
+
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"duration": func(ms int64) string {
+		return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+	},
+}
+
+func (n *Notifier) SetSMTP(cfg SMTPConfig) {
+	n.smtp = cfg
+}
+
+func (n *Notifier) SetEmailTemplates(subject, body string) error {
+	st, err := template.New("subject").Funcs(templateFuncs).Parse(subject)
+	if err != nil {
+		return fmt.Errorf("subject template: %w", err)
+	}
+	bt, err := template.New("body").Funcs(templateFuncs).Parse(body)
+	if err != nil {
+		return fmt.Errorf("body template: %w", err)
+	}
+	n.emailSubject, n.emailBody = st, bt
+	return nil
+}
+
+func (n *Notifier) smtpFrom() string {
+	if n.smtp.From == "" {
+		return "linkwatch@localhost"
+	}
+	return n.smtp.From
+}
+
+// parseRecipients extracts the addresses from a "mailto:a@example.com,b@example.com" URL.
+func parseRecipients(raw string) ([]string, error) {
+	if !strings.HasPrefix(raw, "mailto:") {
+		return nil, errors.New("email channel url must start with mailto:")
+	}
+	list, err := mail.ParseAddressList(strings.TrimPrefix(raw, "mailto:"))
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, a := range list {
+		addrs = append(addrs, a.Address)
+	}
+	return addrs, nil
+}
+
+func (n *Notifier) renderEmail(ch *storage.Channel, ev *Event) (*storage.Notification, error) {
+	recipients, err := parseRecipients(ch.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject, body bytes.Buffer
+	if err := n.emailSubject.Execute(&subject, ev); err != nil {
+		return nil, err
+	}
+	if err := n.emailBody.Execute(&body, ev); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.smtpFrom())
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.Join(strings.Fields(subject.String()), " ")))
+	fmt.Fprintf(&msg, "Date: %s\r\n", ev.Timestamp.UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@linkwatch>\r\n", uuid.NewString())
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&msg)
+	qp.Write([]byte(strings.ReplaceAll(body.String(), "\n", "\r\n")))
+	qp.Close()
+
+	return &storage.Notification{
+		TargetID:  ev.TargetID,
+		ChannelID: ch.ID,
+		Event:     ev.Type,
+		URL:       ch.URL,
+		Headers:   map[string]string{},
+		Payload:   msg.String(),
+	}, nil
+}
+
+func (n *Notifier) sendEmail(ctx context.Context, ntf *storage.Notification) error {
+	if n.smtp.Addr == "" {
+		return errors.New("smtp is not configured")
+	}
+	recipients, err := parseRecipients(ntf.URL)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(n.smtp.Addr)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: n.client.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.smtp.Addr)
+	if err != nil {
+		return err
+	}
+	if n.client.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(n.client.Timeout))
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	} else if n.smtp.StartTLS {
+		return errors.New("smtp server does not support STARTTLS")
+	}
+	if n.smtp.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.smtp.Username, n.smtp.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	from := n.smtpFrom()
+	if addr, err := mail.ParseAddress(from); err == nil {
+		from = addr.Address
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write([]byte(ntf.Payload)); err != nil {
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,221 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+)
+
+type fact struct {
+	name  string
+	value string
+}
+
+// render turns ev into an outbox entry addressed to ch, using the payload
+// format the channel type expects. It returns nil when the channel has no
+// use for the event.
+func (n *Notifier) render(ch *storage.Channel, ev *Event) (*storage.Notification, error) {
+	var body interface{}
+	switch ch.Type {
+	case storage.ChannelEmail:
+		return n.renderEmail(ch, ev)
+	case storage.ChannelPagerDuty:
+		return pagerDutyNotification(ch, ev)
+	case storage.ChannelOpsgenie:
+		return opsgenieNotification(ch, ev)
+	case storage.ChannelWebhook:
+		body = ev
+	case storage.ChannelSlack:
+		body = slackPayload(ev)
+	case storage.ChannelTeams:
+		body = teamsPayload(ev)
+	case storage.ChannelDiscord:
+		body = discordPayload(ev)
+	default:
+		return nil, fmt.Errorf("unsupported channel type %q", ch.Type)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	if ch.Type == storage.ChannelWebhook {
+		headers["X-Linkwatch-Event"] = ev.Type
+		headers["X-Linkwatch-Target"] = ev.TargetID
+		if ch.Secret != "" {
+			headers[SignatureHeader] = Sign(ch.Secret, payload)
+		}
+	}
+	return &storage.Notification{
+		TargetID:  ev.TargetID,
+		ChannelID: ch.ID,
+		Event:     ev.Type,
+		URL:       ch.URL,
+		Headers:   headers,
+		Payload:   string(payload),
+	}, nil
+}
+
+func title(ev *Event) string {
+	var t string
+	switch {
+	case ev.AlertID != "" && ev.State == storage.AlertResolved:
+		t = fmt.Sprintf("[RESOLVED] %s: %s", ev.Rule, ev.URL)
+	case ev.AlertID != "":
+		t = fmt.Sprintf("[%s] %s: %s", strings.ToUpper(ev.Severity), ev.Rule, ev.Summary)
+	case ev.Type == "target.flapping":
+		t = fmt.Sprintf("%s is FLAPPING", ev.URL)
+	case ev.Type == "target.flapping_stopped":
+		t = fmt.Sprintf("%s stopped flapping and is %s", ev.URL, strings.ToUpper(ev.State))
+	default:
+		t = fmt.Sprintf("%s is %s", ev.URL, strings.ToUpper(ev.State))
+	}
+	if ev.Test {
+		t = "[TEST] " + t
+	}
+	return t
+}
+
+func color(ev *Event) int {
+	if ev.Type == "target.flapping" {
+		return 0xF9A825
+	}
+	state := ev.State
+	if ev.AlertID != "" && state == storage.AlertFiring {
+		state = ev.Severity
+	}
+	switch state {
+	case "down", "critical", "error":
+		return 0xD32F2F
+	case "degraded", "warning":
+		return 0xF9A825
+	case "info":
+		return 0x1976D2
+	default:
+		return 0x2E7D32
+	}
+}
+
+func facts(ev *Event) []fact {
+	fs := []fact{{"Target", ev.TargetID}}
+	if ev.PreviousState != "" {
+		fs = append(fs, fact{"Previous state", ev.PreviousState})
+	}
+	if ev.Severity != "" {
+		fs = append(fs, fact{"Severity", ev.Severity})
+	}
+	if ev.Summary != "" {
+		fs = append(fs, fact{"Summary", ev.Summary})
+	}
+	if ev.StatusCode != 0 {
+		fs = append(fs, fact{"Status code", fmt.Sprint(ev.StatusCode)})
+	}
+	if ev.LatencyMs != 0 {
+		fs = append(fs, fact{"Latency", fmt.Sprintf("%d ms", ev.LatencyMs)})
+	}
+	if ev.Error != "" {
+		fs = append(fs, fact{"Error", ev.Error})
+	}
+	if ev.IncidentDurationMs != 0 {
+		fs = append(fs, fact{"Incident duration", (time.Duration(ev.IncidentDurationMs) * time.Millisecond).String()})
+	}
+	return fs
+}
+
+// Slack and Discord reject a whole message when one of these is too long, so
+// long URLs and errors are cut to fit.
+const (
+	slackHeaderLen      = 150
+	slackTextLen        = 3000
+	slackFieldLen       = 2000
+	discordTitleLen     = 256
+	discordFieldNameLen = 256
+	discordFieldLen     = 1024
+)
+
+func slackPayload(ev *Event) map[string]interface{} {
+	var fields []map[string]string
+	for _, f := range facts(ev) {
+		fields = append(fields, map[string]string{"type": "mrkdwn", "text": truncate("*"+f.name+"*\n"+f.value, slackFieldLen)})
+	}
+	return map[string]interface{}{
+		"text": title(ev),
+		"blocks": []interface{}{
+			map[string]interface{}{
+				"type": "header",
+				"text": map[string]string{"type": "plain_text", "text": truncate(title(ev), slackHeaderLen)},
+			},
+			map[string]interface{}{
+				"type":   "section",
+				"text":   map[string]string{"type": "mrkdwn", "text": truncate("<"+ev.URL+">", slackTextLen)},
+				"fields": fields,
+			},
+			map[string]interface{}{
+				"type": "context",
+				"elements": []map[string]string{
+					{"type": "mrkdwn", "text": "linkwatch · " + ev.Timestamp.UTC().Format(time.RFC3339)},
+				},
+			},
+		},
+	}
+}
+
+func teamsPayload(ev *Event) map[string]interface{} {
+	var fs []map[string]string
+	for _, f := range facts(ev) {
+		fs = append(fs, map[string]string{"name": f.name, "value": f.value})
+	}
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    title(ev),
+		"themeColor": fmt.Sprintf("%06X", color(ev)),
+		"title":      title(ev),
+		"sections": []map[string]interface{}{
+			{"activityTitle": ev.URL, "activitySubtitle": ev.Timestamp.UTC().Format(time.RFC3339), "facts": fs},
+		},
+		"potentialAction": []map[string]interface{}{
+			{
+				"@type":   "OpenUri",
+				"name":    "Open target",
+				"targets": []map[string]string{{"os": "default", "uri": ev.URL}},
+			},
+		},
+	}
+}
+
+func discordPayload(ev *Event) map[string]interface{} {
+	var fields []map[string]interface{}
+	for _, f := range facts(ev) {
+		fields = append(fields, map[string]interface{}{
+			"name":   truncate(f.name, discordFieldNameLen),
+			"value":  truncate(f.value, discordFieldLen),
+			"inline": f.name != "Error",
+		})
+	}
+	return map[string]interface{}{
+		"username": "linkwatch",
+		"embeds": []map[string]interface{}{
+			{
+				"title":     truncate(title(ev), discordTitleLen),
+				"url":       ev.URL,
+				"color":     color(ev),
+				"timestamp": ev.Timestamp.UTC().Format(time.RFC3339),
+				"fields":    fields,
+				"footer":    map[string]string{"text": "linkwatch"},
+			},
+		},
+	}
+}
+
+// truncate cuts s to at most n characters, never inside a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,360 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/checker"
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/google/uuid"
+)
+
+const SignatureHeader = "X-Linkwatch-Signature"
+
+type Webhook struct {
+	URL    string
+	Secret string
+}
+
+type Event struct {
+	ID                 string            `json:"id"`
+	Type               string            `json:"event"`
+	TargetID           string            `json:"target_id"`
+	URL                string            `json:"url"`
+	State              string            `json:"state"`
+	PreviousState      string            `json:"previous_state"`
+	Timestamp          time.Time         `json:"timestamp"`
+	StatusCode         int               `json:"status_code"`
+	LatencyMs          int               `json:"latency_ms"`
+	Error              string            `json:"error,omitempty"`
+	IncidentID         string            `json:"incident_id,omitempty"`
+	IncidentDurationMs int64             `json:"incident_duration_ms,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	AlertID            string            `json:"alert_id,omitempty"`
+	Rule               string            `json:"rule,omitempty"`
+	Severity           string            `json:"severity,omitempty"`
+	Summary            string            `json:"summary,omitempty"`
+	PercentStateChange float64           `json:"percent_state_change,omitempty"`
+	Test               bool              `json:"test,omitempty"`
+}
+
+// Suppressor reports whether alerts for a target are currently held back by
+// a maintenance window or silence.
+type Suppressor interface {
+	Suppressed(ctx context.Context, t *storage.Target, at time.Time) bool
+}
+
+type Notifier struct {
+	storage      storage.Storage
+	webhooks     []Webhook
+	client       *http.Client
+	pollInterval time.Duration
+	maxAttempts  int
+	retryBackoff time.Duration
+	smtp         SMTPConfig
+	emailSubject *template.Template
+	emailBody    *template.Template
+	suppressor   Suppressor
+	ctx          context.Context
+	cancel       context.CancelFunc
+}
+
+func NewNotifier(s storage.Storage, webhooks []Webhook, timeout time.Duration) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Notifier{
+		storage:      s,
+		webhooks:     webhooks,
+		client:       &http.Client{Timeout: timeout},
+		pollInterval: time.Second,
+		maxAttempts:  5,
+		retryBackoff: 10 * time.Second,
+		emailSubject: template.Must(template.New("subject").Funcs(templateFuncs).Parse(DefaultEmailSubject)),
+		emailBody:    template.Must(template.New("body").Funcs(templateFuncs).Parse(DefaultEmailBody)),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// SetRetryPolicy controls how often a failed delivery is retried. The delay
+// before attempt n+1 is backoff * 2^(n-1).
+func (n *Notifier) SetRetryPolicy(maxAttempts int, backoff time.Duration) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	n.maxAttempts = maxAttempts
+	n.retryBackoff = backoff
+}
+
+// SetSuppressor drops events for targets that s reports as suppressed.
+// Channel tests are never suppressed.
+func (n *Notifier) SetSuppressor(s Suppressor) {
+	n.suppressor = s
+}
+
+func (n *Notifier) Start() {
+	ticker := time.NewTicker(n.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+			n.deliverDue()
+		}
+	}
+}
+
+func (n *Notifier) Stop() {
+	n.cancel()
+}
+
+func (n *Notifier) ResultSaved(t *storage.Target, r *storage.CheckResult) {}
+
+func (n *Notifier) StateChanged(tr checker.Transition) {
+	// Every target reports unknown -> up on its first check after startup;
+	// that is not news to anyone.
+	if tr.From == checker.StateUnknown && tr.To == checker.StateUp {
+		return
+	}
+	// Flapping targets get a single start/stop notification instead.
+	if tr.Flapping {
+		return
+	}
+
+	n.Notify(eventFromTransition(tr), tr.Target, nil)
+}
+
+func (n *Notifier) FlappingChanged(fc checker.FlapChange) {
+	typ := "target.flapping"
+	if !fc.Flapping {
+		typ = "target.flapping_stopped"
+	}
+	n.Notify(&Event{
+		ID:                 "evt_" + uuid.NewString(),
+		Type:               typ,
+		TargetID:           fc.Target.ID,
+		URL:                fc.Target.URL,
+		State:              string(fc.State),
+		Timestamp:          fc.At,
+		Labels:             fc.Target.Labels,
+		PercentStateChange: fc.PercentChange,
+	}, fc.Target, nil)
+}
+
+func (n *Notifier) TargetRemoved(targetID string) {}
+
+// Notify queues ev for delivery to the given channels, or to every channel
+// routed to t when channelIDs is empty.
+func (n *Notifier) Notify(ev *Event, t *storage.Target, channelIDs []string) {
+	if n.suppressor != nil && n.suppressor.Suppressed(n.ctx, t, ev.Timestamp) {
+		slog.Info("suppressed notification", "event", ev.Type, "target_id", t.ID)
+		return
+	}
+
+	ctx := storage.WithTenant(n.ctx, t.TenantID)
+	var channels []*storage.Channel
+	if len(channelIDs) == 0 {
+		var err error
+		channels, err = n.channelsFor(ctx, t)
+		if err != nil {
+			slog.Error("loading channels", "err", err)
+			return
+		}
+	}
+	for _, id := range channelIDs {
+		ch, err := n.storage.GetChannel(ctx, id)
+		if err != nil {
+			slog.Error("loading channel", "channel_id", id, "err", err)
+			continue
+		}
+		channels = append(channels, ch)
+	}
+
+	for _, ch := range channels {
+		ntf, err := n.render(ch, ev)
+		if err != nil {
+			slog.Error("rendering notification", "channel_id", ch.ID, "event", ev.Type, "err", err)
+			continue
+		}
+		if ntf == nil {
+			continue
+		}
+		if err := n.storage.EnqueueNotification(n.ctx, ntf); err != nil {
+			slog.Error("enqueueing notification", "channel_id", ch.ID, "event", ev.Type, "err", err)
+		}
+	}
+}
+
+// channelsFor returns the statically configured webhooks, which receive
+// alerts for every tenant, plus every channel of t's tenant routed to t.
+func (n *Notifier) channelsFor(ctx context.Context, t *storage.Target) ([]*storage.Channel, error) {
+	var channels []*storage.Channel
+	for _, wh := range n.webhooks {
+		channels = append(channels, &storage.Channel{Type: storage.ChannelWebhook, URL: wh.URL, Secret: wh.Secret})
+	}
+
+	stored, err := n.storage.ListChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range stored {
+		if routes(ch, t) {
+			channels = append(channels, ch)
+		}
+	}
+	return channels, nil
+}
+
+func routes(ch *storage.Channel, t *storage.Target) bool {
+	if ch.TargetID != "" {
+		return ch.TargetID == t.ID
+	}
+	sel, err := labels.Parse(ch.Selector)
+	if err != nil {
+		return false
+	}
+	return sel.Matches(t.Labels)
+}
+
+// TestChannel synchronously sends a sample down alert to ch, bypassing the
+// outbox, and returns the receiver's status code. For PagerDuty and Opsgenie
+// this triggers a real alert that has to be resolved by hand.
+func (n *Notifier) TestChannel(ctx context.Context, ch *storage.Channel) (int, error) {
+	ev := &Event{
+		ID:            "evt_" + uuid.NewString(),
+		Type:          "target.down",
+		TargetID:      "t_sample",
+		URL:           "https://example.com",
+		State:         string(checker.StateDown),
+		PreviousState: string(checker.StateUp),
+		Timestamp:     time.Now().UTC(),
+		StatusCode:    http.StatusServiceUnavailable,
+		LatencyMs:     120,
+		Error:         "sample alert sent from linkwatch",
+		IncidentID:    "inc_test",
+		Test:          true,
+	}
+	ntf, err := n.render(ch, ev)
+	if err != nil {
+		return 0, err
+	}
+	if ntf == nil {
+		return 0, fmt.Errorf("channel type %q ignores sample alerts", ch.Type)
+	}
+	ntf.ID = "ntf_test"
+	return n.send(ctx, ntf)
+}
+
+func eventFromTransition(tr checker.Transition) *Event {
+	ev := &Event{
+		ID:            "evt_" + uuid.NewString(),
+		Type:          "target." + string(tr.To),
+		TargetID:      tr.Target.ID,
+		URL:           tr.Target.URL,
+		State:         string(tr.To),
+		PreviousState: string(tr.From),
+		Timestamp:     tr.At,
+		Labels:        tr.Target.Labels,
+	}
+	if tr.Result != nil {
+		ev.StatusCode = tr.Result.StatusCode
+		ev.LatencyMs = tr.Result.LatencyMs
+		ev.Error = tr.Result.Error
+	}
+	if tr.Incident != nil {
+		ev.IncidentID = tr.Incident.ID
+		if ev.Error == "" && tr.To == checker.StateDown {
+			ev.Error = tr.Incident.FirstError
+		}
+		if !tr.Incident.Open() {
+			ev.IncidentDurationMs = tr.Incident.Duration.Milliseconds()
+		}
+	}
+	return ev
+}
+
+// Sign returns the value of the signature header for body: the hex-encoded
+// HMAC-SHA256 of the raw request body, prefixed with "sha256=".
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *Notifier) deliverDue() {
+	due, err := n.storage.DueNotifications(n.ctx, time.Now().UTC(), 100)
+	if err != nil {
+		slog.Error("loading notifications", "err", err)
+		return
+	}
+	for _, ntf := range due {
+		if n.ctx.Err() != nil {
+			return
+		}
+		n.deliver(ntf)
+	}
+}
+
+func (n *Notifier) deliver(ntf *storage.Notification) {
+	now := time.Now().UTC()
+	ntf.Attempts++
+	code, err := n.send(n.ctx, ntf)
+	ntf.LastStatusCode = code
+	if err == nil {
+		ntf.Status = storage.NotificationDelivered
+		ntf.LastError = ""
+		ntf.DeliveredAt = now
+	} else {
+		ntf.LastError = err.Error()
+		if ntf.Attempts >= n.maxAttempts {
+			ntf.Status = storage.NotificationFailed
+		} else {
+			ntf.NextAttemptAt = now.Add(n.retryBackoff << (ntf.Attempts - 1))
+		}
+	}
+	if err := n.storage.UpdateNotification(n.ctx, ntf); err != nil {
+		slog.Error("updating notification", "notification_id", ntf.ID, "err", err)
+	}
+}
+
+func (n *Notifier) send(ctx context.Context, ntf *storage.Notification) (int, error) {
+	if strings.HasPrefix(ntf.URL, "mailto:") {
+		return 0, n.sendEmail(ctx, ntf)
+	}
+	return n.sendHTTP(ctx, ntf)
+}
+
+func (n *Notifier) sendHTTP(ctx context.Context, ntf *storage.Notification) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ntf.URL, strings.NewReader(ntf.Payload))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range ntf.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("User-Agent", "linkwatch")
+	req.Header.Set("X-Linkwatch-Delivery", ntf.ID)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
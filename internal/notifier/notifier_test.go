@@ -0,0 +1,420 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/checker"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifier_SignedDeliveryWithRetry(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+
+	var bodies [][]byte
+	var signatures []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, body)
+		signatures = append(signatures, r.Header.Get(SignatureHeader))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := NewNotifier(s, []Webhook{{URL: srv.URL, Secret: "s3cret"}}, time.Second)
+	n.SetRetryPolicy(3, 0)
+
+	target := &storage.Target{ID: "t_1", URL: "https://example.com"}
+	n.StateChanged(checker.Transition{
+		Target:   target,
+		From:     checker.StateUp,
+		To:       checker.StateDown,
+		At:       time.Now().UTC(),
+		Result:   &storage.CheckResult{StatusCode: 503},
+		Incident: &storage.Incident{ID: "inc_1", FirstError: "HTTP 503"},
+	})
+
+	n.deliverDue()
+	pending, err := s.ListNotifications(context.Background(), "t_1", storage.NotificationPending, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, http.StatusBadGateway, pending[0].LastStatusCode)
+
+	n.deliverDue()
+	delivered, err := s.ListNotifications(context.Background(), "t_1", storage.NotificationDelivered, 10)
+	require.NoError(t, err)
+	require.Len(t, delivered, 1)
+	assert.Equal(t, 2, delivered[0].Attempts)
+
+	require.Len(t, bodies, 2)
+	assert.Equal(t, Sign("s3cret", bodies[1]), signatures[1])
+
+	var ev Event
+	require.NoError(t, json.Unmarshal(bodies[1], &ev))
+	assert.Equal(t, "target.down", ev.Type)
+	assert.Equal(t, "up", ev.PreviousState)
+	assert.Equal(t, "inc_1", ev.IncidentID)
+	assert.Equal(t, 503, ev.StatusCode)
+}
+
+func TestNotifier_GivesUpAfterMaxAttempts(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	n := NewNotifier(s, []Webhook{{URL: srv.URL}}, time.Second)
+	n.SetRetryPolicy(2, 0)
+
+	n.StateChanged(checker.Transition{
+		Target: &storage.Target{ID: "t_1", URL: "https://example.com"},
+		From:   checker.StateDown,
+		To:     checker.StateUp,
+		At:     time.Now().UTC(),
+	})
+	n.deliverDue()
+	n.deliverDue()
+	n.deliverDue()
+
+	failed, err := s.ListNotifications(context.Background(), "", storage.NotificationFailed, 10)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, 2, failed[0].Attempts)
+	assert.Equal(t, "unexpected status 500", failed[0].LastError)
+}
+
+func TestNotifier_RoutesChannelsByTargetAndSelector(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+
+	target, _, err := s.CreateTarget(ctx, "https://example.com", "")
+	require.NoError(t, err)
+	target, err = s.UpdateTargetLabels(ctx, target.ID, map[string]string{"env": "prod"})
+	require.NoError(t, err)
+
+	channels := []*storage.Channel{
+		{Name: "prod", Type: storage.ChannelSlack, URL: "http://slack.invalid", Selector: "env=prod"},
+		{Name: "staging", Type: storage.ChannelTeams, URL: "http://teams.invalid", Selector: "env=staging"},
+		{Name: "direct", Type: storage.ChannelDiscord, URL: "http://discord.invalid", TargetID: target.ID},
+		{Name: "other", Type: storage.ChannelDiscord, URL: "http://discord.invalid", TargetID: "t_other"},
+	}
+	for _, ch := range channels {
+		require.NoError(t, s.CreateChannel(ctx, ch))
+	}
+	// A catch-all channel of another tenant never hears about this target.
+	require.NoError(t, s.CreateChannel(storage.WithTenant(ctx, "acme"), &storage.Channel{Name: "acme", Type: storage.ChannelSlack, URL: "http://slack.invalid"}))
+
+	n := NewNotifier(s, nil, time.Second)
+	n.StateChanged(checker.Transition{Target: target, From: checker.StateUp, To: checker.StateDown, At: time.Now().UTC()})
+
+	queued, err := s.ListNotifications(ctx, target.ID, "", 10)
+	require.NoError(t, err)
+	require.Len(t, queued, 2)
+
+	byChannel := map[string]*storage.Notification{}
+	for _, ntf := range queued {
+		byChannel[ntf.ChannelID] = ntf
+	}
+
+	var slack map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(byChannel[channels[0].ID].Payload), &slack))
+	assert.Equal(t, "https://example.com is DOWN", slack["text"])
+	assert.NotEmpty(t, slack["blocks"])
+
+	var discord struct {
+		Embeds []struct {
+			Title string
+			Color int
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(byChannel[channels[2].ID].Payload), &discord))
+	require.Len(t, discord.Embeds, 1)
+	assert.Equal(t, 0xD32F2F, discord.Embeds[0].Color)
+}
+
+func TestNotifier_TestChannelTeams(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+
+	var card map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&card)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	n := NewNotifier(s, nil, time.Second)
+	code, err := n.TestChannel(context.Background(), &storage.Channel{Type: storage.ChannelTeams, URL: srv.URL})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "MessageCard", card["@type"])
+	assert.Equal(t, "D32F2F", card["themeColor"])
+	assert.Contains(t, card["title"], "[TEST]")
+}
+
+type fakeSMTP struct {
+	addr  string
+	mu    sync.Mutex
+	auth  string
+	from  string
+	rcpts []string
+	data  string
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeSMTP{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { fmt.Fprintf(conn, "%s\r\n", s) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		f.mu.Lock()
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH PLAIN "):
+			decoded, _ := base64.StdEncoding.DecodeString(line[len("AUTH PLAIN "):])
+			f.auth = string(decoded)
+			reply("235 authenticated")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			f.from = line[len("MAIL FROM:"):]
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			f.rcpts = append(f.rcpts, line[len("RCPT TO:"):])
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			f.data = data.String()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			f.mu.Unlock()
+			return
+		default:
+			reply("250 ok")
+		}
+		f.mu.Unlock()
+	}
+}
+
+func TestNotifier_EmailRecovery(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	smtpSrv := startFakeSMTP(t)
+
+	ch := &storage.Channel{Type: storage.ChannelEmail, URL: "mailto:ops@example.com,oncall@example.com"}
+	require.NoError(t, s.CreateChannel(ctx, ch))
+
+	n := NewNotifier(s, nil, 2*time.Second)
+	n.SetSMTP(SMTPConfig{Addr: smtpSrv.addr, Username: "user", Password: "pass", From: "Linkwatch <alerts@example.com>"})
+
+	n.StateChanged(checker.Transition{
+		Target:   &storage.Target{ID: "t_1", URL: "https://example.com"},
+		From:     checker.StateDown,
+		To:       checker.StateUp,
+		At:       time.Now().UTC(),
+		Result:   &storage.CheckResult{StatusCode: 200, LatencyMs: 42},
+		Incident: &storage.Incident{ID: "inc_1", EndedAt: time.Now(), Duration: 5 * time.Minute},
+	})
+	n.deliverDue()
+
+	delivered, err := s.ListNotifications(ctx, "t_1", storage.NotificationDelivered, 10)
+	require.NoError(t, err)
+	require.Len(t, delivered, 1)
+
+	smtpSrv.mu.Lock()
+	defer smtpSrv.mu.Unlock()
+	assert.Equal(t, "\x00user\x00pass", smtpSrv.auth)
+	assert.Equal(t, "<alerts@example.com>", smtpSrv.from)
+	assert.Equal(t, []string{"<ops@example.com>", "<oncall@example.com>"}, smtpSrv.rcpts)
+	assert.Contains(t, smtpSrv.data, "Subject: [linkwatch] https://example.com is UP\r\n")
+	assert.Contains(t, smtpSrv.data, "https://example.com has recovered.")
+	assert.Contains(t, smtpSrv.data, "Status code: 200")
+	assert.Contains(t, smtpSrv.data, "Incident duration: 5m0s")
+}
+
+func TestNotifier_EmailRequiresStartTLS(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	smtpSrv := startFakeSMTP(t)
+
+	n := NewNotifier(s, nil, 2*time.Second)
+	n.SetSMTP(SMTPConfig{Addr: smtpSrv.addr, StartTLS: true})
+
+	_, err := n.TestChannel(context.Background(), &storage.Channel{Type: storage.ChannelEmail, URL: "mailto:ops@example.com"})
+	assert.EqualError(t, err, "smtp server does not support STARTTLS")
+}
+
+func TestNotifier_PagerDutyAndOpsgenieLifecycle(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+
+	type request struct {
+		path string
+		auth string
+		body map[string]interface{}
+	}
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{path: r.URL.RequestURI(), auth: r.Header.Get("Authorization")}
+		json.NewDecoder(r.Body).Decode(&req.body)
+		requests = append(requests, req)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	require.NoError(t, s.CreateChannel(ctx, &storage.Channel{Type: storage.ChannelPagerDuty, URL: srv.URL + "/v2/enqueue", Secret: "routing-key"}))
+	n := NewNotifier(s, nil, time.Second)
+
+	target := &storage.Target{ID: "t_1", URL: "https://example.com"}
+	incident := &storage.Incident{ID: "inc_1", TargetID: "t_1", FirstError: "HTTP 503"}
+	n.StateChanged(checker.Transition{Target: target, From: checker.StateUp, To: checker.StateDegraded, At: time.Now().UTC()})
+	n.StateChanged(checker.Transition{Target: target, From: checker.StateUp, To: checker.StateDown, At: time.Now().UTC(), Incident: incident})
+	n.deliverDue()
+
+	resolved := *incident
+	resolved.EndedAt = time.Now()
+	resolved.Duration = 2 * time.Minute
+	n.StateChanged(checker.Transition{Target: target, From: checker.StateDown, To: checker.StateUp, At: time.Now().UTC(), Incident: &resolved})
+	n.deliverDue()
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/v2/enqueue", requests[0].path)
+	assert.Equal(t, "trigger", requests[0].body["event_action"])
+	assert.Equal(t, "routing-key", requests[0].body["routing_key"])
+	assert.Equal(t, "linkwatch-t_1-inc_1", requests[0].body["dedup_key"])
+	assert.Equal(t, "critical", requests[0].body["payload"].(map[string]interface{})["severity"])
+	assert.Equal(t, "resolve", requests[1].body["event_action"])
+	assert.Equal(t, "linkwatch-t_1-inc_1", requests[1].body["dedup_key"])
+
+	requests = nil
+	ops := &storage.Channel{Type: storage.ChannelOpsgenie, URL: srv.URL, Secret: "genie"}
+	ntf, err := n.render(ops, eventFromTransition(checker.Transition{Target: target, From: checker.StateUp, To: checker.StateDown, Incident: incident}))
+	require.NoError(t, err)
+	_, err = n.send(ctx, ntf)
+	require.NoError(t, err)
+	ntf, err = n.render(ops, eventFromTransition(checker.Transition{Target: target, From: checker.StateDown, To: checker.StateUp, Incident: &resolved}))
+	require.NoError(t, err)
+	_, err = n.send(ctx, ntf)
+	require.NoError(t, err)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/v2/alerts", requests[0].path)
+	assert.Equal(t, "GenieKey genie", requests[0].auth)
+	assert.Equal(t, "linkwatch-t_1-inc_1", requests[0].body["alias"])
+	assert.Equal(t, "/v2/alerts/linkwatch-t_1-inc_1/close?identifierType=alias", requests[1].path)
+	assert.Equal(t, "Target recovered after 2m0s", requests[1].body["note"])
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 130))
+	long := strings.Repeat("ü", 200)
+	assert.Equal(t, strings.Repeat("ü", 130), truncate(long, 130))
+	assert.True(t, utf8.ValidString(truncate("a"+long, 130)))
+}
+
+func TestChatPayloads_LongURLAndError(t *testing.T) {
+	ev := &Event{
+		Type:     "target.down",
+		TargetID: "t_1",
+		URL:      "https://example.com/" + strings.Repeat("ü", 4000),
+		State:    "down",
+		Error:    strings.Repeat("connection reset ", 200),
+	}
+
+	slack := slackPayload(ev)["blocks"].([]interface{})
+	header := slack[0].(map[string]interface{})["text"].(map[string]string)["text"]
+	assert.Equal(t, slackHeaderLen, utf8.RuneCountInString(header))
+	assert.True(t, utf8.ValidString(header))
+	section := slack[1].(map[string]interface{})
+	assert.LessOrEqual(t, utf8.RuneCountInString(section["text"].(map[string]string)["text"]), slackTextLen)
+	for _, f := range section["fields"].([]map[string]string) {
+		assert.LessOrEqual(t, utf8.RuneCountInString(f["text"]), slackFieldLen)
+	}
+
+	embed := discordPayload(ev)["embeds"].([]map[string]interface{})[0]
+	assert.Equal(t, discordTitleLen, utf8.RuneCountInString(embed["title"].(string)))
+	for _, f := range embed["fields"].([]map[string]interface{}) {
+		assert.LessOrEqual(t, utf8.RuneCountInString(f["value"].(string)), discordFieldLen)
+	}
+}
+
+func TestNotifier_FlappingSuppressesTransitions(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	n := NewNotifier(s, []Webhook{{URL: "http://127.0.0.1:1/hook"}}, time.Second)
+
+	target := &storage.Target{ID: "t_1", URL: "https://example.com"}
+	n.FlappingChanged(checker.FlapChange{Target: target, Flapping: true, State: checker.StateUp, PercentChange: 42.5, At: time.Now().UTC()})
+	n.StateChanged(checker.Transition{Target: target, From: checker.StateUp, To: checker.StateDown, At: time.Now().UTC(), Flapping: true})
+	n.StateChanged(checker.Transition{Target: target, From: checker.StateDown, To: checker.StateUp, At: time.Now().UTC(), Flapping: true})
+	n.FlappingChanged(checker.FlapChange{Target: target, Flapping: false, State: checker.StateUp, PercentChange: 3, At: time.Now().UTC()})
+
+	queued, err := s.ListNotifications(ctx, "t_1", "", 10)
+	require.NoError(t, err)
+	require.Len(t, queued, 2)
+	events := []string{queued[0].Event, queued[1].Event}
+	assert.ElementsMatch(t, []string{"target.flapping", "target.flapping_stopped"}, events)
+
+	var ev Event
+	for _, q := range queued {
+		if q.Event == "target.flapping" {
+			require.NoError(t, json.Unmarshal([]byte(q.Payload), &ev))
+		}
+	}
+	assert.Equal(t, 42.5, ev.PercentStateChange)
+}
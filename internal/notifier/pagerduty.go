@@ -0,0 +1,177 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+)
+
+const (
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	DefaultOpsgenieURL  = "https://api.opsgenie.com"
+)
+
+// dedupKey identifies one incident (or rule alert) on one target, so that
+// the resolve sent on recovery closes exactly the alert opened earlier.
+func dedupKey(ev *Event) string {
+	if ev.AlertID != "" {
+		return "linkwatch-alert-" + ev.AlertID
+	}
+	return "linkwatch-" + ev.TargetID + "-" + ev.IncidentID
+}
+
+// incidentAction maps an event onto the trigger/resolve lifecycle of an
+// on-call tool. Only incidents and rule alerts page; degraded transitions
+// are ignored.
+func incidentAction(ev *Event) string {
+	if ev.AlertID != "" {
+		if ev.State == storage.AlertResolved {
+			return "resolve"
+		}
+		return "trigger"
+	}
+	if ev.IncidentID == "" {
+		return ""
+	}
+	switch ev.State {
+	case "down":
+		return "trigger"
+	case "up", "degraded":
+		return "resolve"
+	}
+	return ""
+}
+
+func details(ev *Event) map[string]string {
+	d := map[string]string{"url": ev.URL}
+	for _, f := range facts(ev) {
+		d[f.name] = f.value
+	}
+	return d
+}
+
+func description(ev *Event) string {
+	if ev.Summary != "" {
+		return ev.Summary
+	}
+	return fmt.Sprintf("%s is down: %s", ev.URL, ev.Error)
+}
+
+func pagerDutySeverity(severity string) string {
+	switch severity {
+	case "critical", "error", "warning", "info":
+		return severity
+	}
+	return "critical"
+}
+
+func opsgeniePriority(severity string) string {
+	switch severity {
+	case "error":
+		return "P2"
+	case "warning":
+		return "P3"
+	case "info":
+		return "P5"
+	}
+	return "P1"
+}
+
+func pagerDutyNotification(ch *storage.Channel, ev *Event) (*storage.Notification, error) {
+	action := incidentAction(ev)
+	if action == "" {
+		return nil, nil
+	}
+
+	body := map[string]interface{}{
+		"routing_key":  ch.Secret,
+		"event_action": action,
+		"dedup_key":    dedupKey(ev),
+	}
+	if action == "trigger" {
+		body["client"] = "linkwatch"
+		body["links"] = []map[string]string{{"href": ev.URL, "text": "Target"}}
+		body["payload"] = map[string]interface{}{
+			"summary":        title(ev),
+			"source":         ev.URL,
+			"severity":       pagerDutySeverity(ev.Severity),
+			"timestamp":      ev.Timestamp.UTC().Format(time.RFC3339),
+			"component":      ev.TargetID,
+			"custom_details": details(ev),
+		}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := ch.URL
+	if endpoint == "" {
+		endpoint = DefaultPagerDutyURL
+	}
+	return &storage.Notification{
+		TargetID:  ev.TargetID,
+		ChannelID: ch.ID,
+		Event:     ev.Type,
+		URL:       endpoint,
+		Headers:   map[string]string{"Content-Type": "application/json"},
+		Payload:   string(payload),
+	}, nil
+}
+
+func opsgenieNotification(ch *storage.Channel, ev *Event) (*storage.Notification, error) {
+	action := incidentAction(ev)
+	if action == "" {
+		return nil, nil
+	}
+
+	base := strings.TrimRight(ch.URL, "/")
+	if base == "" {
+		base = DefaultOpsgenieURL
+	}
+
+	var endpoint string
+	var body map[string]interface{}
+	if action == "trigger" {
+		endpoint = base + "/v2/alerts"
+		body = map[string]interface{}{
+			"message":     truncate(title(ev), 130),
+			"alias":       dedupKey(ev),
+			"description": description(ev),
+			"details":     details(ev),
+			"entity":      ev.URL,
+			"source":      "linkwatch",
+			"priority":    opsgeniePriority(ev.Severity),
+		}
+	} else {
+		endpoint = base + "/v2/alerts/" + url.PathEscape(dedupKey(ev)) + "/close?identifierType=alias"
+		note := "Target recovered"
+		if ev.AlertID != "" {
+			note = "Alert resolved"
+		}
+		if ev.IncidentDurationMs != 0 {
+			note += " after " + (time.Duration(ev.IncidentDurationMs) * time.Millisecond).Round(time.Second).String()
+		}
+		body = map[string]interface{}{"source": "linkwatch", "note": note}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.Notification{
+		TargetID:  ev.TargetID,
+		ChannelID: ch.ID,
+		Event:     ev.Type,
+		URL:       endpoint,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "GenieKey " + ch.Secret,
+		},
+		Payload: string(payload),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package problem
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/logging"
+)
+
+// ContentType is the media type of RFC 7807 problem details.
+const ContentType = "application/problem+json"
+
+// Machine-readable error codes, one per kind of failure.
+const (
+	CodeInvalidArgument  = "invalid_argument"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "request_too_large"
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal"
+	CodeUnavailable      = "unavailable"
+)
+
+// FieldError points at one invalid request field: a JSON body field or a
+// query parameter.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem detail. Type is always about:blank, so
+// Title is the HTTP status text; Code tells errors with the same status
+// apart.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+func New(status int, code, detail string) *Problem {
+	return &Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Code: code, Detail: detail}
+}
+
+// Invalid is a 400 for one bad field.
+func Invalid(field, message string) *Problem {
+	p := New(http.StatusBadRequest, CodeInvalidArgument, field+": "+message)
+	p.Errors = []FieldError{{Field: field, Message: message}}
+	return p
+}
+
+func (p *Problem) Error() string {
+	return p.Detail
+}
+
+// Write sends p as the response, filling in the request path and ID.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = logging.RequestID(r.Context())
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+func Error(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Write(w, r, New(status, code, detail))
+}
+
+func BadRequest(w http.ResponseWriter, r *http.Request, detail string) {
+	Error(w, r, http.StatusBadRequest, CodeInvalidArgument, detail)
+}
+
+func InvalidField(w http.ResponseWriter, r *http.Request, field, message string) {
+	Write(w, r, Invalid(field, message))
+}
+
+func NotFound(w http.ResponseWriter, r *http.Request, detail string) {
+	Error(w, r, http.StatusNotFound, CodeNotFound, detail)
+}
+
+func Conflict(w http.ResponseWriter, r *http.Request, detail string) {
+	Error(w, r, http.StatusConflict, CodeConflict, detail)
+}
+
+func Forbidden(w http.ResponseWriter, r *http.Request, detail string) {
+	Error(w, r, http.StatusForbidden, CodeForbidden, detail)
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not supported here")
+}
+
+// Internal logs err and answers with a generic 500; internal error messages
+// are never sent to clients.
+func Internal(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path, "err", err)
+	Error(w, r, http.StatusInternalServerError, CodeInternal, "internal error")
+}
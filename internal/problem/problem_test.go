@@ -0,0 +1,60 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v1/targets", nil)
+	r = r.WithContext(logging.WithRequestID(r.Context(), "req-1"))
+	w := httptest.NewRecorder()
+
+	InvalidField(w, r, "url", "must be an http or https URL")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, Problem{
+		Type:      "about:blank",
+		Title:     "Bad Request",
+		Status:    http.StatusBadRequest,
+		Code:      CodeInvalidArgument,
+		Detail:    "url: must be an http or https URL",
+		Instance:  "/v1/targets",
+		RequestID: "req-1",
+		Errors:    []FieldError{{Field: "url", Message: "must be an http or https URL"}},
+	}, p)
+}
+
+func TestInternal_HidesError(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(prev)
+
+	w := httptest.NewRecorder()
+	Internal(w, httptest.NewRequest("GET", "/v1/targets", nil), errors.New("database is locked"))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "database is locked")
+	assert.Contains(t, w.Body.String(), `"code":"internal"`)
+	assert.Contains(t, logs.String(), "database is locked")
+}
+
+func TestProblemIsError(t *testing.T) {
+	var err error = Invalid("limit", "must be positive")
+	var p *Problem
+	require.True(t, errors.As(err, &p))
+	assert.Equal(t, "limit: must be positive", err.Error())
+}
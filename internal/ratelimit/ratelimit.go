@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/problem"
+)
+
+// Limit allows Requests per Per, in bursts of up to Requests. The zero Limit
+// allows everything.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit reads limits written as "100/1m". "" and "0" disable limiting.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+	n, per, ok := strings.Cut(s, "/")
+	requests, err := strconv.Atoi(n)
+	if !ok || err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, want e.g. 100/1m", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, want e.g. 100/1m", s)
+	}
+	return Limit{Requests: requests, Per: d}, nil
+}
+
+func (l Limit) String() string {
+	if l.Requests == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// rate is the refill rate in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Decision is the outcome of Take.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed; zero
+	// when this one was.
+	RetryAfter time.Duration
+}
+
+// Limiter keeps one token bucket per client.
+type Limiter struct {
+	limit     Limit
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+	// proxies is the number of trusted proxies that append to
+	// X-Forwarded-For.
+	proxies int
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// SetTrustedProxies makes anonymous clients be identified by X-Forwarded-For
+// when n proxies in front of the server each append to it: the client is the
+// nth address from the right, as the addresses left of it are sent by the
+// client itself. Zero ignores the header.
+func (l *Limiter) SetTrustedProxies(n int) {
+	l.proxies = n
+}
+
+// Take spends a token from key's bucket if it has one.
+func (l *Limiter) Take(key string) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	capacity, rate := float64(l.limit.Requests), l.limit.rate()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	l.sweep(now)
+
+	d := Decision{Limit: l.limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((capacity - b.tokens) / rate)
+	return d
+}
+
+// sweep drops buckets that have refilled completely, which are
+// indistinguishable from new ones, at most once per period.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.limit.Per {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.limit.Per {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Handler limits next per API key, or per client IP for anonymous requests,
+// answering 429 with Retry-After once the bucket is empty. Every response
+// carries RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset.
+func (l *Limiter) Handler(next http.HandlerFunc) http.HandlerFunc {
+	if l.limit.Requests == 0 {
+		return next
+	}
+	policy := fmt.Sprintf("%d;w=%d", l.limit.Requests, int(math.Ceil(l.limit.Per.Seconds())))
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := l.Take(l.clientKey(r))
+		h := w.Header()
+		h.Set("RateLimit-Policy", policy)
+		h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+		if !d.Allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+			problem.Error(w, r, http.StatusTooManyRequests, problem.CodeRateLimited, "rate limit exceeded")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func (l *Limiter) clientKey(r *http.Request) string {
+	if key := auth.FromContext(r.Context()); key != nil {
+		return "key:" + key.ID
+	}
+	if l.proxies > 0 {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			addrs := strings.Split(strings.Join(xff, ","), ",")
+			return "ip:" + strings.TrimSpace(addrs[max(0, len(addrs)-l.proxies)])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("100/1m")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 100, Per: time.Minute}, l)
+	l, err = ParseLimit("0")
+	require.NoError(t, err)
+	assert.Equal(t, "unlimited", l.String())
+	for _, bad := range []string{"100", "x/1m", "-1/1m", "10/0s", "10/soon"} {
+		_, err := ParseLimit(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTake_RefillsOverTime(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(Limit{Requests: 2, Per: 10 * time.Second})
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.Take("a").Allowed)
+	d := l.Take("a")
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	assert.Equal(t, 10*time.Second, d.Reset)
+
+	d = l.Take("a")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 5*time.Second, d.RetryAfter)
+	assert.True(t, l.Take("b").Allowed, "buckets are per key")
+
+	now = now.Add(5 * time.Second)
+	assert.True(t, l.Take("a").Allowed)
+	assert.False(t, l.Take("a").Allowed)
+
+	now = now.Add(time.Minute)
+	l.Take("b")
+	assert.Len(t, l.buckets, 1, "idle buckets are swept")
+}
+
+func TestHandler(t *testing.T) {
+	l := New(Limit{Requests: 1, Per: time.Minute})
+	h := l.Handler(func(w http.ResponseWriter, r *http.Request) {})
+	do := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	anon := httptest.NewRequest("GET", "/v1/targets", nil)
+	w := do(anon)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1;w=60", w.Header().Get("RateLimit-Policy"))
+
+	w = do(anon)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"rate_limited"`)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	keyed := anon.WithContext(auth.WithKey(anon.Context(), &storage.APIKey{ID: "key_1"}))
+	assert.Equal(t, http.StatusOK, do(keyed).Code, "API keys have their own bucket")
+
+	forwarded := httptest.NewRequest("GET", "/v1/targets", nil)
+	forwarded.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, do(forwarded).Code, "X-Forwarded-For is ignored by default")
+	l.SetTrustedProxies(1)
+	assert.Equal(t, http.StatusOK, do(forwarded).Code)
+	spoofed := httptest.NewRequest("GET", "/v1/targets", nil)
+	spoofed.Header.Set("X-Forwarded-For", "198.51.100.1, 10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, do(spoofed).Code, "addresses sent by the client are not trusted")
+	l.SetTrustedProxies(2)
+	assert.Equal(t, http.StatusOK, do(spoofed).Code, "the second proxy saw 198.51.100.1")
+
+	unlimited := New(Limit{})
+	w = httptest.NewRecorder()
+	unlimited.Handler(func(w http.ResponseWriter, r *http.Request) {})(w, anon)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
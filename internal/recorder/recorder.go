@@ -0,0 +1,38 @@
+// Package recorder wraps an http.ResponseWriter to remember the status code
+// of the response, for logging and metrics.
+package recorder
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+type Recorder struct {
+	http.ResponseWriter
+	// Status is the code written, 200 if the handler never called
+	// WriteHeader.
+	Status int
+}
+
+func New(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(code int) {
+	r.Status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades through; the connection is recorded as 101.
+func (r *Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.Status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
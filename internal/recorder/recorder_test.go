@@ -0,0 +1,27 @@
+package recorder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := New(httptest.NewRecorder())
+	assert.Equal(t, http.StatusOK, rec.Status)
+	rec.WriteHeader(http.StatusTeapot)
+	assert.Equal(t, http.StatusTeapot, rec.Status)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := New(w)
+		conn, _, err := rec.Hijack()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusSwitchingProtocols, rec.Status)
+		conn.Close()
+	}))
+	defer srv.Close()
+	http.Get(srv.URL)
+}
@@ -0,0 +1,349 @@
+package statuspage
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+)
+
+const (
+	// Days is the number of daily uptime bars shown per target.
+	Days = 90
+	// RecentDays is how far back resolved incidents are listed.
+	RecentDays  = 14
+	recentLimit = 10
+	// Rendered pages are kept this long, so anonymous traffic does not
+	// query storage on every request.
+	cacheTTL     = 30 * time.Second
+	cacheControl = "public, max-age=30"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+//go:embed assets
+var assetFS embed.FS
+
+var funcs = template.FuncMap{
+	"iso":  func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"when": func(t time.Time) string { return t.UTC().Format("Jan 2, 15:04 UTC") },
+	"statusText": func(status string) string {
+		switch status {
+		case "outage":
+			return "Major outage"
+		case "degraded":
+			return "Some systems are down"
+		}
+		return "All systems operational"
+	},
+	"stateText": func(state string) string {
+		switch state {
+		case "up", "operational":
+			return "Operational"
+		case "degraded":
+			return "Partial outage"
+		case "down", "outage":
+			return "Down"
+		case "maintenance":
+			return "Maintenance"
+		}
+		return "No data"
+	},
+}
+
+var pageTemplate = template.Must(template.New("page.html").Funcs(funcs).ParseFS(templateFS, "templates/page.html"))
+
+// Server renders public status pages. Rendered pages are cached for
+// cacheTTL, so changes made through the API show up within that time.
+type Server struct {
+	storage storage.Storage
+	now     func() time.Time
+	mu      sync.Mutex
+	cache   map[string]*cachedPage
+}
+
+type cachedPage struct {
+	body    []byte
+	expires time.Time
+}
+
+func New(s storage.Storage) *Server {
+	return &Server{storage: s, now: time.Now, cache: map[string]*cachedPage{}}
+}
+
+// Assets serves the embedded stylesheet; mount it at /status/assets/.
+func (s *Server) Assets() http.Handler {
+	sub, _ := fs.Sub(assetFS, "assets")
+	return http.StripPrefix("/status/assets/", http.FileServerFS(sub))
+}
+
+type view struct {
+	Page        *storage.StatusPage
+	Status      string
+	Groups      []*groupView
+	Active      []*incidentView
+	Recent      []*incidentView
+	Days        int
+	RecentDays  int
+	GeneratedAt time.Time
+}
+
+type groupView struct {
+	Name    string
+	Status  string
+	Targets []*targetView
+}
+
+type targetView struct {
+	Name   string
+	State  string
+	Uptime string
+	Bars   []bar
+}
+
+type bar struct {
+	Class string
+	Title string
+}
+
+type incidentView struct {
+	Target    string
+	StartedAt time.Time
+	Duration  string
+}
+
+// Page renders the status page with the given slug, or serves it from the
+// cache.
+func (s *Server) Page(w http.ResponseWriter, r *http.Request, slug string) {
+	s.mu.Lock()
+	cached := s.cache[slug]
+	s.mu.Unlock()
+	if cached == nil || !s.now().Before(cached.expires) {
+		body, ok := s.render(w, r, slug)
+		if !ok {
+			return
+		}
+		cached = &cachedPage{body: body, expires: s.now().Add(cacheTTL)}
+		s.mu.Lock()
+		for k, p := range s.cache {
+			if !s.now().Before(p.expires) {
+				delete(s.cache, k)
+			}
+		}
+		s.cache[slug] = cached
+		s.mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Write(cached.body)
+}
+
+// render builds the page from storage. On errors it writes the response and
+// returns false.
+func (s *Server) render(w http.ResponseWriter, r *http.Request, slug string) ([]byte, bool) {
+	page, err := s.storage.GetStatusPage(r.Context(), slug)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "loading status page", "slug", slug, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	v, err := s.build(storage.WithTenant(r.Context(), page.TenantID), page)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "building status page", "slug", slug, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, v); err != nil {
+		slog.ErrorContext(r.Context(), "rendering status page", "slug", slug, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func (s *Server) build(ctx context.Context, page *storage.StatusPage) (*view, error) {
+	now := s.now().UTC()
+	v := &view{Page: page, Days: Days, RecentDays: RecentDays, GeneratedAt: now}
+
+	targets, _, err := s.storage.ListTargets(ctx, "", 10000, "")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]*targetView{}
+	var up, down int
+	for _, g := range page.Groups {
+		sel, err := labels.Parse(g.Selector)
+		if err != nil {
+			return nil, err
+		}
+		gv := &groupView{Name: g.Name}
+		var groupDown int
+		for _, t := range targets {
+			if !sel.Matches(t.Labels) {
+				continue
+			}
+			tv, ok := seen[t.ID]
+			if !ok {
+				if tv, err = s.target(ctx, t, now, v); err != nil {
+					return nil, err
+				}
+				seen[t.ID] = tv
+				if tv.State == "down" {
+					down++
+				} else {
+					up++
+				}
+			}
+			if tv.State == "down" {
+				groupDown++
+			}
+			gv.Targets = append(gv.Targets, tv)
+		}
+		gv.Status = rollupStatus(len(gv.Targets)-groupDown, groupDown)
+		v.Groups = append(v.Groups, gv)
+	}
+	v.Status = rollupStatus(up, down)
+
+	sort.Slice(v.Active, func(i, j int) bool { return v.Active[i].StartedAt.After(v.Active[j].StartedAt) })
+	sort.Slice(v.Recent, func(i, j int) bool { return v.Recent[i].StartedAt.After(v.Recent[j].StartedAt) })
+	if len(v.Recent) > recentLimit {
+		v.Recent = v.Recent[:recentLimit]
+	}
+	return v, nil
+}
+
+// target gathers one target's state, uptime bars and incidents, adding the
+// incidents to v.
+func (s *Server) target(ctx context.Context, t *storage.Target, now time.Time, v *view) (*targetView, error) {
+	tv := &targetView{Name: DisplayName(t), State: "unknown"}
+
+	latest, err := s.storage.GetCheckResults(ctx, t.ID, time.Time{}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(latest) > 0 {
+		tv.State = "up"
+		if latest[0].Maintenance {
+			tv.State = "maintenance"
+		}
+	}
+
+	incidents, err := s.storage.ListIncidents(ctx, t.ID, false, recentLimit)
+	if err != nil {
+		return nil, err
+	}
+	for _, inc := range incidents {
+		iv := &incidentView{Target: tv.Name, StartedAt: inc.StartedAt}
+		if inc.EndedAt.IsZero() {
+			tv.State = "down"
+			iv.Duration = formatDuration(now.Sub(inc.StartedAt))
+			v.Active = append(v.Active, iv)
+		} else if now.Sub(inc.EndedAt) < RecentDays*24*time.Hour {
+			iv.Duration = formatDuration(inc.EndedAt.Sub(inc.StartedAt))
+			v.Recent = append(v.Recent, iv)
+		}
+	}
+
+	first := now.Truncate(24*time.Hour).AddDate(0, 0, -(Days - 1))
+	days, err := s.storage.GetDailyUptime(ctx, t.ID, first)
+	if err != nil {
+		return nil, err
+	}
+	tv.Bars, tv.Uptime = bars(days, first)
+	return tv, nil
+}
+
+// bars returns one bar per day starting at first and the overall uptime.
+func bars(days []*storage.DailyUptime, first time.Time) ([]bar, string) {
+	byDay := map[string]*storage.DailyUptime{}
+	var checks, up int
+	for _, d := range days {
+		byDay[d.Day.Format("2006-01-02")] = d
+		checks += d.Checks
+		up += d.Up
+	}
+
+	out := make([]bar, Days)
+	for i := range out {
+		day := first.AddDate(0, 0, i).Format("2006-01-02")
+		d, ok := byDay[day]
+		if !ok || d.Checks == 0 {
+			out[i] = bar{Class: "none", Title: day + ": no data"}
+			continue
+		}
+		pct := 100 * float64(d.Up) / float64(d.Checks)
+		class := "major"
+		switch {
+		case pct >= 99.9:
+			class = "ok"
+		case pct >= 99:
+			class = "minor"
+		}
+		out[i] = bar{Class: class, Title: fmt.Sprintf("%s: %s (%d checks)", day, formatPercent(pct), d.Checks)}
+	}
+
+	if checks == 0 {
+		return out, "n/a"
+	}
+	return out, formatPercent(100 * float64(up) / float64(checks))
+}
+
+func rollupStatus(up, down int) string {
+	switch {
+	case down == 0:
+		return "operational"
+	case up == 0:
+		return "outage"
+	}
+	return "degraded"
+}
+
+// DisplayName is the target's "name" label, falling back to its URL.
+func DisplayName(t *storage.Target) string {
+	if name := t.Labels["name"]; name != "" {
+		return name
+	}
+	return t.URL
+}
+
+func formatPercent(pct float64) string {
+	s := fmt.Sprintf("%.2f", pct)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return s + "%"
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h >= 24:
+		return fmt.Sprintf("%dd %dh", h/24, h%24)
+	case h > 0:
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+	return fmt.Sprintf("%dm", m)
+}
@@ -0,0 +1,92 @@
+package statuspage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPage(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	api, _, err := s.CreateTarget(ctx, "https://api.example.com", "")
+	require.NoError(t, err)
+	_, err = s.UpdateTargetLabels(ctx, api.ID, map[string]string{"tier": "api", "name": "Public API"})
+	require.NoError(t, err)
+	web, _, err := s.CreateTarget(ctx, "https://www.example.com", "")
+	require.NoError(t, err)
+	_, err = s.UpdateTargetLabels(ctx, web.ID, map[string]string{"tier": "web"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveCheckResult(ctx, api.ID, &storage.CheckResult{CheckedAt: now.Add(-48 * time.Hour), StatusCode: 200}))
+	require.NoError(t, s.SaveCheckResult(ctx, api.ID, &storage.CheckResult{CheckedAt: now.Add(-time.Minute), StatusCode: 500}))
+	require.NoError(t, s.SaveCheckResult(ctx, web.ID, &storage.CheckResult{CheckedAt: now.Add(-time.Minute), StatusCode: 200}))
+	require.NoError(t, s.CreateIncident(ctx, &storage.Incident{TargetID: api.ID, StartedAt: now.Add(-90 * time.Minute)}))
+	old := &storage.Incident{TargetID: web.ID, StartedAt: now.Add(-72 * time.Hour)}
+	require.NoError(t, s.CreateIncident(ctx, old))
+	require.NoError(t, s.ResolveIncident(ctx, old.ID, now.Add(-71*time.Hour)))
+
+	require.NoError(t, s.CreateStatusPage(ctx, &storage.StatusPage{
+		Slug:   "acme",
+		Title:  "Acme <Status>",
+		Groups: []storage.StatusGroup{{Name: "API", Selector: "tier=api"}, {Name: "Website", Selector: "tier=web"}},
+	}))
+
+	srv := New(s)
+	srv.now = func() time.Time { return now }
+
+	w := httptest.NewRecorder()
+	srv.Page(w, httptest.NewRequest("GET", "/status/acme", nil), "acme")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, cacheControl, w.Header().Get("Cache-Control"))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "Acme &lt;Status&gt;")
+	assert.Contains(t, body, `<div class="banner degraded">Some systems are down</div>`)
+	assert.Contains(t, body, "<strong>Public API</strong> is down since")
+	assert.Contains(t, body, "(1h 30m)")
+	assert.Contains(t, body, "<strong>https://www.example.com</strong> was down for 1h 0m")
+	assert.Contains(t, body, "50% uptime")
+	assert.Contains(t, body, "2026-03-08: 100% (1 checks)")
+	assert.Contains(t, body, "2026-03-10: 0% (1 checks)")
+	assert.Equal(t, 2*Days, strings.Count(body, `<span class="bar `))
+
+	// Pages are served from the cache until it expires.
+	open, err := s.GetOpenIncident(ctx, api.ID)
+	require.NoError(t, err)
+	require.NoError(t, s.ResolveIncident(ctx, open.ID, now))
+	w = httptest.NewRecorder()
+	srv.Page(w, httptest.NewRequest("GET", "/status/acme", nil), "acme")
+	assert.Equal(t, body, w.Body.String())
+	now = now.Add(cacheTTL)
+	w = httptest.NewRecorder()
+	srv.Page(w, httptest.NewRequest("GET", "/status/acme", nil), "acme")
+	assert.Contains(t, w.Body.String(), "All systems operational")
+
+	w = httptest.NewRecorder()
+	srv.Page(w, httptest.NewRequest("GET", "/status/missing", nil), "missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	srv.Assets().ServeHTTP(w, httptest.NewRequest("GET", "/status/assets/style.css", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), ".bars")
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "99.95%", formatPercent(99.951))
+	assert.Equal(t, "100%", formatPercent(100))
+	assert.Equal(t, "less than a minute", formatDuration(20*time.Second))
+	assert.Equal(t, "2d 3h", formatDuration(51*time.Hour))
+}
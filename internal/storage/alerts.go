@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RuleFailures   = "failures"
+	RuleLatency    = "latency"
+	RuleCertExpiry = "cert_expiry"
+
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// RuleCondition holds the parameters of a rule; which fields apply depends
+// on the rule kind:
+//   - failures: at least Failures of the last Of checks failed
+//   - latency: the Percentile latency over Window exceeds LatencyMs
+//   - cert_expiry: the certificate expires within Within
+type RuleCondition struct {
+	Failures   int           `json:"failures,omitempty"`
+	Of         int           `json:"of,omitempty"`
+	Percentile float64       `json:"percentile,omitempty"`
+	LatencyMs  int           `json:"latency_ms,omitempty"`
+	Window     time.Duration `json:"window,omitempty"`
+	Within     time.Duration `json:"within,omitempty"`
+}
+
+type Rule struct {
+	ID                  string
+	TenantID            string
+	Name                string
+	Kind                string
+	Condition           RuleCondition
+	Severity            string
+	TargetID            string
+	Selector            string
+	ChannelIDs          []string
+	RepeatInterval      time.Duration
+	EscalationChannelID string
+	EscalateAfter       time.Duration
+	CreatedAt           time.Time
+}
+
+type Alert struct {
+	ID             string
+	RuleID         string
+	TargetID       string
+	Severity       string
+	Status         string
+	Summary        string
+	StartedAt      time.Time
+	LastNotifiedAt time.Time
+	AcknowledgedAt time.Time
+	AcknowledgedBy string
+	EscalatedAt    time.Time
+	ResolvedAt     time.Time
+}
+
+func (s *SQLiteStorage) CreateRule(ctx context.Context, rule *Rule) error {
+	rule.ID = "rule_" + uuid.NewString()
+	rule.TenantID = tenantFor(ctx)
+	rule.CreatedAt = time.Now().UTC()
+	if rule.ChannelIDs == nil {
+		rule.ChannelIDs = []string{}
+	}
+	condition, err := json.Marshal(rule.Condition)
+	if err != nil {
+		return err
+	}
+	channelIDs, err := json.Marshal(rule.ChannelIDs)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO alert_rules (id, tenant_id, name, kind, condition, severity, target_id, selector, channel_ids, repeat_interval_ms, escalation_channel_id, escalate_after_ms, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.ID, rule.TenantID, rule.Name, rule.Kind, string(condition), rule.Severity, rule.TargetID, rule.Selector, string(channelIDs),
+		rule.RepeatInterval.Milliseconds(), rule.EscalationChannelID, rule.EscalateAfter.Milliseconds(), rule.CreatedAt)
+	return err
+}
+
+const ruleColumns = `id, tenant_id, name, kind, condition, severity, target_id, selector, channel_ids, repeat_interval_ms, escalation_channel_id, escalate_after_ms, created_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRule(row scanner) (*Rule, error) {
+	rule := &Rule{}
+	var condition, channelIDs string
+	var repeatMs, escalateMs int64
+	if err := row.Scan(&rule.ID, &rule.TenantID, &rule.Name, &rule.Kind, &condition, &rule.Severity, &rule.TargetID, &rule.Selector,
+		&channelIDs, &repeatMs, &rule.EscalationChannelID, &escalateMs, &rule.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(condition), &rule.Condition); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(channelIDs), &rule.ChannelIDs); err != nil {
+		return nil, err
+	}
+	rule.RepeatInterval = time.Duration(repeatMs) * time.Millisecond
+	rule.EscalateAfter = time.Duration(escalateMs) * time.Millisecond
+	return rule, nil
+}
+
+func (s *SQLiteStorage) GetRule(ctx context.Context, id string) (*Rule, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	rule, err := scanRule(s.db.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM alert_rules WHERE id = ?`+cond, append([]interface{}{id}, args...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return rule, err
+}
+
+func (s *SQLiteStorage) ListRules(ctx context.Context) ([]*Rule, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	rows, err := s.db.QueryContext(ctx, `SELECT `+ruleColumns+` FROM alert_rules WHERE 1 = 1`+cond+` ORDER BY created_at ASC, id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *SQLiteStorage) DeleteRule(ctx context.Context, id string) error {
+	cond, args := ownedBy(ctx, "tenant_id")
+	res, err := s.db.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = ?`+cond, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) CreateAlert(ctx context.Context, alert *Alert) error {
+	alert.ID = "alert_" + uuid.NewString()
+	if alert.Status == "" {
+		alert.Status = AlertFiring
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO alerts (id, rule_id, target_id, severity, status, summary, started_at, last_notified_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		alert.ID, alert.RuleID, alert.TargetID, alert.Severity, alert.Status, alert.Summary, alert.StartedAt, alert.LastNotifiedAt)
+	return err
+}
+
+const alertColumns = `id, rule_id, target_id, severity, status, summary, started_at, last_notified_at, acknowledged_at, acknowledged_by, escalated_at, resolved_at`
+
+func scanAlert(row scanner) (*Alert, error) {
+	a := &Alert{}
+	var ackAt, escAt, resolvedAt sql.NullTime
+	if err := row.Scan(&a.ID, &a.RuleID, &a.TargetID, &a.Severity, &a.Status, &a.Summary, &a.StartedAt, &a.LastNotifiedAt,
+		&ackAt, &a.AcknowledgedBy, &escAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	a.AcknowledgedAt = ackAt.Time
+	a.EscalatedAt = escAt.Time
+	a.ResolvedAt = resolvedAt.Time
+	return a, nil
+}
+
+func (s *SQLiteStorage) GetAlert(ctx context.Context, id string) (*Alert, error) {
+	cond, args := targetOwnedBy(ctx, "target_id")
+	a, err := scanAlert(s.db.QueryRowContext(ctx, `SELECT `+alertColumns+` FROM alerts WHERE id = ?`+cond, append([]interface{}{id}, args...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+func (s *SQLiteStorage) GetOpenAlert(ctx context.Context, ruleID, targetID string) (*Alert, error) {
+	a, err := scanAlert(s.db.QueryRowContext(ctx, `SELECT `+alertColumns+` FROM alerts WHERE rule_id = ? AND target_id = ? AND status = ?`,
+		ruleID, targetID, AlertFiring))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return a, err
+}
+
+func (s *SQLiteStorage) ListAlerts(ctx context.Context, status string, limit int) ([]*Alert, error) {
+	cond, args := targetOwnedBy(ctx, "target_id")
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE 1 = 1` + cond
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY started_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []*Alert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func (s *SQLiteStorage) UpdateAlert(ctx context.Context, a *Alert) error {
+	cond, args := targetOwnedBy(ctx, "target_id")
+	res, err := s.db.ExecContext(ctx, `UPDATE alerts SET status = ?, summary = ?, last_notified_at = ?, acknowledged_at = ?, acknowledged_by = ?, escalated_at = ?, resolved_at = ? WHERE id = ?`+cond,
+		append([]interface{}{a.Status, a.Summary, a.LastNotifiedAt, nullTime(a.AcknowledgedAt), a.AcknowledgedBy, nullTime(a.EscalatedAt), nullTime(a.ResolvedAt), a.ID}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
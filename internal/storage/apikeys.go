@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey is a credential for the HTTP API. Only the SHA-256 hash of the key
+// is stored; Prefix keeps its first characters so people can tell keys apart.
+type APIKey struct {
+	ID         string
+	TenantID   string
+	Name       string
+	Scope      string
+	Prefix     string
+	Hash       string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+}
+
+func (s *SQLiteStorage) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	key.ID = "key_" + uuid.NewString()
+	if key.TenantID == "" {
+		key.TenantID = tenantFor(ctx)
+	}
+	key.CreatedAt = time.Now().UTC()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO api_keys (id, tenant_id, name, scope, prefix, hash, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, key.TenantID, key.Name, key.Scope, key.Prefix, key.Hash, key.CreatedAt)
+	return err
+}
+
+// GetAPIKeyByHash returns the unrevoked key with the given hash, whichever
+// tenant it belongs to; it is how requests find their tenant.
+func (s *SQLiteStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE hash = ? AND revoked_at IS NULL`, hash)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+func (s *SQLiteStorage) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	rows, err := s.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE 1 = 1`+cond+` ORDER BY created_at ASC, id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey returns ErrNotFound if the key does not exist or is already
+// revoked.
+func (s *SQLiteStorage) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	cond, args := ownedBy(ctx, "tenant_id")
+	res, err := s.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`+cond, append([]interface{}{at, id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+const apiKeyColumns = `id, tenant_id, name, scope, prefix, hash, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	key := &APIKey{}
+	var lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.TenantID, &key.Name, &key.Scope, &key.Prefix, &key.Hash, &key.CreatedAt, &lastUsedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	key.LastUsedAt = lastUsedAt.Time
+	key.RevokedAt = revokedAt.Time
+	return key, nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ChannelWebhook   = "webhook"
+	ChannelSlack     = "slack"
+	ChannelTeams     = "teams"
+	ChannelDiscord   = "discord"
+	ChannelEmail     = "email"
+	ChannelPagerDuty = "pagerduty"
+	ChannelOpsgenie  = "opsgenie"
+)
+
+// Channel is a notification destination. For PagerDuty and Opsgenie the URL
+// overrides the API endpoint and Secret holds the routing or API key. It receives alerts for TargetID
+// when set, otherwise for every target matching Selector (all targets when
+// both are empty).
+type Channel struct {
+	ID        string
+	TenantID  string
+	Name      string
+	Type      string
+	URL       string
+	Secret    string
+	TargetID  string
+	Selector  string
+	CreatedAt time.Time
+}
+
+func (s *SQLiteStorage) CreateChannel(ctx context.Context, ch *Channel) error {
+	ch.ID = "ch_" + uuid.NewString()
+	ch.TenantID = tenantFor(ctx)
+	ch.CreatedAt = time.Now().UTC()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO channels (id, tenant_id, name, type, url, secret, target_id, selector, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		ch.ID, ch.TenantID, ch.Name, ch.Type, ch.URL, ch.Secret, ch.TargetID, ch.Selector, ch.CreatedAt)
+	return err
+}
+
+func (s *SQLiteStorage) GetChannel(ctx context.Context, id string) (*Channel, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	ch := &Channel{}
+	err := s.db.QueryRowContext(ctx, `SELECT id, tenant_id, name, type, url, secret, target_id, selector, created_at FROM channels WHERE id = ?`+cond, append([]interface{}{id}, args...)...).
+		Scan(&ch.ID, &ch.TenantID, &ch.Name, &ch.Type, &ch.URL, &ch.Secret, &ch.TargetID, &ch.Selector, &ch.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (s *SQLiteStorage) ListChannels(ctx context.Context) ([]*Channel, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	rows, err := s.db.QueryContext(ctx, `SELECT id, tenant_id, name, type, url, secret, target_id, selector, created_at FROM channels WHERE 1 = 1`+cond+` ORDER BY created_at ASC, id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []*Channel
+	for rows.Next() {
+		ch := &Channel{}
+		if err := rows.Scan(&ch.ID, &ch.TenantID, &ch.Name, &ch.Type, &ch.URL, &ch.Secret, &ch.TargetID, &ch.Selector, &ch.CreatedAt); err != nil {
+			return nil, err
+		}
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
+
+func (s *SQLiteStorage) DeleteChannel(ctx context.Context, id string) error {
+	cond, args := ownedBy(ctx, "tenant_id")
+	res, err := s.db.ExecContext(ctx, `DELETE FROM channels WHERE id = ?`+cond, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaintenancePause skips checks entirely while the window is open.
+	MaintenancePause = "pause"
+	// MaintenanceSuppress keeps checking but suppresses alerts and leaves the
+	// results out of uptime.
+	MaintenanceSuppress = "suppress"
+)
+
+// MaintenanceWindow applies to TargetID when set, otherwise to every target
+// matching Selector. One-off windows use StartsAt/EndsAt; recurring windows
+// open on every activation of the cron Schedule and last Duration.
+type MaintenanceWindow struct {
+	ID        string
+	TenantID  string
+	Name      string
+	Mode      string
+	TargetID  string
+	Selector  string
+	StartsAt  time.Time
+	EndsAt    time.Time
+	Schedule  string
+	Duration  time.Duration
+	CreatedAt time.Time
+}
+
+// Silence mutes alerts for matching targets until ExpiresAt without
+// affecting checks or uptime.
+type Silence struct {
+	ID        string
+	TenantID  string
+	TargetID  string
+	Selector  string
+	Comment   string
+	CreatedBy string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (s *SQLiteStorage) CreateMaintenanceWindow(ctx context.Context, w *MaintenanceWindow) error {
+	w.ID = "mw_" + uuid.NewString()
+	w.TenantID = tenantFor(ctx)
+	w.CreatedAt = time.Now().UTC()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO maintenance_windows (id, tenant_id, name, mode, target_id, selector, starts_at, ends_at, schedule, duration_ms, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		w.ID, w.TenantID, w.Name, w.Mode, w.TargetID, w.Selector, nullTime(w.StartsAt), nullTime(w.EndsAt), w.Schedule, w.Duration.Milliseconds(), w.CreatedAt)
+	return err
+}
+
+func (s *SQLiteStorage) ListMaintenanceWindows(ctx context.Context) ([]*MaintenanceWindow, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	rows, err := s.db.QueryContext(ctx, `SELECT id, tenant_id, name, mode, target_id, selector, starts_at, ends_at, schedule, duration_ms, created_at FROM maintenance_windows WHERE 1 = 1`+cond+` ORDER BY created_at ASC, id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []*MaintenanceWindow
+	for rows.Next() {
+		w := &MaintenanceWindow{}
+		var startsAt, endsAt sql.NullTime
+		var durationMs int64
+		if err := rows.Scan(&w.ID, &w.TenantID, &w.Name, &w.Mode, &w.TargetID, &w.Selector, &startsAt, &endsAt, &w.Schedule, &durationMs, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		w.StartsAt, w.EndsAt = startsAt.Time, endsAt.Time
+		w.Duration = time.Duration(durationMs) * time.Millisecond
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+func (s *SQLiteStorage) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	cond, args := ownedBy(ctx, "tenant_id")
+	res, err := s.db.ExecContext(ctx, `DELETE FROM maintenance_windows WHERE id = ?`+cond, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) CreateSilence(ctx context.Context, silence *Silence) error {
+	silence.ID = "sil_" + uuid.NewString()
+	silence.TenantID = tenantFor(ctx)
+	silence.CreatedAt = time.Now().UTC()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO silences (id, tenant_id, target_id, selector, comment, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		silence.ID, silence.TenantID, silence.TargetID, silence.Selector, silence.Comment, silence.CreatedBy, silence.CreatedAt, silence.ExpiresAt)
+	return err
+}
+
+// ListSilences returns silences still in effect at activeAt, or every
+// silence when activeAt is zero.
+func (s *SQLiteStorage) ListSilences(ctx context.Context, activeAt time.Time) ([]*Silence, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	query := `SELECT id, tenant_id, target_id, selector, comment, created_by, created_at, expires_at FROM silences WHERE 1 = 1` + cond
+	if !activeAt.IsZero() {
+		query += ` AND expires_at > ?`
+		args = append(args, activeAt)
+	}
+	query += ` ORDER BY created_at DESC, id ASC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var silences []*Silence
+	for rows.Next() {
+		sil := &Silence{}
+		if err := rows.Scan(&sil.ID, &sil.TenantID, &sil.TargetID, &sil.Selector, &sil.Comment, &sil.CreatedBy, &sil.CreatedAt, &sil.ExpiresAt); err != nil {
+			return nil, err
+		}
+		silences = append(silences, sil)
+	}
+	return silences, rows.Err()
+}
+
+// ExpireSilence ends a silence early by moving its expiry to at.
+func (s *SQLiteStorage) ExpireSilence(ctx context.Context, id string, at time.Time) error {
+	cond, args := ownedBy(ctx, "tenant_id")
+	res, err := s.db.ExecContext(ctx, `UPDATE silences SET expires_at = ? WHERE id = ? AND expires_at > ?`+cond, append([]interface{}{at, id, at}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
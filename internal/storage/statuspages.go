@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StatusPage is a public page served at /status/{slug}. Each group lists the
+// targets matching its label selector.
+type StatusPage struct {
+	ID          string
+	TenantID    string
+	Slug        string
+	Title       string
+	Description string
+	Groups      []StatusGroup
+	CreatedAt   time.Time
+}
+
+type StatusGroup struct {
+	Name     string `json:"name"`
+	Selector string `json:"selector"`
+}
+
+func (s *SQLiteStorage) CreateStatusPage(ctx context.Context, page *StatusPage) error {
+	page.ID = "sp_" + uuid.NewString()
+	page.TenantID = tenantFor(ctx)
+	page.CreatedAt = time.Now().UTC()
+	groups, err := json.Marshal(page.Groups)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO status_pages (id, tenant_id, slug, title, description, groups, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		page.ID, page.TenantID, page.Slug, page.Title, page.Description, string(groups), page.CreatedAt)
+	return err
+}
+
+// GetStatusPage looks a page up by slug. Slugs are unique across tenants.
+func (s *SQLiteStorage) GetStatusPage(ctx context.Context, slug string) (*StatusPage, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	row := s.db.QueryRowContext(ctx, `SELECT `+statusPageColumns+` FROM status_pages WHERE slug = ?`+cond, append([]interface{}{slug}, args...)...)
+	page, err := scanStatusPage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return page, err
+}
+
+func (s *SQLiteStorage) ListStatusPages(ctx context.Context) ([]*StatusPage, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	rows, err := s.db.QueryContext(ctx, `SELECT `+statusPageColumns+` FROM status_pages WHERE 1 = 1`+cond+` ORDER BY slug ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pages []*StatusPage
+	for rows.Next() {
+		page, err := scanStatusPage(rows)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, rows.Err()
+}
+
+func (s *SQLiteStorage) DeleteStatusPage(ctx context.Context, id string) error {
+	cond, args := ownedBy(ctx, "tenant_id")
+	res, err := s.db.ExecContext(ctx, `DELETE FROM status_pages WHERE id = ?`+cond, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const statusPageColumns = `id, tenant_id, slug, title, description, groups, created_at`
+
+func scanStatusPage(row interface{ Scan(...interface{}) error }) (*StatusPage, error) {
+	page := &StatusPage{}
+	var groups string
+	if err := row.Scan(&page.ID, &page.TenantID, &page.Slug, &page.Title, &page.Description, &groups, &page.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(groups), &page.Groups); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
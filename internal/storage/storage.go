@@ -4,8 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -17,21 +18,142 @@ type Storage interface {
 	ListTargets(ctx context.Context, host string, limit int, pageToken string) ([]*Target, string, error)
 	GetCheckResults(ctx context.Context, targetID string, since time.Time, limit int) ([]*CheckResult, error)
 	SaveCheckResult(ctx context.Context, targetID string, result *CheckResult) error
+	CreateIncident(ctx context.Context, incident *Incident) error
+	ResolveIncident(ctx context.Context, id string, endedAt time.Time) error
+	GetOpenIncident(ctx context.Context, targetID string) (*Incident, error)
+	ListIncidents(ctx context.Context, targetID string, openOnly bool, limit int) ([]*Incident, error)
+	EnqueueNotification(ctx context.Context, n *Notification) error
+	DueNotifications(ctx context.Context, now time.Time, limit int) ([]*Notification, error)
+	UpdateNotification(ctx context.Context, n *Notification) error
+	ListNotifications(ctx context.Context, targetID string, status string, limit int) ([]*Notification, error)
+	GetTarget(ctx context.Context, id string) (*Target, error)
+	UpdateTargetLabels(ctx context.Context, id string, labels map[string]string) (*Target, error)
+	SetTargetFlapping(ctx context.Context, id string, flapping bool) error
+	SetTargetPublic(ctx context.Context, id string, public bool) (*Target, error)
+	SetTargetInterval(ctx context.Context, id string, interval time.Duration) (*Target, error)
+	SetTargetManaged(ctx context.Context, id string, managed bool) (*Target, error)
+	SetTargetPaused(ctx context.Context, id string, paused bool) (*Target, error)
+	DeleteTarget(ctx context.Context, id string) error
+	CountTargets(ctx context.Context) (int, error)
+	ImportTargets(ctx context.Context, targets []*Target) ([]*Target, []bool, error)
+	CreateChannel(ctx context.Context, ch *Channel) error
+	GetChannel(ctx context.Context, id string) (*Channel, error)
+	ListChannels(ctx context.Context) ([]*Channel, error)
+	DeleteChannel(ctx context.Context, id string) error
+	CreateRule(ctx context.Context, rule *Rule) error
+	GetRule(ctx context.Context, id string) (*Rule, error)
+	ListRules(ctx context.Context) ([]*Rule, error)
+	DeleteRule(ctx context.Context, id string) error
+	CreateAlert(ctx context.Context, alert *Alert) error
+	GetAlert(ctx context.Context, id string) (*Alert, error)
+	GetOpenAlert(ctx context.Context, ruleID, targetID string) (*Alert, error)
+	ListAlerts(ctx context.Context, status string, limit int) ([]*Alert, error)
+	UpdateAlert(ctx context.Context, alert *Alert) error
+	CreateMaintenanceWindow(ctx context.Context, w *MaintenanceWindow) error
+	ListMaintenanceWindows(ctx context.Context) ([]*MaintenanceWindow, error)
+	DeleteMaintenanceWindow(ctx context.Context, id string) error
+	CreateSilence(ctx context.Context, silence *Silence) error
+	ListSilences(ctx context.Context, activeAt time.Time) ([]*Silence, error)
+	ExpireSilence(ctx context.Context, id string, at time.Time) error
+	GetUptime(ctx context.Context, targetID string, since time.Time) (checks int, up int, err error)
+	GetLatencyPercentile(ctx context.Context, targetID string, since time.Time, p float64) (latencyMs int, checks int, err error)
+	GetDailyUptime(ctx context.Context, targetID string, since time.Time) ([]*DailyUptime, error)
+	CreateStatusPage(ctx context.Context, page *StatusPage) error
+	GetStatusPage(ctx context.Context, slug string) (*StatusPage, error)
+	ListStatusPages(ctx context.Context) ([]*StatusPage, error)
+	DeleteStatusPage(ctx context.Context, id string) error
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+	CreateTenant(ctx context.Context, t *Tenant) error
+	GetTenant(ctx context.Context, id string) (*Tenant, error)
+	ListTenants(ctx context.Context) ([]*Tenant, error)
+	UpdateTenant(ctx context.Context, t *Tenant) error
+	Ping(ctx context.Context) error
 	Close() error
 	Init(ctx context.Context) error
 }
 
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidPageToken is returned by ListTargets for tokens it did not issue.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
 type Target struct {
-	ID        string
-	URL       string
+	ID       string
+	TenantID string
+	URL      string
+	Labels   map[string]string
+	Flapping bool
+	// Public targets have badges that can be fetched without credentials.
+	Public bool
+	// Interval overrides the checker's default interval when set.
+	Interval time.Duration
+	// Managed targets are defined in the targets file and read-only via
+	// the API.
+	Managed bool
+	// Paused targets are not checked.
+	Paused    bool
 	CreatedAt time.Time
 }
 
 type CheckResult struct {
-	CheckedAt  time.Time
-	StatusCode int
-	LatencyMs  int
-	Error      string
+	ID            int64
+	CheckedAt     time.Time
+	StatusCode    int
+	LatencyMs     int
+	Error         string
+	CertExpiresAt time.Time
+	// Maintenance marks results taken during a suppress maintenance window;
+	// they are kept but excluded from uptime.
+	Maintenance bool
+}
+
+// Failed reports whether the check counts as a failure: a transport error or
+// a 4xx/5xx response.
+func (r *CheckResult) Failed() bool {
+	return r.Error != "" || r.StatusCode == 0 || r.StatusCode >= 400
+}
+
+type Incident struct {
+	ID             string
+	TargetID       string
+	StartedAt      time.Time
+	EndedAt        time.Time
+	Duration       time.Duration
+	FirstError     string
+	TriggerResults []*CheckResult
+}
+
+func (i *Incident) Open() bool {
+	return i.EndedAt.IsZero()
+}
+
+const (
+	NotificationPending   = "pending"
+	NotificationDelivered = "delivered"
+	NotificationFailed    = "failed"
+)
+
+// Notification is an outbox entry: a rendered request waiting to be (or
+// already) delivered to a notification endpoint.
+type Notification struct {
+	ID             string
+	TargetID       string
+	ChannelID      string
+	Event          string
+	URL            string
+	Headers        map[string]string
+	Payload        string
+	Status         string
+	Attempts       int
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	NextAttemptAt  time.Time
+	DeliveredAt    time.Time
 }
 
 type SQLiteStorage struct {
@@ -44,11 +166,20 @@ func NewSQLiteStorage(db *sql.DB) *SQLiteStorage {
 
 func (s *SQLiteStorage) Init(ctx context.Context) error {
 	_, err := s.db.ExecContext(ctx, `
-		CREATE TABLE IF NOT EXISTS targets (
+		CREATE TABLE IF NOT EXISTS tenants (
 			id TEXT PRIMARY KEY,
-			url TEXT UNIQUE NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			max_targets INTEGER NOT NULL DEFAULT 0,
+			min_interval_ms INTEGER NOT NULL DEFAULT 0,
 			created_at DATETIME NOT NULL
 		);
+		CREATE TABLE IF NOT EXISTS targets (
+			id TEXT PRIMARY KEY,
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			url TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			UNIQUE (tenant_id, url)
+		);
 		CREATE TABLE IF NOT EXISTS check_results (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			target_id TEXT NOT NULL,
@@ -57,63 +188,483 @@ func (s *SQLiteStorage) Init(ctx context.Context) error {
 			latency_ms INTEGER,
 			error TEXT
 		);
+		CREATE INDEX IF NOT EXISTS idx_check_results_target ON check_results (target_id, checked_at);
 		CREATE TABLE IF NOT EXISTS idempotency_keys (
-			key TEXT PRIMARY KEY,
-			target_id TEXT NOT NULL
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			key TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			PRIMARY KEY (tenant_id, key)
+		);
+		CREATE TABLE IF NOT EXISTS incidents (
+			id TEXT PRIMARY KEY,
+			target_id TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			ended_at DATETIME,
+			duration_ms INTEGER,
+			first_error TEXT
+		);
+		CREATE INDEX IF NOT EXISTS idx_incidents_target ON incidents (target_id, started_at);
+		CREATE TABLE IF NOT EXISTS incident_results (
+			incident_id TEXT NOT NULL,
+			result_id INTEGER NOT NULL,
+			PRIMARY KEY (incident_id, result_id)
+		);
+		CREATE TABLE IF NOT EXISTS notifications (
+			id TEXT PRIMARY KEY,
+			target_id TEXT NOT NULL,
+			event TEXT NOT NULL,
+			url TEXT NOT NULL,
+			headers TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_status_code INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			next_attempt_at DATETIME NOT NULL,
+			delivered_at DATETIME
+		);
+		CREATE INDEX IF NOT EXISTS idx_notifications_due ON notifications (status, next_attempt_at);
+		CREATE TABLE IF NOT EXISTS alert_rules (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			condition TEXT NOT NULL,
+			severity TEXT NOT NULL,
+			target_id TEXT NOT NULL DEFAULT '',
+			selector TEXT NOT NULL DEFAULT '',
+			channel_ids TEXT NOT NULL DEFAULT '[]',
+			repeat_interval_ms INTEGER NOT NULL DEFAULT 0,
+			escalation_channel_id TEXT NOT NULL DEFAULT '',
+			escalate_after_ms INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS alerts (
+			id TEXT PRIMARY KEY,
+			rule_id TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			severity TEXT NOT NULL,
+			status TEXT NOT NULL,
+			summary TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			last_notified_at DATETIME NOT NULL,
+			acknowledged_at DATETIME,
+			acknowledged_by TEXT NOT NULL DEFAULT '',
+			escalated_at DATETIME,
+			resolved_at DATETIME
+		);
+		CREATE INDEX IF NOT EXISTS idx_alerts_open ON alerts (rule_id, target_id, status);
+		CREATE TABLE IF NOT EXISTS channels (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL DEFAULT '',
+			target_id TEXT NOT NULL DEFAULT '',
+			selector TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS maintenance_windows (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			mode TEXT NOT NULL,
+			target_id TEXT NOT NULL DEFAULT '',
+			selector TEXT NOT NULL DEFAULT '',
+			starts_at DATETIME,
+			ends_at DATETIME,
+			schedule TEXT NOT NULL DEFAULT '',
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS silences (
+			id TEXT PRIMARY KEY,
+			target_id TEXT NOT NULL DEFAULT '',
+			selector TEXT NOT NULL DEFAULT '',
+			comment TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS daily_rollups (
+			target_id TEXT NOT NULL,
+			day TEXT NOT NULL,
+			checks INTEGER NOT NULL DEFAULT 0,
+			up INTEGER NOT NULL DEFAULT 0,
+			latency_ms_sum INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (target_id, day)
+		);
+		CREATE TABLE IF NOT EXISTS status_pages (
+			id TEXT PRIMARY KEY,
+			slug TEXT UNIQUE NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			groups TEXT NOT NULL DEFAULT '[]',
+			created_at DATETIME NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL DEFAULT '',
+			scope TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			hash TEXT UNIQUE NOT NULL,
+			created_at DATETIME NOT NULL,
+			last_used_at DATETIME,
+			revoked_at DATETIME
 		);
 	`)
+	if err != nil {
+		return err
+	}
+	return s.migrate(ctx)
+}
+
+// migrate adds columns introduced after a table was first created; CREATE
+// TABLE IF NOT EXISTS leaves existing databases untouched.
+func (s *SQLiteStorage) migrate(ctx context.Context) error {
+	columns := []struct{ table, column, definition string }{
+		{"targets", "labels", "TEXT NOT NULL DEFAULT '{}'"},
+		{"notifications", "channel_id", "TEXT NOT NULL DEFAULT ''"},
+		{"check_results", "cert_expires_at", "DATETIME"},
+		{"check_results", "maintenance", "INTEGER NOT NULL DEFAULT 0"},
+		{"targets", "flapping", "INTEGER NOT NULL DEFAULT 0"},
+		{"targets", "public", "INTEGER NOT NULL DEFAULT 0"},
+		{"targets", "tenant_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"targets", "interval_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"targets", "managed", "INTEGER NOT NULL DEFAULT 0"},
+		{"targets", "paused", "INTEGER NOT NULL DEFAULT 0"},
+		{"channels", "tenant_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"alert_rules", "tenant_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"maintenance_windows", "tenant_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"silences", "tenant_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"status_pages", "tenant_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"api_keys", "tenant_id", "TEXT NOT NULL DEFAULT 'default'"},
+	}
+	for _, c := range columns {
+		if err := s.addColumn(ctx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// URLs and idempotency keys used to be unique across the instance.
+	err := s.rebuildTable(ctx, "targets", "url TEXT UNIQUE", `
+		CREATE TABLE targets_new (
+			id TEXT PRIMARY KEY,
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			url TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			labels TEXT NOT NULL DEFAULT '{}',
+			flapping INTEGER NOT NULL DEFAULT 0,
+			public INTEGER NOT NULL DEFAULT 0,
+			interval_ms INTEGER NOT NULL DEFAULT 0,
+			managed INTEGER NOT NULL DEFAULT 0,
+			paused INTEGER NOT NULL DEFAULT 0,
+			UNIQUE (tenant_id, url)
+		)`, `id, tenant_id, url, created_at, labels, flapping, public, interval_ms, managed, paused`)
+	if err != nil {
+		return err
+	}
+	err = s.rebuildTable(ctx, "idempotency_keys", "key TEXT PRIMARY KEY", `
+		CREATE TABLE idempotency_keys_new (
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			key TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			PRIMARY KEY (tenant_id, key)
+		)`, `key, target_id`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT OR IGNORE INTO tenants (id, name, created_at) VALUES (?, ?, ?)`,
+		DefaultTenantID, "Default", time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return s.backfillRollups(ctx)
+}
+
+// rebuildTable recreates table from ddl, which must create table+"_new", if
+// its current definition contains stale; SQLite cannot change constraints in
+// place. The given columns are copied over.
+func (s *SQLiteStorage) rebuildTable(ctx context.Context, table, stale, ddl, columns string) error {
+	var current string
+	if err := s.db.QueryRowContext(ctx, `SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&current); err != nil {
+		return err
+	}
+	if !strings.Contains(current, stale) {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		ddl,
+		`INSERT INTO ` + table + `_new (` + columns + `) SELECT ` + columns + ` FROM ` + table,
+		`DROP TABLE ` + table,
+		`ALTER TABLE ` + table + `_new RENAME TO ` + table,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// backfillRollups builds daily rollups from existing check results the first
+// time a database without them is opened.
+func (s *SQLiteStorage) backfillRollups(ctx context.Context) error {
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM daily_rollups`).Scan(&n); err != nil || n > 0 {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO daily_rollups (target_id, day, checks, up, latency_ms_sum)
+		SELECT target_id, date(checked_at), COUNT(*),
+			SUM(CASE WHEN error = '' AND status_code > 0 AND status_code < 400 THEN 1 ELSE 0 END),
+			COALESCE(SUM(latency_ms), 0)
+		FROM check_results WHERE maintenance = 0 AND date(checked_at) IS NOT NULL
+		GROUP BY target_id, date(checked_at)`)
 	return err
 }
 
-func (s *SQLiteStorage) CreateTarget(ctx context.Context, url string, idempotencyKey string) (*Target, bool, error) {
-	var isNew bool
-	id := "t_" + uuid.NewString()
-	createdAt := time.Now().UTC()
+func (s *SQLiteStorage) addColumn(ctx context.Context, table, column, definition string) error {
+	rows, err := s.db.QueryContext(ctx, `PRAGMA table_info(`+table+`)`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = s.db.ExecContext(ctx, `ALTER TABLE `+table+` ADD COLUMN `+column+` `+definition)
+	return err
+}
+
+// CreateTarget adds url to the tenant in ctx, or returns the tenant's
+// existing target for it. It fails with ErrQuotaExceeded when the tenant is
+// at its target limit.
+func (s *SQLiteStorage) CreateTarget(ctx context.Context, url string, idempotencyKey string) (*Target, bool, error) {
+	tenant := tenantFor(ctx)
 	if idempotencyKey != "" {
 		var existingID string
-		err := s.db.QueryRowContext(ctx, `SELECT target_id FROM idempotency_keys WHERE key = ?`, idempotencyKey).Scan(&existingID)
+		err := s.db.QueryRowContext(ctx, `SELECT target_id FROM idempotency_keys WHERE tenant_id = ? AND key = ?`, tenant, idempotencyKey).Scan(&existingID)
 		if err == nil {
-			target, err := s.getTarget(ctx, existingID)
+			target, err := s.getTarget(WithTenant(ctx, tenant), existingID)
 			return target, false, err
 		} else if !errors.Is(err, sql.ErrNoRows) {
 			return nil, false, err
 		}
 	}
 
-	res, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO targets (id, url, created_at) VALUES (?, ?, ?)`, id, url, createdAt)
+	// The quota is checked in the INSERT itself so concurrent creates cannot
+	// overshoot it.
+	res, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO targets (id, tenant_id, url, created_at)
+		SELECT ?, ?, ?, ? WHERE COALESCE((SELECT max_targets FROM tenants WHERE id = ?), 0) = 0
+			OR (SELECT COUNT(*) FROM targets WHERE tenant_id = ?) < (SELECT max_targets FROM tenants WHERE id = ?)`,
+		"t_"+uuid.NewString(), tenant, url, time.Now().UTC(), tenant, tenant, tenant)
 	if err != nil {
 		return nil, false, err
 	}
-
 	rowsAffected, _ := res.RowsAffected()
-	isNew = (rowsAffected > 0)
+	isNew := rowsAffected > 0
 
-	err = s.db.QueryRowContext(ctx, `SELECT id, created_at FROM targets WHERE url = ?`, url).Scan(&id, &createdAt)
+	target, err := scanTarget(s.db.QueryRowContext(ctx, `SELECT `+targetColumns+` FROM targets WHERE tenant_id = ? AND url = ?`, tenant, url))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, ErrQuotaExceeded
+	}
 	if err != nil {
 		return nil, false, err
 	}
 
 	if idempotencyKey != "" {
-		_, err = s.db.ExecContext(ctx, `INSERT OR IGNORE INTO idempotency_keys (key, target_id) VALUES (?, ?)`, idempotencyKey, id)
+		_, err = s.db.ExecContext(ctx, `INSERT OR IGNORE INTO idempotency_keys (tenant_id, key, target_id) VALUES (?, ?, ?)`, tenant, idempotencyKey, target.ID)
 		if err != nil {
 			return nil, false, err
 		}
 	}
+	return target, isNew, nil
+}
 
-	return &Target{ID: id, URL: url, CreatedAt: createdAt}, isNew, nil
+// ImportTargets creates targets with their labels, public flag, interval and
+// managed flag in one transaction. Targets whose URL the tenant already has
+// are left unchanged and returned as they are stored, with created false.
+// Nothing is written when the tenant's quota would be exceeded.
+func (s *SQLiteStorage) ImportTargets(ctx context.Context, targets []*Target) ([]*Target, []bool, error) {
+	tenant := tenantFor(ctx)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	stored := make([]*Target, len(targets))
+	created := make([]bool, len(targets))
+	for i, t := range targets {
+		labels := t.Labels
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		encoded, err := json.Marshal(labels)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO targets (id, tenant_id, url, labels, public, interval_ms, managed, created_at)
+			SELECT ?, ?, ?, ?, ?, ?, ?, ? WHERE COALESCE((SELECT max_targets FROM tenants WHERE id = ?), 0) = 0
+				OR (SELECT COUNT(*) FROM targets WHERE tenant_id = ?) < (SELECT max_targets FROM tenants WHERE id = ?)`,
+			"t_"+uuid.NewString(), tenant, t.URL, string(encoded), t.Public, t.Interval.Milliseconds(), t.Managed, time.Now().UTC(), tenant, tenant, tenant)
+		if err != nil {
+			return nil, nil, err
+		}
+		n, _ := res.RowsAffected()
+		created[i] = n > 0
+		stored[i], err = scanTarget(tx.QueryRowContext(ctx, `SELECT `+targetColumns+` FROM targets WHERE tenant_id = ? AND url = ?`, tenant, t.URL))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrQuotaExceeded
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return stored, created, nil
+}
+
+const targetColumns = `id, tenant_id, url, labels, flapping, public, interval_ms, managed, paused, created_at`
+
+func scanTarget(row scanner) (*Target, error) {
+	t := &Target{}
+	var labels string
+	var intervalMs int64
+	if err := row.Scan(&t.ID, &t.TenantID, &t.URL, &labels, &t.Flapping, &t.Public, &intervalMs, &t.Managed, &t.Paused, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	t.Interval = time.Duration(intervalMs) * time.Millisecond
+	if err := json.Unmarshal([]byte(labels), &t.Labels); err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
 func (s *SQLiteStorage) getTarget(ctx context.Context, id string) (*Target, error) {
-	t := &Target{ID: id}
-	err := s.db.QueryRowContext(ctx, `SELECT url, created_at FROM targets WHERE id = ?`, id).Scan(&t.URL, &t.CreatedAt)
+	cond, args := ownedBy(ctx, "tenant_id")
+	t, err := scanTarget(s.db.QueryRowContext(ctx, `SELECT `+targetColumns+` FROM targets WHERE id = ?`+cond, append([]interface{}{id}, args...)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	return t, err
 }
 
+func (s *SQLiteStorage) GetTarget(ctx context.Context, id string) (*Target, error) {
+	return s.getTarget(ctx, id)
+}
+
+// updateTarget sets one column of a target owned by the tenant in ctx and
+// returns the updated target.
+func (s *SQLiteStorage) updateTarget(ctx context.Context, id, column string, value interface{}) (*Target, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	res, err := s.db.ExecContext(ctx, `UPDATE targets SET `+column+` = ? WHERE id = ?`+cond, append([]interface{}{value, id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return s.getTarget(ctx, id)
+}
+
+func (s *SQLiteStorage) UpdateTargetLabels(ctx context.Context, id string, labels map[string]string) (*Target, error) {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	encoded, err := json.Marshal(labels)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateTarget(ctx, id, "labels", string(encoded))
+}
+
+func (s *SQLiteStorage) SetTargetPublic(ctx context.Context, id string, public bool) (*Target, error) {
+	return s.updateTarget(ctx, id, "public", public)
+}
+
+// SetTargetInterval sets how often the target is checked; zero restores the
+// checker's default.
+func (s *SQLiteStorage) SetTargetInterval(ctx context.Context, id string, interval time.Duration) (*Target, error) {
+	return s.updateTarget(ctx, id, "interval_ms", interval.Milliseconds())
+}
+
+func (s *SQLiteStorage) SetTargetManaged(ctx context.Context, id string, managed bool) (*Target, error) {
+	return s.updateTarget(ctx, id, "managed", managed)
+}
+
+func (s *SQLiteStorage) SetTargetPaused(ctx context.Context, id string, paused bool) (*Target, error) {
+	return s.updateTarget(ctx, id, "paused", paused)
+}
+
+// DeleteTarget removes a target with its results, incidents, rollups and
+// alerts. Delivered notifications are kept as history.
+func (s *SQLiteStorage) DeleteTarget(ctx context.Context, id string) error {
+	cond, args := ownedBy(ctx, "tenant_id")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `DELETE FROM targets WHERE id = ?`+cond, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	for _, stmt := range []string{
+		`DELETE FROM incident_results WHERE incident_id IN (SELECT id FROM incidents WHERE target_id = ?)`,
+		`DELETE FROM incidents WHERE target_id = ?`,
+		`DELETE FROM check_results WHERE target_id = ?`,
+		`DELETE FROM daily_rollups WHERE target_id = ?`,
+		`DELETE FROM alerts WHERE target_id = ?`,
+		`DELETE FROM idempotency_keys WHERE target_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) SetTargetFlapping(ctx context.Context, id string, flapping bool) error {
+	_, err := s.updateTarget(ctx, id, "flapping", flapping)
+	return err
+}
+
 func (s *SQLiteStorage) ListTargets(ctx context.Context, host string, limit int, pageToken string) ([]*Target, string, error) {
 	var whereClauses []string
 	var args []interface{}
 
+	if tenant := TenantFromContext(ctx); tenant != "" {
+		whereClauses = append(whereClauses, "tenant_id = ?")
+		args = append(args, tenant)
+	}
 	if host != "" {
 		whereClauses = append(whereClauses, "LOWER(url) LIKE ?")
 		args = append(args, "%://"+strings.ToLower(host)+"%")
@@ -124,15 +675,15 @@ func (s *SQLiteStorage) ListTargets(ctx context.Context, host string, limit int,
 	if pageToken != "" {
 		decoded, err := base64.StdEncoding.DecodeString(pageToken)
 		if err != nil {
-			return nil, "", err
+			return nil, "", ErrInvalidPageToken
 		}
 		parts := strings.Split(string(decoded), "|")
-		if len(parts) != 2 {
-			return nil, "", fmt.Errorf("invalid page token")
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, "", ErrInvalidPageToken
 		}
 		createdAt, err = time.Parse(time.RFC3339, parts[0])
 		if err != nil {
-			return nil, "", err
+			return nil, "", ErrInvalidPageToken
 		}
 		cursorID = parts[1]
 		whereClauses = append(whereClauses, "(created_at > ? OR (created_at = ? AND id > ?))")
@@ -144,7 +695,7 @@ func (s *SQLiteStorage) ListTargets(ctx context.Context, host string, limit int,
 		where = "WHERE " + strings.Join(whereClauses, " AND ")
 	}
 
-	query := `SELECT id, url, created_at FROM targets ` + where + ` ORDER BY created_at ASC, id ASC LIMIT ?`
+	query := `SELECT ` + targetColumns + ` FROM targets ` + where + ` ORDER BY created_at ASC, id ASC LIMIT ?`
 	args = append(args, limit+1)
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
@@ -155,8 +706,8 @@ func (s *SQLiteStorage) ListTargets(ctx context.Context, host string, limit int,
 
 	var items []*Target
 	for rows.Next() {
-		t := &Target{}
-		if err := rows.Scan(&t.ID, &t.URL, &t.CreatedAt); err != nil {
+		t, err := scanTarget(rows)
+		if err != nil {
 			return nil, "", err
 		}
 		items = append(items, t)
@@ -164,16 +715,17 @@ func (s *SQLiteStorage) ListTargets(ctx context.Context, host string, limit int,
 
 	var nextToken string
 	if len(items) > limit {
-		last := items[limit]
-		nextToken = base64.StdEncoding.EncodeToString([]byte(last.CreatedAt.Format(time.RFC3339) + "|" + last.ID))
+		last := items[limit-1]
+		nextToken = base64.StdEncoding.EncodeToString([]byte(last.CreatedAt.Format(time.RFC3339Nano) + "|" + last.ID))
 		items = items[:limit]
 	}
 	return items, nextToken, nil
 }
 
 func (s *SQLiteStorage) GetCheckResults(ctx context.Context, targetID string, since time.Time, limit int) ([]*CheckResult, error) {
-	query := `SELECT checked_at, status_code, latency_ms, error FROM check_results WHERE target_id = ?`
-	args := []interface{}{targetID}
+	cond, args := targetOwnedBy(ctx, "target_id")
+	query := `SELECT id, checked_at, status_code, latency_ms, error, cert_expires_at, maintenance FROM check_results WHERE target_id = ?` + cond
+	args = append([]interface{}{targetID}, args...)
 	if !since.IsZero() {
 		query += ` AND checked_at >= ?`
 		args = append(args, since)
@@ -190,20 +742,328 @@ func (s *SQLiteStorage) GetCheckResults(ctx context.Context, targetID string, si
 	var results []*CheckResult
 	for rows.Next() {
 		r := &CheckResult{}
-		if err := rows.Scan(&r.CheckedAt, &r.StatusCode, &r.LatencyMs, &r.Error); err != nil {
+		var certExpiresAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.CheckedAt, &r.StatusCode, &r.LatencyMs, &r.Error, &certExpiresAt, &r.Maintenance); err != nil {
 			return nil, err
 		}
+		r.CertExpiresAt = certExpiresAt.Time
 		results = append(results, r)
 	}
 	return results, nil
 }
 
+// SaveCheckResult stores the result and adds it to its target's daily
+// rollup, unless it was taken during maintenance.
 func (s *SQLiteStorage) SaveCheckResult(ctx context.Context, targetID string, result *CheckResult) error {
-	_, err := s.db.ExecContext(ctx, `INSERT INTO check_results (target_id, checked_at, status_code, latency_ms, error) VALUES (?, ?, ?, ?, ?)`,
-		targetID, result.CheckedAt, result.StatusCode, result.LatencyMs, result.Error)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO check_results (target_id, checked_at, status_code, latency_ms, error, cert_expires_at, maintenance) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		targetID, result.CheckedAt, result.StatusCode, result.LatencyMs, result.Error, nullTime(result.CertExpiresAt), result.Maintenance)
+	if err != nil {
+		return err
+	}
+	if result.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+
+	if !result.Maintenance {
+		up := 0
+		if !result.Failed() {
+			up = 1
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO daily_rollups (target_id, day, checks, up, latency_ms_sum) VALUES (?, ?, 1, ?, ?)
+			ON CONFLICT (target_id, day) DO UPDATE SET checks = checks + 1, up = up + excluded.up, latency_ms_sum = latency_ms_sum + excluded.latency_ms_sum`,
+			targetID, result.CheckedAt.UTC().Format(dayLayout), up, result.LatencyMs)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetUptime counts the checks since the given time and how many of them
+// succeeded. Results taken during maintenance are not counted.
+func (s *SQLiteStorage) GetUptime(ctx context.Context, targetID string, since time.Time) (int, int, error) {
+	cond, args := targetOwnedBy(ctx, "target_id")
+	var checks, up int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN error = '' AND status_code > 0 AND status_code < 400 THEN 1 ELSE 0 END), 0)
+		FROM check_results WHERE target_id = ? AND checked_at >= ? AND maintenance = 0`+cond, append([]interface{}{targetID, since}, args...)...).Scan(&checks, &up)
+	return checks, up, err
+}
+
+// GetLatencyPercentile returns the pth percentile, by nearest rank, of the
+// latencies of successful checks since the given time, and how many such
+// checks there were. The database ranks them, so only one row is read.
+func (s *SQLiteStorage) GetLatencyPercentile(ctx context.Context, targetID string, since time.Time, p float64) (int, int, error) {
+	cond, args := targetOwnedBy(ctx, "target_id")
+	where := ` FROM check_results WHERE target_id = ? AND checked_at >= ? AND error = '' AND status_code > 0 AND status_code < 400` + cond
+	args = append([]interface{}{targetID, since}, args...)
+
+	var checks int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*)`+where, args...).Scan(&checks); err != nil || checks == 0 {
+		return 0, 0, err
+	}
+	rank := min(max(int(math.Ceil(p/100*float64(checks))), 1), checks)
+	var latency int
+	err := s.db.QueryRowContext(ctx, `SELECT latency_ms`+where+` ORDER BY latency_ms LIMIT 1 OFFSET ?`, append(args, rank-1)...).Scan(&latency)
+	return latency, checks, err
+}
+
+const dayLayout = "2006-01-02"
+
+// DailyUptime is one UTC day of a target's check results, excluding those
+// taken during maintenance.
+type DailyUptime struct {
+	Day          time.Time
+	Checks       int
+	Up           int
+	LatencyMsSum int64
+}
+
+// GetDailyUptime returns the target's rollups for days on or after since,
+// oldest first. Days without checks are omitted.
+func (s *SQLiteStorage) GetDailyUptime(ctx context.Context, targetID string, since time.Time) ([]*DailyUptime, error) {
+	cond, args := targetOwnedBy(ctx, "target_id")
+	rows, err := s.db.QueryContext(ctx, `SELECT day, checks, up, latency_ms_sum FROM daily_rollups WHERE target_id = ? AND day >= ?`+cond+` ORDER BY day ASC`,
+		append([]interface{}{targetID, since.UTC().Format(dayLayout)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []*DailyUptime
+	for rows.Next() {
+		d := &DailyUptime{}
+		var day string
+		if err := rows.Scan(&day, &d.Checks, &d.Up, &d.LatencyMsSum); err != nil {
+			return nil, err
+		}
+		if d.Day, err = time.Parse(dayLayout, day); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+func (s *SQLiteStorage) CreateIncident(ctx context.Context, incident *Incident) error {
+	incident.ID = "inc_" + uuid.NewString()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO incidents (id, target_id, started_at, first_error) VALUES (?, ?, ?, ?)`,
+		incident.ID, incident.TargetID, incident.StartedAt, incident.FirstError)
+	if err != nil {
+		return err
+	}
+	for _, r := range incident.TriggerResults {
+		_, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO incident_results (incident_id, result_id) VALUES (?, ?)`, incident.ID, r.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) ResolveIncident(ctx context.Context, id string, endedAt time.Time) error {
+	var startedAt time.Time
+	err := s.db.QueryRowContext(ctx, `SELECT started_at FROM incidents WHERE id = ?`, id).Scan(&startedAt)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `UPDATE incidents SET ended_at = ?, duration_ms = ? WHERE id = ? AND ended_at IS NULL`,
+		endedAt, endedAt.Sub(startedAt).Milliseconds(), id)
+	return err
+}
+
+func (s *SQLiteStorage) GetOpenIncident(ctx context.Context, targetID string) (*Incident, error) {
+	cond, args := targetOwnedBy(ctx, "target_id")
+	incidents, err := s.queryIncidents(ctx, `WHERE target_id = ? AND ended_at IS NULL`+cond, append([]interface{}{targetID}, args...), 1)
+	if err != nil || len(incidents) == 0 {
+		return nil, err
+	}
+	return incidents[0], nil
+}
+
+func (s *SQLiteStorage) ListIncidents(ctx context.Context, targetID string, openOnly bool, limit int) ([]*Incident, error) {
+	var whereClauses []string
+	var args []interface{}
+	if targetID != "" {
+		whereClauses = append(whereClauses, "target_id = ?")
+		args = append(args, targetID)
+	}
+	if openOnly {
+		whereClauses = append(whereClauses, "ended_at IS NULL")
+	}
+	if tenant := TenantFromContext(ctx); tenant != "" {
+		whereClauses = append(whereClauses, "target_id IN (SELECT id FROM targets WHERE tenant_id = ?)")
+		args = append(args, tenant)
+	}
+
+	where := ""
+	if len(whereClauses) > 0 {
+		where = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	return s.queryIncidents(ctx, where, args, limit)
+}
+
+func (s *SQLiteStorage) queryIncidents(ctx context.Context, where string, args []interface{}, limit int) ([]*Incident, error) {
+	query := `SELECT id, target_id, started_at, ended_at, duration_ms, first_error FROM incidents ` + where + ` ORDER BY started_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var incidents []*Incident
+	for rows.Next() {
+		inc := &Incident{}
+		var endedAt sql.NullTime
+		var durationMs sql.NullInt64
+		var firstError sql.NullString
+		if err := rows.Scan(&inc.ID, &inc.TargetID, &inc.StartedAt, &endedAt, &durationMs, &firstError); err != nil {
+			return nil, err
+		}
+		inc.EndedAt = endedAt.Time
+		inc.Duration = time.Duration(durationMs.Int64) * time.Millisecond
+		inc.FirstError = firstError.String
+		incidents = append(incidents, inc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, inc := range incidents {
+		inc.TriggerResults, err = s.incidentResults(ctx, inc.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return incidents, nil
+}
+
+func (s *SQLiteStorage) incidentResults(ctx context.Context, incidentID string) ([]*CheckResult, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.checked_at, r.status_code, r.latency_ms, r.error
+		FROM incident_results ir JOIN check_results r ON r.id = ir.result_id
+		WHERE ir.incident_id = ? ORDER BY r.checked_at ASC`, incidentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*CheckResult
+	for rows.Next() {
+		r := &CheckResult{}
+		if err := rows.Scan(&r.ID, &r.CheckedAt, &r.StatusCode, &r.LatencyMs, &r.Error); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func (s *SQLiteStorage) EnqueueNotification(ctx context.Context, n *Notification) error {
+	n.ID = "ntf_" + uuid.NewString()
+	n.Status = NotificationPending
+	n.CreatedAt = time.Now().UTC()
+	if n.NextAttemptAt.IsZero() {
+		n.NextAttemptAt = n.CreatedAt
+	}
+	headers, err := json.Marshal(n.Headers)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO notifications (id, target_id, channel_id, event, url, headers, payload, status, created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.ID, n.TargetID, n.ChannelID, n.Event, n.URL, string(headers), n.Payload, n.Status, n.CreatedAt, n.NextAttemptAt)
 	return err
 }
 
+func (s *SQLiteStorage) DueNotifications(ctx context.Context, now time.Time, limit int) ([]*Notification, error) {
+	return s.queryNotifications(ctx, `WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at ASC`,
+		[]interface{}{NotificationPending, now}, limit)
+}
+
+func (s *SQLiteStorage) UpdateNotification(ctx context.Context, n *Notification) error {
+	var deliveredAt interface{}
+	if !n.DeliveredAt.IsZero() {
+		deliveredAt = n.DeliveredAt
+	}
+	_, err := s.db.ExecContext(ctx, `UPDATE notifications SET status = ?, attempts = ?, last_status_code = ?, last_error = ?, next_attempt_at = ?, delivered_at = ? WHERE id = ?`,
+		n.Status, n.Attempts, n.LastStatusCode, n.LastError, n.NextAttemptAt, deliveredAt, n.ID)
+	return err
+}
+
+func (s *SQLiteStorage) ListNotifications(ctx context.Context, targetID string, status string, limit int) ([]*Notification, error) {
+	var whereClauses []string
+	var args []interface{}
+	if targetID != "" {
+		whereClauses = append(whereClauses, "target_id = ?")
+		args = append(args, targetID)
+	}
+	if status != "" {
+		whereClauses = append(whereClauses, "status = ?")
+		args = append(args, status)
+	}
+	if tenant := TenantFromContext(ctx); tenant != "" {
+		whereClauses = append(whereClauses, "target_id IN (SELECT id FROM targets WHERE tenant_id = ?)")
+		args = append(args, tenant)
+	}
+
+	where := ""
+	if len(whereClauses) > 0 {
+		where = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	return s.queryNotifications(ctx, where+` ORDER BY created_at DESC`, args, limit)
+}
+
+func (s *SQLiteStorage) queryNotifications(ctx context.Context, where string, args []interface{}, limit int) ([]*Notification, error) {
+	query := `SELECT id, target_id, channel_id, event, url, headers, payload, status, attempts, last_status_code, last_error, created_at, next_attempt_at, delivered_at FROM notifications ` + where + ` LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*Notification
+	for rows.Next() {
+		n := &Notification{}
+		var headers string
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.TargetID, &n.ChannelID, &n.Event, &n.URL, &headers, &n.Payload, &n.Status, &n.Attempts,
+			&n.LastStatusCode, &n.LastError, &n.CreatedAt, &n.NextAttemptAt, &deliveredAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(headers), &n.Headers); err != nil {
+			return nil, err
+		}
+		n.DeliveredAt = deliveredAt.Time
+		items = append(items, n)
+	}
+	return items, rows.Err()
+}
+
+// Ping reads the schema; sql.DB.Ping alone does not touch the database file
+// with SQLite.
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	var tables int
+	return s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master`).Scan(&tables)
+}
+
 func (s *SQLiteStorage) Close() error {
 	return s.db.Close()
 }
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -106,3 +107,305 @@ func TestGetCheckResults(t *testing.T) {
 	assert.Len(t, fetchedSince, 1)
 	assert.Equal(t, 404, fetchedSince[0].StatusCode)
 }
+
+func TestIncidents(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+
+	target, _, err := s.CreateTarget(context.Background(), "https://test.com", "")
+	assert.NoError(t, err)
+
+	failed := &CheckResult{CheckedAt: time.Now().Add(-time.Minute), StatusCode: 503}
+	assert.NoError(t, s.SaveCheckResult(context.Background(), target.ID, failed))
+	assert.NotZero(t, failed.ID)
+
+	inc := &Incident{TargetID: target.ID, StartedAt: failed.CheckedAt, FirstError: "HTTP 503", TriggerResults: []*CheckResult{failed}}
+	assert.NoError(t, s.CreateIncident(context.Background(), inc))
+
+	open, err := s.GetOpenIncident(context.Background(), target.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, inc.ID, open.ID)
+	assert.Len(t, open.TriggerResults, 1)
+	assert.Equal(t, 503, open.TriggerResults[0].StatusCode)
+
+	assert.NoError(t, s.ResolveIncident(context.Background(), inc.ID, time.Now()))
+
+	open, err = s.GetOpenIncident(context.Background(), target.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, open)
+
+	all, err := s.ListIncidents(context.Background(), "", false, 10)
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.False(t, all[0].Open())
+	assert.InDelta(t, time.Minute.Seconds(), all[0].Duration.Seconds(), 1)
+}
+
+func TestGetUptime_ExcludesMaintenance(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+
+	target, _, err := s.CreateTarget(context.Background(), "https://test.com", "")
+	assert.NoError(t, err)
+
+	now := time.Now().UTC()
+	results := []*CheckResult{
+		{CheckedAt: now.Add(-3 * time.Minute), StatusCode: 200},
+		{CheckedAt: now.Add(-2 * time.Minute), StatusCode: 503},
+		{CheckedAt: now.Add(-time.Minute), StatusCode: 0, Error: "timeout", Maintenance: true},
+		{CheckedAt: now.Add(-48 * time.Hour), StatusCode: 500},
+	}
+	for _, r := range results {
+		assert.NoError(t, s.SaveCheckResult(context.Background(), target.ID, r))
+	}
+
+	checks, up, err := s.GetUptime(context.Background(), target.ID, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, checks)
+	assert.Equal(t, 1, up)
+
+	latest, err := s.GetCheckResults(context.Background(), target.ID, time.Time{}, 1)
+	assert.NoError(t, err)
+	assert.True(t, latest[0].Maintenance)
+}
+
+func TestGetLatencyPercentile(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+	ctx := context.Background()
+
+	target, _, err := s.CreateTarget(ctx, "https://test.com", "")
+	assert.NoError(t, err)
+
+	now := time.Now().UTC()
+	for i, ms := range []int{5, 1, 4, 2, 3, 6, 7, 8, 9, 10} {
+		assert.NoError(t, s.SaveCheckResult(ctx, target.ID, &CheckResult{CheckedAt: now.Add(-time.Duration(i) * time.Second), StatusCode: 200, LatencyMs: ms}))
+	}
+	// Failed and old checks are not counted.
+	assert.NoError(t, s.SaveCheckResult(ctx, target.ID, &CheckResult{CheckedAt: now, StatusCode: 500, LatencyMs: 900}))
+	assert.NoError(t, s.SaveCheckResult(ctx, target.ID, &CheckResult{CheckedAt: now.Add(-time.Hour), StatusCode: 200, LatencyMs: 900}))
+
+	since := now.Add(-time.Minute)
+	for p, want := range map[float64]int{95: 10, 50: 5, 1: 1} {
+		latency, checks, err := s.GetLatencyPercentile(ctx, target.ID, since, p)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, checks)
+		assert.Equal(t, want, latency, "p%g", p)
+	}
+
+	latency, checks, err := s.GetLatencyPercentile(ctx, target.ID, now.Add(time.Minute), 95)
+	assert.NoError(t, err)
+	assert.Zero(t, checks)
+	assert.Zero(t, latency)
+}
+
+func TestDailyUptime(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+	ctx := context.Background()
+
+	target, _, err := s.CreateTarget(ctx, "https://test.com", "")
+	assert.NoError(t, err)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yesterday := today.Add(-24 * time.Hour)
+	results := []*CheckResult{
+		{CheckedAt: yesterday.Add(time.Hour), StatusCode: 200, LatencyMs: 100},
+		{CheckedAt: yesterday.Add(2 * time.Hour), StatusCode: 500, LatencyMs: 300},
+		{CheckedAt: today.Add(time.Minute), StatusCode: 200, LatencyMs: 50},
+		{CheckedAt: today.Add(2 * time.Minute), Error: "timeout", Maintenance: true},
+	}
+	for _, r := range results {
+		assert.NoError(t, s.SaveCheckResult(ctx, target.ID, r))
+	}
+
+	check := func() {
+		days, err := s.GetDailyUptime(ctx, target.ID, yesterday)
+		assert.NoError(t, err)
+		if assert.Len(t, days, 2) {
+			assert.Equal(t, &DailyUptime{Day: yesterday, Checks: 2, Up: 1, LatencyMsSum: 400}, days[0])
+			assert.Equal(t, &DailyUptime{Day: today, Checks: 1, Up: 1, LatencyMsSum: 50}, days[1])
+		}
+	}
+	check()
+
+	_, err = s.db.Exec(`DELETE FROM daily_rollups`)
+	assert.NoError(t, err)
+	assert.NoError(t, s.backfillRollups(ctx))
+	check()
+
+	days, err := s.GetDailyUptime(ctx, target.ID, today)
+	assert.NoError(t, err)
+	assert.Len(t, days, 1)
+}
+
+func TestStatusPages(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+	ctx := context.Background()
+
+	page := &StatusPage{Slug: "acme", Title: "Acme", Groups: []StatusGroup{{Name: "API", Selector: "tier=api"}}}
+	assert.NoError(t, s.CreateStatusPage(ctx, page))
+	assert.Error(t, s.CreateStatusPage(ctx, &StatusPage{Slug: "acme", Title: "Dup"}))
+
+	got, err := s.GetStatusPage(ctx, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, page.ID, got.ID)
+	assert.Equal(t, page.Groups, got.Groups)
+
+	pages, err := s.ListStatusPages(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+
+	assert.NoError(t, s.DeleteStatusPage(ctx, page.ID))
+	assert.ErrorIs(t, s.DeleteStatusPage(ctx, page.ID), ErrNotFound)
+	_, err = s.GetStatusPage(ctx, "acme")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestTenants_Isolation(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+	ctx := context.Background()
+	assert.NoError(t, s.CreateTenant(ctx, &Tenant{ID: "acme", Name: "Acme", MaxTargets: 2, MinInterval: time.Minute}))
+	acme := WithTenant(ctx, "acme")
+	def := WithTenant(ctx, DefaultTenantID)
+
+	mine, isNew, err := s.CreateTarget(def, "https://example.com", "key-1")
+	assert.NoError(t, err)
+	assert.True(t, isNew)
+	theirs, isNew, err := s.CreateTarget(acme, "https://example.com", "key-1")
+	assert.NoError(t, err)
+	assert.True(t, isNew, "URLs and idempotency keys are unique per tenant")
+	assert.NotEqual(t, mine.ID, theirs.ID)
+	assert.Equal(t, "acme", theirs.TenantID)
+
+	_, err = s.GetTarget(acme, mine.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.UpdateTargetLabels(acme, mine.ID, map[string]string{"x": "y"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	items, _, err := s.ListTargets(acme, "", 10, "")
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	all, _, err := s.ListTargets(ctx, "", 10, "")
+	assert.NoError(t, err)
+	assert.Len(t, all, 2, "unscoped contexts see every tenant")
+
+	s.SaveCheckResult(ctx, mine.ID, &CheckResult{CheckedAt: time.Now().UTC(), StatusCode: 200})
+	results, err := s.GetCheckResults(acme, mine.ID, time.Time{}, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	assert.NoError(t, s.CreateChannel(acme, &Channel{Name: "ops", Type: ChannelWebhook, URL: "https://hooks.example.com"}))
+	channels, err := s.ListChannels(def)
+	assert.NoError(t, err)
+	assert.Empty(t, channels)
+	channels, err = s.ListChannels(acme)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 1)
+
+	key := &APIKey{Name: "ci", Scope: "read", Prefix: "lw_abc", Hash: "h1"}
+	assert.NoError(t, s.CreateAPIKey(acme, key))
+	assert.Equal(t, "acme", key.TenantID)
+	assert.ErrorIs(t, s.RevokeAPIKey(def, key.ID, time.Now()), ErrNotFound)
+
+	// Quota: acme may have two targets; re-adding an existing URL is fine.
+	_, _, err = s.CreateTarget(acme, "https://two.example.com", "")
+	assert.NoError(t, err)
+	_, _, err = s.CreateTarget(acme, "https://three.example.com", "")
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	_, isNew, err = s.CreateTarget(acme, "https://two.example.com", "")
+	assert.NoError(t, err)
+	assert.False(t, isNew)
+	n, err := s.CountTargets(acme)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestTenants_MigratesGlobalUniqueness(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "legacy.db"))
+	assert.NoError(t, err)
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE targets (id TEXT PRIMARY KEY, url TEXT UNIQUE NOT NULL, created_at DATETIME NOT NULL);
+		CREATE TABLE idempotency_keys (key TEXT PRIMARY KEY, target_id TEXT NOT NULL);
+		INSERT INTO targets VALUES ('t_old', 'https://example.com', '2024-01-01 00:00:00');
+		INSERT INTO idempotency_keys VALUES ('k', 't_old');`)
+	assert.NoError(t, err)
+
+	s := NewSQLiteStorage(db)
+	defer s.Close()
+	assert.NoError(t, s.Init(ctx))
+	assert.NoError(t, s.Init(ctx), "migrating twice is a no-op")
+
+	old, isNew, err := s.CreateTarget(WithTenant(ctx, DefaultTenantID), "https://other.com", "k")
+	assert.NoError(t, err)
+	assert.False(t, isNew)
+	assert.Equal(t, "t_old", old.ID)
+	assert.Equal(t, DefaultTenantID, old.TenantID)
+
+	assert.NoError(t, s.CreateTenant(ctx, &Tenant{ID: "acme"}))
+	_, isNew, err = s.CreateTarget(WithTenant(ctx, "acme"), "https://example.com", "k")
+	assert.NoError(t, err)
+	assert.True(t, isNew)
+}
+
+func TestImportTargets(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+	ctx := context.Background()
+
+	existing, _, err := s.CreateTarget(ctx, "https://a.example.com", "")
+	assert.NoError(t, err)
+	stored, created, err := s.ImportTargets(ctx, []*Target{
+		{URL: "https://a.example.com", Public: true},
+		{URL: "https://b.example.com", Labels: map[string]string{"env": "prod"}, Public: true, Interval: 5 * time.Minute},
+		{URL: "https://b.example.com"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, true, false}, created)
+	assert.Equal(t, existing.ID, stored[0].ID)
+	assert.False(t, stored[0].Public, "existing targets are not changed")
+	assert.Equal(t, map[string]string{"env": "prod"}, stored[1].Labels)
+	assert.True(t, stored[1].Public)
+	assert.Equal(t, 5*time.Minute, stored[1].Interval)
+	assert.Equal(t, stored[1].ID, stored[2].ID)
+
+	assert.NoError(t, s.CreateTenant(ctx, &Tenant{ID: "acme", MaxTargets: 1}))
+	acme := WithTenant(ctx, "acme")
+	_, _, err = s.ImportTargets(acme, []*Target{{URL: "https://a.example.com"}, {URL: "https://b.example.com"}})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	n, err := s.CountTargets(acme)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n, "a failed import writes nothing")
+}
+
+func TestDeleteTarget(t *testing.T) {
+	s := setupTestDB(t)
+	defer s.Close()
+	ctx := context.Background()
+
+	target, _, err := s.CreateTarget(ctx, "https://example.com", "key-1")
+	assert.NoError(t, err)
+	assert.NoError(t, s.SaveCheckResult(ctx, target.ID, &CheckResult{CheckedAt: time.Now(), Error: "refused"}))
+	assert.NoError(t, s.CreateIncident(ctx, &Incident{TargetID: target.ID, StartedAt: time.Now()}))
+	managed, err := s.SetTargetManaged(ctx, target.ID, true)
+	assert.NoError(t, err)
+	assert.True(t, managed.Managed)
+
+	assert.ErrorIs(t, s.DeleteTarget(WithTenant(ctx, "acme"), target.ID), ErrNotFound)
+	assert.NoError(t, s.DeleteTarget(ctx, target.ID))
+	assert.ErrorIs(t, s.DeleteTarget(ctx, target.ID), ErrNotFound)
+	results, err := s.GetCheckResults(ctx, target.ID, time.Time{}, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	incidents, err := s.ListIncidents(ctx, target.ID, false, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, incidents)
+
+	// The idempotency key no longer points at the deleted target.
+	again, isNew, err := s.CreateTarget(ctx, "https://example.com", "key-1")
+	assert.NoError(t, err)
+	assert.True(t, isNew)
+	assert.NotEqual(t, target.ID, again.ID)
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// DefaultTenantID owns everything created before tenants existed, and
+// everything created without a tenant in the context.
+const DefaultTenantID = "default"
+
+// ErrQuotaExceeded is returned by CreateTarget when the tenant already has
+// as many targets as it may.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Tenant is an organization sharing the instance. MaxTargets (0 for no limit)
+// caps how many targets it may have, and no target of it is checked more often
+// than MinInterval.
+type Tenant struct {
+	ID          string
+	Name        string
+	MaxTargets  int
+	MinInterval time.Duration
+	CreatedAt   time.Time
+}
+
+type tenantContextKey struct{}
+
+// WithTenant scopes every storage call made with the returned context to the
+// given tenant. An empty id removes the scope.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, id)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, or "" when calls
+// made with ctx see every tenant, as the checker and other background workers
+// do.
+func TenantFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantContextKey{}).(string)
+	return id
+}
+
+// tenantFor is the tenant that owns rows created with ctx.
+func tenantFor(ctx context.Context) string {
+	if id := TenantFromContext(ctx); id != "" {
+		return id
+	}
+	return DefaultTenantID
+}
+
+// ownedBy narrows a query on a table with a tenant_id column to the tenant
+// in ctx. It returns an " AND ..." condition and its argument, or nothing for
+// unscoped contexts.
+func ownedBy(ctx context.Context, column string) (string, []interface{}) {
+	id := TenantFromContext(ctx)
+	if id == "" {
+		return "", nil
+	}
+	return ` AND ` + column + ` = ?`, []interface{}{id}
+}
+
+// targetOwnedBy is ownedBy for tables that belong to a tenant through the
+// target in column.
+func targetOwnedBy(ctx context.Context, column string) (string, []interface{}) {
+	id := TenantFromContext(ctx)
+	if id == "" {
+		return "", nil
+	}
+	return ` AND ` + column + ` IN (SELECT id FROM targets WHERE tenant_id = ?)`, []interface{}{id}
+}
+
+func (s *SQLiteStorage) CreateTenant(ctx context.Context, t *Tenant) error {
+	t.CreatedAt = time.Now().UTC()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO tenants (id, name, max_targets, min_interval_ms, created_at) VALUES (?, ?, ?, ?, ?)`,
+		t.ID, t.Name, t.MaxTargets, t.MinInterval.Milliseconds(), t.CreatedAt)
+	return err
+}
+
+func (s *SQLiteStorage) GetTenant(ctx context.Context, id string) (*Tenant, error) {
+	t, err := scanTenant(s.db.QueryRowContext(ctx, `SELECT id, name, max_targets, min_interval_ms, created_at FROM tenants WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+func (s *SQLiteStorage) ListTenants(ctx context.Context) ([]*Tenant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, max_targets, min_interval_ms, created_at FROM tenants ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []*Tenant
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// UpdateTenant saves the tenant's name and quotas. Lowering MaxTargets below
+// the current count only blocks new targets.
+func (s *SQLiteStorage) UpdateTenant(ctx context.Context, t *Tenant) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE tenants SET name = ?, max_targets = ?, min_interval_ms = ? WHERE id = ?`,
+		t.Name, t.MaxTargets, t.MinInterval.Milliseconds(), t.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanTenant(row scanner) (*Tenant, error) {
+	t := &Tenant{}
+	var minIntervalMs int64
+	if err := row.Scan(&t.ID, &t.Name, &t.MaxTargets, &minIntervalMs, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	t.MinInterval = time.Duration(minIntervalMs) * time.Millisecond
+	return t, nil
+}
+
+// CountTargets returns how many targets the tenant in ctx has.
+func (s *SQLiteStorage) CountTargets(ctx context.Context) (int, error) {
+	cond, args := ownedBy(ctx, "tenant_id")
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM targets WHERE 1 = 1`+cond, args...).Scan(&n)
+	return n, err
+}
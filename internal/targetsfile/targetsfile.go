@@ -0,0 +1,423 @@
+// Package targetsfile keeps targets in step with a YAML file, so they can be
+// reviewed and versioned like the rest of a repository:
+//
+//	tenant: default # optional
+//	prune: false    # delete targets that are not in the file
+//	targets:
+//	  - url: https://example.com
+//	    labels: {env: prod}
+//	    public: true
+//	    interval: 5m
+//
+// Targets in the file are marked managed, which makes them read-only via
+// the API. A target removed from the file is deleted when prune is set and
+// otherwise released, becoming editable again.
+package targetsfile
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/labels"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/urls"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a parsed and validated targets file.
+type File struct {
+	Tenant  string
+	Prune   bool
+	Targets []*storage.Target
+}
+
+type fileYAML struct {
+	Tenant  string                         `yaml:"tenant"`
+	Prune   bool                           `yaml:"prune"`
+	Targets []apitypes.CreateTargetRequest `yaml:"targets"`
+}
+
+// Parse validates a targets file. URLs are canonicalized the way the API
+// does and must not repeat.
+func Parse(data []byte) (*File, error) {
+	var raw fileYAML
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&raw); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	f := &File{Tenant: raw.Tenant, Prune: raw.Prune}
+	if f.Tenant == "" {
+		f.Tenant = storage.DefaultTenantID
+	}
+	seen := map[string]int{}
+	for i, t := range raw.Targets {
+		u, err := urls.Canonicalize(t.URL)
+		if err != nil {
+			return nil, fmt.Errorf("targets[%d].url: must be an http or https URL", i)
+		}
+		if j, ok := seen[u]; ok {
+			return nil, fmt.Errorf("targets[%d].url: %s is already listed at targets[%d]", i, u, j)
+		}
+		seen[u] = i
+		if err := labels.Validate(t.Labels); err != nil {
+			return nil, fmt.Errorf("targets[%d].labels: %w", i, err)
+		}
+		var interval time.Duration
+		if t.Interval != "" {
+			if interval, err = time.ParseDuration(t.Interval); err != nil || interval < time.Second {
+				return nil, fmt.Errorf("targets[%d].interval: must be a duration of at least 1s", i)
+			}
+		}
+		f.Targets = append(f.Targets, &storage.Target{URL: u, Labels: t.Labels, Public: t.Public, Interval: interval, Managed: true})
+	}
+	return f, nil
+}
+
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change is one step of a Plan.
+type Change struct {
+	Action string
+	URL    string
+	// ID is the existing target; empty for creates.
+	ID string
+	// Fields lists updated fields as "name: old -> new".
+	Fields []string
+
+	desired *storage.Target
+	current *storage.Target
+}
+
+// Plan is what reconciling a file would change.
+type Plan struct {
+	Tenant  string
+	Changes []Change
+}
+
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Count returns how many changes have action.
+func (p *Plan) Count(action string) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// String renders the plan as a diff: + for creates, ~ for updates and - for
+// deletes.
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "no changes\n"
+	}
+	var b strings.Builder
+	for _, c := range p.Changes {
+		switch c.Action {
+		case ActionCreate:
+			fmt.Fprintf(&b, "+ %s\n", c.URL)
+		case ActionDelete:
+			fmt.Fprintf(&b, "- %s (%s)\n", c.URL, c.ID)
+		default:
+			fmt.Fprintf(&b, "~ %s (%s)\n", c.URL, c.ID)
+		}
+		for _, field := range c.Fields {
+			fmt.Fprintf(&b, "    %s\n", field)
+		}
+	}
+	fmt.Fprintf(&b, "%d to create, %d to update, %d to delete\n", p.Count(ActionCreate), p.Count(ActionUpdate), p.Count(ActionDelete))
+	return b.String()
+}
+
+// Diff compares f with the targets of its tenant in s.
+func Diff(ctx context.Context, s storage.Storage, f *File) (*Plan, error) {
+	if _, err := s.GetTenant(ctx, f.Tenant); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("tenant %q does not exist", f.Tenant)
+		}
+		return nil, err
+	}
+	ctx = storage.WithTenant(ctx, f.Tenant)
+	existing := map[string]*storage.Target{}
+	var order []*storage.Target
+	pageToken := ""
+	for {
+		page, next, err := s.ListTargets(ctx, "", 1000, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range page {
+			existing[t.URL] = t
+			order = append(order, t)
+		}
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+
+	plan := &Plan{Tenant: f.Tenant}
+	wanted := map[string]bool{}
+	for _, want := range f.Targets {
+		wanted[want.URL] = true
+		have, ok := existing[want.URL]
+		if !ok {
+			c := Change{Action: ActionCreate, URL: want.URL, desired: want}
+			c.Fields = fieldChanges(&storage.Target{}, want)
+			plan.Changes = append(plan.Changes, c)
+			continue
+		}
+		if fields := fieldChanges(have, want); len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, URL: want.URL, ID: have.ID, Fields: fields, desired: want, current: have})
+		}
+	}
+	for _, have := range order {
+		if wanted[have.URL] {
+			continue
+		}
+		if f.Prune {
+			plan.Changes = append(plan.Changes, Change{Action: ActionDelete, URL: have.URL, ID: have.ID, current: have})
+		} else if have.Managed {
+			released := *have
+			released.Managed = false
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, URL: have.URL, ID: have.ID,
+				Fields: fieldChanges(have, &released), desired: &released, current: have})
+		}
+	}
+	return plan, nil
+}
+
+func fieldChanges(have, want *storage.Target) []string {
+	var fields []string
+	if !maps.Equal(have.Labels, want.Labels) {
+		fields = append(fields, fmt.Sprintf("labels: %s -> %s", formatLabels(have.Labels), formatLabels(want.Labels)))
+	}
+	if have.Public != want.Public {
+		fields = append(fields, fmt.Sprintf("public: %t -> %t", have.Public, want.Public))
+	}
+	if have.Interval != want.Interval {
+		fields = append(fields, fmt.Sprintf("interval: %s -> %s", formatInterval(have.Interval), formatInterval(want.Interval)))
+	}
+	if have.Managed != want.Managed {
+		fields = append(fields, fmt.Sprintf("managed: %t -> %t", have.Managed, want.Managed))
+	}
+	return fields
+}
+
+func formatLabels(l map[string]string) string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + l[k]
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatInterval(d time.Duration) string {
+	if d == 0 {
+		return "default"
+	}
+	return d.String()
+}
+
+// Apply carries out plan. Creates happen in one transaction; updates and
+// deletes one target at a time, so a failed Apply leaves a partly applied
+// plan that the next Diff picks up from.
+func Apply(ctx context.Context, s storage.Storage, plan *Plan) error {
+	ctx = storage.WithTenant(ctx, plan.Tenant)
+	var creates []*storage.Target
+	for _, c := range plan.Changes {
+		if c.Action == ActionCreate {
+			creates = append(creates, c.desired)
+		}
+	}
+	if len(creates) > 0 {
+		if _, _, err := s.ImportTargets(ctx, creates); err != nil {
+			return fmt.Errorf("creating targets: %w", err)
+		}
+	}
+	for _, c := range plan.Changes {
+		var err error
+		switch c.Action {
+		case ActionUpdate:
+			err = update(ctx, s, c.current, c.desired)
+		case ActionDelete:
+			err = s.DeleteTarget(ctx, c.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", c.Action, c.URL, err)
+		}
+	}
+	return nil
+}
+
+func update(ctx context.Context, s storage.Storage, have, want *storage.Target) error {
+	var err error
+	if !maps.Equal(have.Labels, want.Labels) {
+		_, err = s.UpdateTargetLabels(ctx, have.ID, want.Labels)
+	}
+	if err == nil && have.Public != want.Public {
+		_, err = s.SetTargetPublic(ctx, have.ID, want.Public)
+	}
+	if err == nil && have.Interval != want.Interval {
+		_, err = s.SetTargetInterval(ctx, have.ID, want.Interval)
+	}
+	if err == nil && have.Managed != want.Managed {
+		_, err = s.SetTargetManaged(ctx, have.ID, want.Managed)
+	}
+	return err
+}
+
+// Reconciler applies a targets file at startup, whenever its content
+// changes and on Reload.
+type Reconciler struct {
+	storage storage.Storage
+	path    string
+	poll    time.Duration
+	reload  chan struct{}
+
+	mu      sync.Mutex
+	lastSum [sha256.Size]byte
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewReconciler watches path by reading it every poll; zero disables
+// polling, leaving Reload as the only trigger.
+func NewReconciler(s storage.Storage, path string, poll time.Duration) *Reconciler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Reconciler{
+		storage: s,
+		path:    path,
+		poll:    poll,
+		reload:  make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Reconcile applies the file now and returns what it changed.
+func (r *Reconciler) Reconcile() (*Plan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+	r.lastSum = sha256.Sum256(data)
+	return r.apply(data)
+}
+
+func (r *Reconciler) apply(data []byte) (*Plan, error) {
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.path, err)
+	}
+	plan, err := Diff(r.ctx, r.storage, f)
+	if err != nil {
+		return nil, err
+	}
+	if err := Apply(r.ctx, r.storage, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Reload asks the running reconciler to apply the file even if it has not
+// changed, as on SIGHUP.
+func (r *Reconciler) Reload() {
+	select {
+	case r.reload <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Reconciler) Start() {
+	var tick <-chan time.Time
+	if r.poll > 0 {
+		ticker := time.NewTicker(r.poll)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.reload:
+			r.logResult(r.Reconcile())
+		case <-tick:
+			r.reconcileIfChanged()
+		}
+	}
+}
+
+func (r *Reconciler) Stop() {
+	r.cancel()
+}
+
+func (r *Reconciler) reconcileIfChanged() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		slog.Error("reading targets file", "path", r.path, "err", err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	if sum == r.lastSum {
+		return
+	}
+	// Remember broken content too, so it is reported once rather than on
+	// every poll.
+	r.lastSum = sum
+	r.logResult(r.apply(data))
+}
+
+func (r *Reconciler) logResult(plan *Plan, err error) {
+	if err != nil {
+		slog.Error("reconciling targets file", "path", r.path, "err", err)
+		return
+	}
+	if !plan.Empty() {
+		slog.Info("reconciled targets file", "path", r.path, "tenant", plan.Tenant,
+			"created", plan.Count(ActionCreate), "updated", plan.Count(ActionUpdate), "deleted", plan.Count(ActionDelete))
+	}
+}
@@ -0,0 +1,128 @@
+package targetsfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(`
+targets:
+  - url: https://Example.com/
+    labels: {env: prod}
+    public: true
+    interval: 5m
+  - url: http://example.org
+`))
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultTenantID, f.Tenant)
+	assert.False(t, f.Prune)
+	require.Len(t, f.Targets, 2)
+	assert.Equal(t, &storage.Target{URL: "https://example.com", Labels: map[string]string{"env": "prod"}, Public: true, Interval: 5 * time.Minute, Managed: true}, f.Targets[0])
+
+	for _, tt := range []struct{ yaml, err string }{
+		{"targets:\n  - url: ftp://example.com\n", "targets[0].url: must be an http or https URL"},
+		{"targets:\n  - url: https://a.example.com\n  - url: https://A.example.com/\n", "targets[1].url: https://a.example.com is already listed at targets[0]"},
+		{"targets:\n  - url: https://a.example.com\n    interval: 1ms\n", "targets[0].interval: must be a duration of at least 1s"},
+		{"targets:\n  - url: https://a.example.com\n    colour: red\n", "field colour not found"},
+		{"prune: maybe\n", "cannot unmarshal"},
+	} {
+		_, err := Parse([]byte(tt.yaml))
+		assert.ErrorContains(t, err, tt.err, tt.yaml)
+	}
+}
+
+func TestDiffAndApply(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	ctx := context.Background()
+	kept, _, _ := s.CreateTarget(ctx, "https://kept.example.com", "")
+	manual, _, _ := s.CreateTarget(ctx, "https://manual.example.com", "")
+
+	f, err := Parse([]byte(`
+targets:
+  - url: https://kept.example.com
+    labels: {env: prod}
+  - url: https://new.example.com
+    interval: 1m
+`))
+	require.NoError(t, err)
+	plan, err := Diff(ctx, s, f)
+	require.NoError(t, err)
+	assert.Equal(t, "~ https://kept.example.com ("+kept.ID+`)
+    labels: {} -> {env=prod}
+    managed: false -> true
++ https://new.example.com
+    interval: default -> 1m0s
+    managed: false -> true
+1 to create, 1 to update, 0 to delete
+`, plan.String())
+	require.NoError(t, Apply(ctx, s, plan))
+
+	got, err := s.GetTarget(ctx, kept.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod"}, got.Labels)
+	assert.True(t, got.Managed)
+	plan, err = Diff(ctx, s, f)
+	require.NoError(t, err)
+	assert.True(t, plan.Empty(), "applying converges")
+	assert.Equal(t, "no changes\n", plan.String())
+
+	// Dropping a target releases it; with prune it and unmanaged targets go.
+	f.Targets = f.Targets[1:]
+	plan, err = Diff(ctx, s, f)
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 1)
+	assert.Equal(t, []string{"managed: true -> false"}, plan.Changes[0].Fields)
+	require.NoError(t, Apply(ctx, s, plan))
+	got, _ = s.GetTarget(ctx, kept.ID)
+	assert.False(t, got.Managed)
+	assert.Equal(t, map[string]string{"env": "prod"}, got.Labels, "released targets keep their settings")
+
+	f.Prune = true
+	plan, err = Diff(ctx, s, f)
+	require.NoError(t, err)
+	assert.Equal(t, 2, plan.Count(ActionDelete))
+	require.NoError(t, Apply(ctx, s, plan))
+	_, err = s.GetTarget(ctx, manual.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	n, _ := s.CountTargets(ctx)
+	assert.Equal(t, 1, n)
+
+	f.Tenant = "nobody"
+	_, err = Diff(ctx, s, f)
+	assert.ErrorContains(t, err, `tenant "nobody" does not exist`)
+}
+
+func TestReconciler_FileChanges(t *testing.T) {
+	s := testutil.SetupTestDB(t)
+	path := filepath.Join(t.TempDir(), "targets.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("targets:\n  - url: https://a.example.com\n"), 0o644))
+	r := NewReconciler(s, path, 0)
+
+	plan, err := r.Reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, 1, plan.Count(ActionCreate))
+
+	r.reconcileIfChanged()
+	n, _ := s.CountTargets(context.Background())
+	assert.Equal(t, 1, n)
+
+	require.NoError(t, os.WriteFile(path, []byte("targets:\n  - url: https://a.example.com\n  - url: https://b.example.com\n"), 0o644))
+	r.reconcileIfChanged()
+	n, _ = s.CountTargets(context.Background())
+	assert.Equal(t, 2, n)
+
+	// A broken file leaves the targets alone.
+	require.NoError(t, os.WriteFile(path, []byte("targets: [{url: ftp://x}]\n"), 0o644))
+	r.reconcileIfChanged()
+	n, _ = s.CountTargets(context.Background())
+	assert.Equal(t, 2, n)
+}
@@ -0,0 +1,30 @@
+// Package urls normalizes target URLs so the same endpoint is stored once.
+package urls
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Canonicalize accepts http and https URLs and lowercases the scheme and
+// host, drops default ports, trailing slashes and the fragment.
+func Canonicalize(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.New("invalid scheme")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		hostParts := strings.Split(u.Host, ":")
+		u.Host = hostParts[0]
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+
+	u.Fragment = ""
+	return u.String(), nil
+}
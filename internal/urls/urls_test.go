@@ -0,0 +1,31 @@
+package urls
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+		err      bool
+	}{
+		{"https://EXAMPLE.com/", "https://example.com", false},
+		{"HTTP://example.com:80/path/", "http://example.com/path", false},
+		{"https://example.com:443", "https://example.com", false},
+		{"ftp://invalid.com", "", true},
+		{"https://example.com#fragment", "https://example.com", false},
+	}
+
+	for _, tt := range tests {
+		res, err := Canonicalize(tt.raw)
+		if tt.err {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, res)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+// Package apitypes holds the request and response bodies of the linkwatch
+// HTTP API. The server encodes them and pkg/client decodes them, so the two
+// cannot drift apart; /v1/openapi.json describes the same shapes.
+package apitypes
+
+import "time"
+
+type Target struct {
+	ID     string            `json:"id"`
+	URL    string            `json:"url"`
+	Labels map[string]string `json:"labels"`
+	// Flapping is set while the target changes state too often to alert on.
+	Flapping bool `json:"flapping"`
+	Public   bool `json:"public"`
+	// Paused targets are not checked.
+	Paused bool `json:"paused"`
+	// Interval is the target's own check interval, empty for the default.
+	Interval string `json:"interval,omitempty"`
+	// Managed targets are defined in the server's targets file and cannot
+	// be changed through the API.
+	Managed   bool      `json:"managed"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateTargetRequest is also one row of a bulk import or export.
+type CreateTargetRequest struct {
+	URL      string            `json:"url" yaml:"url"`
+	Labels   map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Public   bool              `json:"public,omitempty" yaml:"public,omitempty"`
+	Interval string            `json:"interval,omitempty" yaml:"interval,omitempty"`
+}
+
+// UpdateTargetRequest changes the fields that are set. A non-nil Labels
+// replaces all labels, so an empty map removes them; an empty Interval
+// restores the default.
+type UpdateTargetRequest struct {
+	Labels   map[string]string `json:"labels"`
+	Public   *bool             `json:"public,omitempty"`
+	Paused   *bool             `json:"paused,omitempty"`
+	Interval *string           `json:"interval,omitempty"`
+}
+
+type TargetList struct {
+	Items []Target `json:"items"`
+	// NextPageToken fetches the next page; empty on the last one.
+	NextPageToken string `json:"next_page_token"`
+}
+
+// Outcomes of one imported row.
+const (
+	ImportCreated  = "created"
+	ImportExisting = "existing"
+	ImportInvalid  = "invalid"
+)
+
+type ImportResult struct {
+	Created  int         `json:"created"`
+	Existing int         `json:"existing"`
+	Invalid  int         `json:"invalid"`
+	Items    []ImportRow `json:"items"`
+}
+
+// ImportRow reports one row of an import, in input order. Index counts from
+// 0 and skips the CSV header.
+type ImportRow struct {
+	Index  int    `json:"index"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	// ID is the created or existing target; empty for invalid rows.
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type CheckResult struct {
+	CheckedAt  time.Time `json:"checked_at"`
+	StatusCode int       `json:"status_code"`
+	LatencyMs  int       `json:"latency_ms"`
+	// Error is the transport error, nil when a response was received.
+	Error         *string    `json:"error"`
+	CertExpiresAt *time.Time `json:"cert_expires_at,omitempty"`
+	// Maintenance marks results taken during a suppress maintenance window.
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+type ResultList struct {
+	Items []CheckResult `json:"items"`
+}
+
+type Incident struct {
+	ID             string        `json:"id"`
+	TargetID       string        `json:"target_id"`
+	StartedAt      time.Time     `json:"started_at"`
+	EndedAt        *time.Time    `json:"ended_at"`
+	DurationMs     *int64        `json:"duration_ms"`
+	FirstError     string        `json:"first_error"`
+	Open           bool          `json:"open"`
+	TriggerResults []CheckResult `json:"trigger_results"`
+}
+
+type IncidentList struct {
+	Items []Incident `json:"items"`
+}
+
+// Uptime is the share of successful checks over Window; nil without checks.
+type Uptime struct {
+	TargetID   string   `json:"target_id"`
+	Window     string   `json:"window"`
+	Checks     int      `json:"checks"`
+	Successful int      `json:"successful"`
+	Uptime     *float64 `json:"uptime"`
+}
+
+type Notification struct {
+	ID             string     `json:"id"`
+	TargetID       string     `json:"target_id"`
+	Event          string     `json:"event"`
+	URL            string     `json:"url"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+type NotificationList struct {
+	Items []Notification `json:"items"`
+}
+
+// Channel never includes the secret; HasSecret tells whether one is set.
+type Channel struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	URL       string    `json:"url"`
+	HasSecret bool      `json:"has_secret"`
+	TargetID  string    `json:"target_id"`
+	Selector  string    `json:"selector"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateChannelRequest scopes the channel to one target, a label selector
+// or, with neither, every target.
+type CreateChannelRequest struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Secret   string `json:"secret,omitempty"`
+	TargetID string `json:"target_id,omitempty"`
+	Selector string `json:"selector,omitempty"`
+}
+
+type ChannelList struct {
+	Items []Channel `json:"items"`
+}
+
+// ChannelTestResult reports a test delivery; Error is nil when it succeeded.
+type ChannelTestResult struct {
+	Delivered  bool    `json:"delivered"`
+	StatusCode int     `json:"status_code"`
+	Error      *string `json:"error"`
+}
+
+// RuleCondition holds the fields of one rule kind: failures and of for
+// "failures", percentile, latency_ms and window for "latency", within for
+// "cert_expiry".
+type RuleCondition struct {
+	Failures   int     `json:"failures,omitempty"`
+	Of         int     `json:"of,omitempty"`
+	Percentile float64 `json:"percentile,omitempty"`
+	LatencyMs  int     `json:"latency_ms,omitempty"`
+	Window     string  `json:"window,omitempty"`
+	Within     string  `json:"within,omitempty"`
+}
+
+type Rule struct {
+	ID                  string        `json:"id"`
+	Name                string        `json:"name"`
+	Kind                string        `json:"kind"`
+	Severity            string        `json:"severity"`
+	Condition           RuleCondition `json:"condition"`
+	TargetID            string        `json:"target_id"`
+	Selector            string        `json:"selector"`
+	ChannelIDs          []string      `json:"channel_ids"`
+	RepeatInterval      string        `json:"repeat_interval"`
+	EscalationChannelID string        `json:"escalation_channel_id"`
+	EscalateAfter       string        `json:"escalate_after"`
+	CreatedAt           time.Time     `json:"created_at"`
+}
+
+// CreateRuleRequest takes durations such as "5m" or "7d". Severity defaults
+// to critical.
+type CreateRuleRequest struct {
+	Name                string        `json:"name"`
+	Kind                string        `json:"kind"`
+	Severity            string        `json:"severity,omitempty"`
+	Condition           RuleCondition `json:"condition"`
+	TargetID            string        `json:"target_id,omitempty"`
+	Selector            string        `json:"selector,omitempty"`
+	ChannelIDs          []string      `json:"channel_ids,omitempty"`
+	RepeatInterval      string        `json:"repeat_interval,omitempty"`
+	EscalationChannelID string        `json:"escalation_channel_id,omitempty"`
+	EscalateAfter       string        `json:"escalate_after,omitempty"`
+}
+
+type RuleList struct {
+	Items []Rule `json:"items"`
+}
+
+type Alert struct {
+	ID             string     `json:"id"`
+	RuleID         string     `json:"rule_id"`
+	TargetID       string     `json:"target_id"`
+	Severity       string     `json:"severity"`
+	Status         string     `json:"status"`
+	Summary        string     `json:"summary"`
+	StartedAt      time.Time  `json:"started_at"`
+	Acknowledged   bool       `json:"acknowledged"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	AcknowledgedBy string     `json:"acknowledged_by"`
+	EscalatedAt    *time.Time `json:"escalated_at"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	LastNotifiedAt time.Time  `json:"last_notified_at"`
+}
+
+type AlertList struct {
+	Items []Alert `json:"items"`
+}
+
+// AcknowledgeAlertRequest is optional; By records who acknowledged.
+type AcknowledgeAlertRequest struct {
+	By string `json:"by,omitempty"`
+}
+
+// MaintenanceWindow is either recurring, with Schedule and Duration, or
+// one-off, with StartsAt and EndsAt.
+type MaintenanceWindow struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Mode      string     `json:"mode"`
+	TargetID  string     `json:"target_id"`
+	Selector  string     `json:"selector"`
+	Active    bool       `json:"active"`
+	Schedule  string     `json:"schedule,omitempty"`
+	Duration  string     `json:"duration,omitempty"`
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreateMaintenanceWindowRequest needs Schedule and Duration or StartsAt and
+// EndsAt. Mode defaults to suppress.
+type CreateMaintenanceWindowRequest struct {
+	Name     string     `json:"name,omitempty"`
+	Mode     string     `json:"mode,omitempty"`
+	TargetID string     `json:"target_id,omitempty"`
+	Selector string     `json:"selector,omitempty"`
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Schedule string     `json:"schedule,omitempty"`
+	Duration string     `json:"duration,omitempty"`
+}
+
+type MaintenanceWindowList struct {
+	Items []MaintenanceWindow `json:"items"`
+}
+
+type Silence struct {
+	ID        string    `json:"id"`
+	TargetID  string    `json:"target_id"`
+	Selector  string    `json:"selector"`
+	Comment   string    `json:"comment"`
+	CreatedBy string    `json:"created_by"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateSilenceRequest needs either Duration or ExpiresAt.
+type CreateSilenceRequest struct {
+	TargetID  string     `json:"target_id,omitempty"`
+	Selector  string     `json:"selector,omitempty"`
+	Comment   string     `json:"comment,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	Duration  string     `json:"duration,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type SilenceList struct {
+	Items []Silence `json:"items"`
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	// Key is only returned when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name     string `json:"name"`
+	Scope    string `json:"scope"`
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+type APIKeyList struct {
+	Items []APIKey `json:"items"`
+}
+
+// Tenant shows a tenant's quotas and how many targets it has. A zero
+// MaxTargets means no limit.
+type Tenant struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	MaxTargets  int       `json:"max_targets"`
+	MinInterval string    `json:"min_interval"`
+	Targets     int       `json:"targets"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreateTenantRequest struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name,omitempty"`
+	MaxTargets  *int    `json:"max_targets,omitempty"`
+	MinInterval *string `json:"min_interval,omitempty"`
+}
+
+// UpdateTenantRequest changes the fields that are set.
+type UpdateTenantRequest struct {
+	Name        string  `json:"name,omitempty"`
+	MaxTargets  *int    `json:"max_targets,omitempty"`
+	MinInterval *string `json:"min_interval,omitempty"`
+}
+
+type TenantList struct {
+	Items []Tenant `json:"items"`
+}
+
+// StatusGroup lists the targets matching Selector; empty matches all.
+type StatusGroup struct {
+	Name     string `json:"name"`
+	Selector string `json:"selector"`
+}
+
+type StatusPage struct {
+	ID          string        `json:"id"`
+	Slug        string        `json:"slug"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Groups      []StatusGroup `json:"groups"`
+	// Path is where the page is served, relative to the server.
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateStatusPageRequest struct {
+	Slug        string        `json:"slug"`
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Groups      []StatusGroup `json:"groups"`
+}
+
+type StatusPageList struct {
+	Items []StatusPage `json:"items"`
+}
+
+// Event is the data of a server-sent event from /v1/events. Which optional
+// fields are set depends on the event type: Result for "result", From, To,
+// Flapping and possibly Result and Incident for "state", and Flapping, State
+// and PercentStateChange for "flapping". Messages on /v1/ws also carry ID
+// and Type, which is "incident" for state changes with an incident.
+type Event struct {
+	ID                 uint64            `json:"id,omitempty"`
+	Type               string            `json:"type,omitempty"`
+	TargetID           string            `json:"target_id"`
+	URL                string            `json:"url"`
+	Labels             map[string]string `json:"labels"`
+	At                 time.Time         `json:"at"`
+	Result             *CheckResult      `json:"result,omitempty"`
+	From               string            `json:"from,omitempty"`
+	To                 string            `json:"to,omitempty"`
+	Flapping           *bool             `json:"flapping,omitempty"`
+	Incident           *Incident         `json:"incident,omitempty"`
+	State              string            `json:"state,omitempty"`
+	PercentStateChange *float64          `json:"percent_state_change,omitempty"`
+}
+
+// WSRequest changes the subscriptions of a /v1/ws connection. Type is
+// "subscribe" or "unsubscribe".
+type WSRequest struct {
+	Type      string   `json:"type"`
+	TargetIDs []string `json:"target_ids,omitempty"`
+	Selectors []string `json:"selectors,omitempty"`
+}
+
+// WSSubscriptions answers every WSRequest with the current subscriptions.
+type WSSubscriptions struct {
+	Type      string   `json:"type"`
+	TargetIDs []string `json:"target_ids"`
+	Selectors []string `json:"selectors"`
+}
+
+// WSNotice is an "error" message answering an invalid WSRequest, or a
+// "dropped" message counting events lost because the client fell behind.
+type WSNotice struct {
+	Type  string `json:"type"`
+	Error string `json:"error,omitempty"`
+	Count uint64 `json:"count,omitempty"`
+}
+
+// Readiness is the body of /readyz; Status is "ok" or "unavailable".
+type Readiness struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// ComponentStatus is "ok" or "error" with the reason in Error. The checker
+// also reports its last completed cycle.
+type ComponentStatus struct {
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	LastCycleAt *time.Time `json:"last_cycle_at,omitempty"`
+	AgeSeconds  *int       `json:"age_seconds,omitempty"`
+}
+
+// Problem is an RFC 7807 error body.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,316 @@
+// Package client is a Go client for the linkwatch HTTP API.
+//
+//	c := client.New("http://localhost:8080", os.Getenv("LINKWATCH_API_KEY"))
+//	t, _, err := c.CreateTarget(ctx, apitypes.CreateTargetRequest{URL: "https://example.com"}, "")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+)
+
+// Client calls one linkwatch server. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// New returns a client for the server at baseURL that authenticates with
+// apiKey, or anonymously when it is empty.
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SetHTTPClient replaces the default client, which times out after 30s.
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.httpClient = hc
+}
+
+// Error is an error response from the server.
+type Error struct {
+	apitypes.Problem
+}
+
+func (e *Error) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Title
+	}
+	return fmt.Sprintf("linkwatch: %d %s: %s", e.Status, e.Code, msg)
+}
+
+// IsNotFound reports whether err is a 404 from the server.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// do sends a request with an optional body and decodes a JSON response into
+// out unless it is nil. An io.Reader body is sent as is, with the
+// Content-Type from header; other bodies are encoded as JSON. It returns the
+// status code of successful responses and an *Error for the others.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body, out interface{}) (int, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	reader, raw := body.(io.Reader)
+	if body != nil && !raw {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil && !raw {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, decodeError(resp)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("linkwatch: decoding %s %s response: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// decodeError reads a problem+json body, falling back to the status line for
+// responses that are not, such as those of a proxy in front of the server.
+func decodeError(resp *http.Response) error {
+	e := &Error{}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(b, &e.Problem) != nil || e.Status == 0 {
+		e.Problem = apitypes.Problem{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode), Detail: strings.TrimSpace(string(b))}
+	}
+	return e
+}
+
+// CreateTarget creates a target. created is false when a target with the
+// same canonical URL, or the same idempotencyKey, already existed.
+func (c *Client) CreateTarget(ctx context.Context, req apitypes.CreateTargetRequest, idempotencyKey string) (t *apitypes.Target, created bool, err error) {
+	var header http.Header
+	if idempotencyKey != "" {
+		header = http.Header{"Idempotency-Key": {idempotencyKey}}
+	}
+	t = &apitypes.Target{}
+	status, err := c.do(ctx, http.MethodPost, "/v1/targets", nil, header, req, t)
+	if err != nil {
+		return nil, false, err
+	}
+	return t, status == http.StatusCreated, nil
+}
+
+func (c *Client) GetTarget(ctx context.Context, id string) (*apitypes.Target, error) {
+	t := &apitypes.Target{}
+	if _, err := c.do(ctx, http.MethodGet, "/v1/targets/"+url.PathEscape(id), nil, nil, nil, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (c *Client) UpdateTarget(ctx context.Context, id string, req apitypes.UpdateTargetRequest) (*apitypes.Target, error) {
+	t := &apitypes.Target{}
+	if _, err := c.do(ctx, http.MethodPatch, "/v1/targets/"+url.PathEscape(id), nil, nil, req, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// DeleteTarget deletes a target with its results, incidents and alerts.
+func (c *Client) DeleteTarget(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/v1/targets/"+url.PathEscape(id), nil, nil, nil, nil)
+	return err
+}
+
+// SetPaused pauses or resumes checks of a target.
+func (c *Client) SetPaused(ctx context.Context, id string, paused bool) (*apitypes.Target, error) {
+	return c.UpdateTarget(ctx, id, apitypes.UpdateTargetRequest{Paused: &paused})
+}
+
+// ImportTargets creates targets from r, a JSON array, CSV file or YAML list
+// as named by contentType. Unless bestEffort is set, one invalid row fails
+// the whole import with an *Error listing every problem.
+func (c *Client) ImportTargets(ctx context.Context, r io.Reader, contentType string, bestEffort bool) (*apitypes.ImportResult, error) {
+	q := url.Values{}
+	if bestEffort {
+		q.Set("mode", "best_effort")
+	}
+	result := &apitypes.ImportResult{}
+	if _, err := c.do(ctx, http.MethodPost, "/v1/targets:import", q, http.Header{"Content-Type": {contentType}}, r, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListTargetsOptions filters and pages ListTargets. Zero values use the
+// server's defaults.
+type ListTargetsOptions struct {
+	Host      string
+	Limit     int
+	PageToken string
+}
+
+// ListTargets returns one page of targets.
+func (c *Client) ListTargets(ctx context.Context, opts ListTargetsOptions) (*apitypes.TargetList, error) {
+	q := url.Values{}
+	setString(q, "host", opts.Host)
+	setInt(q, "limit", opts.Limit)
+	setString(q, "page_token", opts.PageToken)
+	list := &apitypes.TargetList{}
+	if _, err := c.do(ctx, http.MethodGet, "/v1/targets", q, nil, nil, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Targets iterates over every target matching opts, fetching pages as
+// needed. Iteration stops after the first error.
+func (c *Client) Targets(ctx context.Context, opts ListTargetsOptions) iter.Seq2[apitypes.Target, error] {
+	return func(yield func(apitypes.Target, error) bool) {
+		for {
+			page, err := c.ListTargets(ctx, opts)
+			if err != nil {
+				yield(apitypes.Target{}, err)
+				return
+			}
+			for _, t := range page.Items {
+				if !yield(t, nil) {
+					return
+				}
+			}
+			if page.NextPageToken == "" {
+				return
+			}
+			opts.PageToken = page.NextPageToken
+		}
+	}
+}
+
+// ResultsOptions narrows Results. Zero values use the server's defaults.
+type ResultsOptions struct {
+	Since time.Time
+	Limit int
+}
+
+// Results returns a target's latest check results, newest first.
+func (c *Client) Results(ctx context.Context, targetID string, opts ResultsOptions) ([]apitypes.CheckResult, error) {
+	q := url.Values{}
+	if !opts.Since.IsZero() {
+		q.Set("since", opts.Since.UTC().Format(time.RFC3339))
+	}
+	setInt(q, "limit", opts.Limit)
+	var list apitypes.ResultList
+	if _, err := c.do(ctx, http.MethodGet, "/v1/targets/"+url.PathEscape(targetID)+"/results", q, nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// IncidentsOptions narrows Incidents. An empty TargetID lists incidents of
+// all targets.
+type IncidentsOptions struct {
+	TargetID string
+	OpenOnly bool
+	Limit    int
+}
+
+// Incidents returns incidents, newest first.
+func (c *Client) Incidents(ctx context.Context, opts IncidentsOptions) ([]apitypes.Incident, error) {
+	path := "/v1/incidents"
+	if opts.TargetID != "" {
+		path = "/v1/targets/" + url.PathEscape(opts.TargetID) + "/incidents"
+	}
+	q := url.Values{}
+	if opts.OpenOnly {
+		q.Set("open", "true")
+	}
+	setInt(q, "limit", opts.Limit)
+	var list apitypes.IncidentList
+	if _, err := c.do(ctx, http.MethodGet, path, q, nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Uptime returns a target's uptime over window, such as "24h" or "7d"; an
+// empty window uses the server's default of 24h.
+func (c *Client) Uptime(ctx context.Context, targetID, window string) (*apitypes.Uptime, error) {
+	q := url.Values{}
+	setString(q, "window", window)
+	u := &apitypes.Uptime{}
+	if _, err := c.do(ctx, http.MethodGet, "/v1/targets/"+url.PathEscape(targetID)+"/uptime", q, nil, nil, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// NotificationsOptions narrows Notifications.
+type NotificationsOptions struct {
+	TargetID string
+	// Status is pending, delivered or failed; empty for all.
+	Status string
+	Limit  int
+}
+
+// Notifications returns the alert delivery log, newest first. It needs an
+// admin key.
+func (c *Client) Notifications(ctx context.Context, opts NotificationsOptions) ([]apitypes.Notification, error) {
+	q := url.Values{}
+	setString(q, "target_id", opts.TargetID)
+	setString(q, "status", opts.Status)
+	setInt(q, "limit", opts.Limit)
+	var list apitypes.NotificationList
+	if _, err := c.do(ctx, http.MethodGet, "/v1/notifications", q, nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func setString(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
+
+func setInt(q url.Values, key string, value int) {
+	if value != 0 {
+		q.Set(key, strconv.Itoa(value))
+	}
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlanZeng-Coder/linkwatch/internal/api"
+	"github.com/AlanZeng-Coder/linkwatch/internal/auth"
+	"github.com/AlanZeng-Coder/linkwatch/internal/storage"
+	"github.com/AlanZeng-Coder/linkwatch/internal/testutil"
+	"github.com/AlanZeng-Coder/linkwatch/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "test-admin-key"
+
+// newServer serves the API routes behind authentication.
+func newServer(t *testing.T) (*httptest.Server, storage.Storage) {
+	s := testutil.SetupTestDB(t)
+	srv := httptest.NewServer(api.NewHandler(s).Routes(auth.New(s, testKey)))
+	t.Cleanup(srv.Close)
+	return srv, s
+}
+
+func TestClient_Targets(t *testing.T) {
+	srv, _ := newServer(t)
+	c := New(srv.URL+"/", testKey)
+	ctx := context.Background()
+
+	created, isNew, err := c.CreateTarget(ctx, apitypes.CreateTargetRequest{URL: "https://Example.com/", Labels: map[string]string{"env": "prod"}, Interval: "5m"}, "key-1")
+	require.NoError(t, err)
+	assert.True(t, isNew)
+	assert.Equal(t, "https://example.com", created.URL)
+	assert.Equal(t, map[string]string{"env": "prod"}, created.Labels)
+	assert.Equal(t, "5m0s", created.Interval)
+	assert.WithinDuration(t, time.Now(), created.CreatedAt, time.Minute)
+
+	again, isNew, err := c.CreateTarget(ctx, apitypes.CreateTargetRequest{URL: "https://example.com"}, "")
+	require.NoError(t, err)
+	assert.False(t, isNew)
+	assert.Equal(t, created.ID, again.ID)
+
+	got, err := c.GetTarget(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+
+	public := true
+	updated, err := c.UpdateTarget(ctx, created.ID, apitypes.UpdateTargetRequest{Public: &public})
+	require.NoError(t, err)
+	assert.True(t, updated.Public)
+	assert.Equal(t, map[string]string{"env": "prod"}, updated.Labels, "labels are kept when not sent")
+	updated, err = c.UpdateTarget(ctx, created.ID, apitypes.UpdateTargetRequest{Labels: map[string]string{}})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Labels)
+
+	for _, u := range []string{"https://a.example.com", "https://b.example.com", "https://c.example.com", "https://d.example.com"} {
+		_, _, err := c.CreateTarget(ctx, apitypes.CreateTargetRequest{URL: u}, "")
+		require.NoError(t, err)
+	}
+	page, err := c.ListTargets(ctx, ListTargetsOptions{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	assert.NotEmpty(t, page.NextPageToken)
+
+	seen := map[string]bool{}
+	for target, err := range c.Targets(ctx, ListTargetsOptions{Limit: 2}) {
+		require.NoError(t, err)
+		assert.False(t, seen[target.ID], "target listed twice")
+		seen[target.ID] = true
+	}
+	assert.Len(t, seen, 5)
+
+	paused, err := c.SetPaused(ctx, created.ID, true)
+	require.NoError(t, err)
+	assert.True(t, paused.Paused)
+	require.NoError(t, c.DeleteTarget(ctx, created.ID))
+	_, err = c.GetTarget(ctx, created.ID)
+	assert.True(t, IsNotFound(err))
+}
+
+func TestClient_ImportTargets(t *testing.T) {
+	srv, _ := newServer(t)
+	ctx := context.Background()
+	c := New(srv.URL, testKey)
+
+	csv := "url,label:env\nhttps://a.example.com,prod\nftp://b.example.com,\n"
+	_, err := c.ImportTargets(ctx, strings.NewReader(csv), "text/csv", false)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "[1].url", apiErr.Errors[0].Field)
+
+	result, err := c.ImportTargets(ctx, strings.NewReader(csv), "text/csv", true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Created)
+	assert.Equal(t, 1, result.Invalid)
+	assert.Equal(t, apitypes.ImportCreated, result.Items[0].Status)
+}
+
+func TestClient_ResultsAndIncidents(t *testing.T) {
+	srv, s := newServer(t)
+	c := New(srv.URL, testKey)
+	ctx := context.Background()
+
+	target, _, err := c.CreateTarget(ctx, apitypes.CreateTargetRequest{URL: "https://example.com"}, "")
+	require.NoError(t, err)
+	now := time.Now().UTC()
+	require.NoError(t, s.SaveCheckResult(ctx, target.ID, &storage.CheckResult{CheckedAt: now.Add(-time.Minute), StatusCode: 200, LatencyMs: 42}))
+	require.NoError(t, s.SaveCheckResult(ctx, target.ID, &storage.CheckResult{CheckedAt: now, Error: "connection refused"}))
+	require.NoError(t, s.CreateIncident(ctx, &storage.Incident{TargetID: target.ID, StartedAt: now, FirstError: "connection refused"}))
+
+	results, err := c.Results(ctx, target.ID, ResultsOptions{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.NotNil(t, results[0].Error)
+	assert.Equal(t, "connection refused", *results[0].Error)
+	assert.Nil(t, results[1].Error)
+	assert.Equal(t, 42, results[1].LatencyMs)
+
+	results, err = c.Results(ctx, target.ID, ResultsOptions{Since: now.Add(-time.Second)})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	incidents, err := c.Incidents(ctx, IncidentsOptions{TargetID: target.ID, OpenOnly: true})
+	require.NoError(t, err)
+	require.Len(t, incidents, 1)
+	assert.True(t, incidents[0].Open)
+	assert.Nil(t, incidents[0].EndedAt)
+	incidents, err = c.Incidents(ctx, IncidentsOptions{})
+	require.NoError(t, err)
+	assert.Len(t, incidents, 1)
+
+	uptime, err := c.Uptime(ctx, target.ID, "1h")
+	require.NoError(t, err)
+	assert.Equal(t, 2, uptime.Checks)
+	require.NotNil(t, uptime.Uptime)
+	assert.InDelta(t, 50, *uptime.Uptime, 0.001)
+
+	notifications, err := c.Notifications(ctx, NotificationsOptions{Status: storage.NotificationPending})
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func TestClient_Errors(t *testing.T) {
+	srv, _ := newServer(t)
+	ctx := context.Background()
+	c := New(srv.URL, testKey)
+
+	_, err := c.GetTarget(ctx, "t_missing")
+	assert.True(t, IsNotFound(err))
+
+	_, _, err = c.CreateTarget(ctx, apitypes.CreateTargetRequest{URL: "ftp://example.com"}, "")
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+	assert.Equal(t, "invalid_argument", apiErr.Code)
+	require.Len(t, apiErr.Errors, 1)
+	assert.Equal(t, "url", apiErr.Errors[0].Field)
+
+	_, err = c.ListTargets(ctx, ListTargetsOptions{PageToken: "bogus"})
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "page_token", apiErr.Errors[0].Field)
+
+	_, err = New(srv.URL, "").ListTargets(ctx, ListTargetsOptions{})
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.Status)
+	assert.Contains(t, err.Error(), "API key required")
+}